@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  АЛЕРТЫ — критичные тикеты в Slack/Telegram (ALERT_WEBHOOK_URL)
+// ═══════════════════════════════════════════════════════════
+
+// Критерии алерта (ALERT_TYPES, ALERT_MIN_PRIORITY) и доставка
+// (ALERT_WEBHOOK_URL, ALERT_TELEGRAM_CHAT_ID, ALERT_BATCH_WINDOW).
+// Без ALERT_WEBHOOK_URL алерты не отправляются.
+var (
+	alertTypes = map[string]bool{
+		"Мошеннические действия": true,
+		"Претензия":              true,
+	}
+	alertMinPriority  = 9
+	alertWebhookURL   string
+	alertTelegramChat string // задан — тело в формате Telegram sendMessage, иначе Slack
+	alertBatchWindow  = 10 * time.Second
+)
+
+const (
+	// alertMaxPerMessage — тикетов в одном сообщении; остальные — следующим
+	alertMaxPerMessage = 20
+	alertSendTimeout   = 10 * time.Second
+	// alertSummaryMax — символов summary в строке тикета
+	alertSummaryMax = 300
+	// Предел длины сообщения: Telegram sendMessage — 4096 символов (запас на
+	// эмодзи, они считаются двумя), Slack обрезает text после 40000
+	alertTelegramMaxChars = 4000
+	alertSlackMaxChars    = 39000
+)
+
+// loadAlertConfig — настройки алертов из окружения
+func loadAlertConfig() {
+	alertWebhookURL = strings.TrimSpace(getEnv("ALERT_WEBHOOK_URL", ""))
+	alertTelegramChat = strings.TrimSpace(getEnv("ALERT_TELEGRAM_CHAT_ID", ""))
+	if v, ok := os.LookupEnv("ALERT_TYPES"); ok {
+		alertTypes = make(map[string]bool)
+		for _, typ := range strings.Split(v, ",") {
+			if typ = strings.TrimSpace(typ); typ != "" {
+				alertTypes[typ] = true
+			}
+		}
+	}
+	if n, err := strconv.Atoi(getEnv("ALERT_MIN_PRIORITY", "")); err == nil && n >= 1 && n <= 10 {
+		alertMinPriority = n
+	}
+	if d := getEnvDuration("ALERT_BATCH_WINDOW", alertBatchWindow); d > 0 {
+		alertBatchWindow = d
+	}
+	if alertWebhookURL != "" {
+		types := make([]string, 0, len(alertTypes))
+		for typ := range alertTypes {
+			types = append(types, typ)
+		}
+		sort.Strings(types)
+		fmt.Printf("🚨 Алерты: %s, приоритет ≥ %d → webhook (пачки раз в %v)\n",
+			strings.Join(types, " / "), alertMinPriority, alertBatchWindow)
+	}
+}
+
+// alertNotifier — копит критичные тикеты и отправляет их одним сообщением
+// через alertBatchWindow после первого: всплеск не превращается в поток
+// уведомлений. Ошибка отправки только логируется — роутинг не ждёт webhook.
+type alertNotifier struct {
+	mu      sync.Mutex
+	pending []RoutingResult
+	timer   *time.Timer
+	// sendMu — отправки по очереди: Flush в конце запуска дожидается
+	// пачки, которую в этот момент отправляет таймер
+	sendMu sync.Mutex
+}
+
+// alerts — алерты процесса
+var alerts = &alertNotifier{}
+
+// needsAlert — тикет подходит под критерии алерта
+func needsAlert(r RoutingResult) bool {
+	return alertTypes[r.Type] && priorityNum(r.Priority) >= alertMinPriority
+}
+
+// Add — учесть результат роутинга; no-op без ALERT_WEBHOOK_URL
+func (a *alertNotifier) Add(r RoutingResult) {
+	if alertWebhookURL == "" || !needsAlert(r) {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(a.pending, r)
+	if a.timer == nil {
+		a.timer = time.AfterFunc(alertBatchWindow, a.Flush)
+	}
+}
+
+// Flush — отправить накопленное сейчас (по таймеру и в конце запуска)
+func (a *alertNotifier) Flush() {
+	a.mu.Lock()
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	batch := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+	for len(batch) > 0 {
+		text, n := formatAlert(batch, alertMaxChars())
+		if err := postAlert(text); err != nil {
+			log.Printf("⚠️ Алерт (%d тикетов) не отправлен: %v", n, err)
+		} else {
+			fmt.Printf("🚨 Алерт отправлен: %d критичных тикетов\n", n)
+		}
+		batch = batch[n:]
+	}
+}
+
+// alertMaxChars — предел длины сообщения для транспорта (Telegram или Slack)
+func alertMaxChars() int {
+	if alertTelegramChat != "" {
+		return alertTelegramMaxChars
+	}
+	return alertSlackMaxChars
+}
+
+// alertHeader — первая строка сообщения
+func alertHeader(n int) string {
+	return fmt.Sprintf("🚨 FIRE: критичных тикетов — %d\n", n)
+}
+
+// alertLine — блок одного тикета (summary обрезается до alertSummaryMax)
+func alertLine(r RoutingResult) string {
+	summary, _ := truncateSummary(r.Summary, 0, alertSummaryMax)
+	return fmt.Sprintf("\n• %s | %s, приоритет %s\n  Офис: %s, менеджер: %s\n  %s\n",
+		r.GUID, r.Type, r.Priority, tenantOffice(tenantID(r.Tenant), r.AssignedOffice), r.ManagerName, summary)
+}
+
+// formatAlert — сообщение с первых тикетов batch: не больше alertMaxPerMessage
+// и maxChars символов (хотя бы один тикет). n — сколько тикетов вошло.
+func formatAlert(batch []RoutingResult, maxChars int) (text string, n int) {
+	var lines []string
+	size := 0
+	for _, r := range batch {
+		if len(lines) == alertMaxPerMessage {
+			break
+		}
+		line := alertLine(r)
+		lineSize := len([]rune(line))
+		if len(lines) > 0 && len([]rune(alertHeader(len(lines)+1)))+size+lineSize > maxChars {
+			break
+		}
+		lines = append(lines, line)
+		size += lineSize
+	}
+	return alertHeader(len(lines)) + strings.Join(lines, ""), len(lines)
+}
+
+// postAlert — POST в webhook: Slack ({"text"}) или Telegram sendMessage ({"chat_id","text"})
+func postAlert(text string) error {
+	payload := map[string]string{"text": text}
+	if alertTelegramChat != "" {
+		payload["chat_id"] = alertTelegramChat
+	}
+	body, _ := json.Marshal(payload)
+
+	ctx, cancel := context.WithTimeout(context.Background(), alertSendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", alertWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook ответил %d", resp.StatusCode)
+	}
+	return nil
+}
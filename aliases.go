@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  АЛИАСЫ ГОРОДОВ — старые и альтернативные названия → офис
+// ═══════════════════════════════════════════════════════════
+
+// defaultCityAliases — нормализованное название города → офис. Стартовый словарь
+// повторяет примеры из промпта; копия в каждом Engine дополняется/переопределяется
+// data/city_aliases.csv.
+var defaultCityAliases = map[string]string{
+	"семипалатинск": "Усть-Каменогорск",
+	"семей":         "Усть-Каменогорск",
+	"өскемен":       "Усть-Каменогорск",
+	"оскемен":       "Усть-Каменогорск",
+	"кокпекты":      "Усть-Каменогорск",
+	"бескарагай":    "Усть-Каменогорск",
+	"целиноград":    "Астана",
+	"акмола":        "Астана",
+	"акмолинск":     "Астана",
+	"нур-султан":    "Астана",
+	"косшы":         "Астана",
+	"красный яр":    "Астана",
+	"капчагай":      "Алматы",
+	"конаев":        "Алматы",
+	"қонаев":        "Алматы",
+	"алма-ата":      "Алматы",
+	"тургень":       "Алматы",
+	"чимкент":       "Шымкент",
+	"джамбул":       "Тараз",
+	"аулие-ата":     "Тараз",
+	"гурьев":        "Атырау",
+	"индербор":      "Атырау",
+	"шевченко":      "Актау",
+	"aktau":         "Актау",
+	"актюбинск":     "Актобе",
+	"кустанай":      "Костанай",
+	"кокчетав":      "Кокшетау",
+	"аксу":          "Павлодар",
+	"петропавл":     "Петропавловск",
+	"қызылорда":     "Кызылорда",
+	// Типичные результаты транслитерации латиницы (Ust-Kamenogorsk, Uralsk)
+	"уст-каменогорск": "Усть-Каменогорск",
+	"уралск":          "Уральск",
+}
+
+// normalizeCityName — нижний регистр без «г.», «город» и лишних знаков
+func normalizeCityName(city string) string {
+	c := strings.ToLower(strings.TrimSpace(city))
+	for _, prefix := range []string{"город ", "г. ", "г.", "г "} {
+		c = strings.TrimPrefix(c, prefix)
+	}
+	return strings.Trim(c, " .,;")
+}
+
+// lookupCityAlias — офис по алиасу города; ok=false, если алиаса нет
+func (e *Engine) lookupCityAlias(city string) (string, bool) {
+	office, ok := e.cityAliases[normalizeCityName(city)]
+	return office, ok
+}
+
+// LoadCityAliases — дополнительные алиасы из CSV (Город,Офис). Файл необязателен;
+// строки с офисом, которого нет в business_units.csv, пропускаются.
+func (e *Engine) LoadCityAliases(fp string) {
+	file, err := os.Open(fp)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	records, err := readCSV(file)
+	if err != nil {
+		fmt.Printf("⚠️ Ошибка чтения %s: %v\n", fp, err)
+		return
+	}
+	added := 0
+	for i, row := range records {
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+		alias := normalizeCityName(row[0])
+		office := e.normalizeOfficeName(row[1])
+		if alias == "" || office == "" {
+			fmt.Printf("⚠️ %s: пропущен алиас '%s' → неизвестный офис '%s'\n", fp, row[0], row[1])
+			continue
+		}
+		e.cityAliases[alias] = office
+		added++
+	}
+	fmt.Printf("✅ Алиасов городов: %d (из %s: %d)\n", len(e.cityAliases), fp, added)
+}
+
+// ═══════════════════════════════════════════════════════════
+//  ТРАНСЛИТЕРАЦИЯ — латиница → кириллица для городов
+// ═══════════════════════════════════════════════════════════
+
+// translitDigraphs — сочетания проверяются раньше одиночных букв
+var translitDigraphs = []struct{ lat, cyr string }{
+	{"shch", "щ"}, {"sh", "ш"}, {"ch", "ч"}, {"zh", "ж"}, {"kh", "х"},
+	{"ts", "ц"}, {"ya", "я"}, {"yu", "ю"}, {"yo", "ё"},
+}
+
+var translitLetters = map[rune]string{
+	'a': "а", 'b': "б", 'c': "к", 'd': "д", 'e': "е", 'f': "ф", 'g': "г", 'h': "х",
+	'i': "и", 'j': "ж", 'k': "к", 'l': "л", 'm': "м", 'n': "н", 'o': "о", 'p': "п",
+	'q': "к", 'r': "р", 's': "с", 't': "т", 'u': "у", 'v': "в", 'w': "в", 'x': "кс",
+	'y': "ы", 'z': "з",
+}
+
+// looksLatin — в названии есть латиница и нет кириллицы
+func looksLatin(s string) bool {
+	hasLatin := false
+	for _, r := range s {
+		switch {
+		case r >= 'а' && r <= 'я', r >= 'А' && r <= 'Я', r == 'ё', r == 'Ё':
+			return false
+		case (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			hasLatin = true
+		}
+	}
+	return hasLatin
+}
+
+// translitToCyrillic — «Shymkent» → «Шымкент», «Kostanay» → «Костанай».
+// Упрощённая схема под названия городов; y после гласной → й.
+func translitToCyrillic(s string) string {
+	lower := strings.ToLower(s)
+	var sb strings.Builder
+	prevVowel := false
+	upperNext := true
+	for i := 0; i < len(lower); {
+		if !(lower[i] >= 'a' && lower[i] <= 'z') {
+			sb.WriteByte(s[i])
+			upperNext = lower[i] == ' ' || lower[i] == '-'
+			prevVowel = false
+			i++
+			continue
+		}
+		cyr, n := "", 1
+		for _, d := range translitDigraphs {
+			if strings.HasPrefix(lower[i:], d.lat) {
+				cyr, n = d.cyr, len(d.lat)
+				break
+			}
+		}
+		if cyr == "" {
+			if lower[i] == 'y' && prevVowel {
+				cyr = "й"
+			} else {
+				cyr = translitLetters[rune(lower[i])]
+			}
+		}
+		if upperNext && s[i] >= 'A' && s[i] <= 'Z' {
+			cyr = strings.ToUpper(cyr[:2]) + cyr[2:]
+		}
+		sb.WriteString(cyr)
+		prevVowel = strings.ContainsAny(cyr, "аеиоуыэюяёАЕИОУЫЭЮЯЁ") && cyr != "й"
+		upperNext = false
+		i += n
+	}
+	return sb.String()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// officeTestEngine — движок со встроенными офисами и алиасами, без менеджеров
+func officeTestEngine(tb testing.TB) *Engine {
+	quietStdout(tb) // 🔤 Левенштейн, ✅ Алиасов городов
+	return benchEngine(len(defaultOfficeCoords), 0)
+}
+
+func TestNormalizeOfficeName(t *testing.T) {
+	e := officeTestEngine(t)
+	for _, c := range []struct{ name, office, want string }{
+		{"точное", "Алматы", "Алматы"},
+		{"регистр и пробелы", "  усть-каменогорск ", "Усть-Каменогорск"},
+		{"верхний регистр", "АКТАУ", "Актау"},
+		{"подстрока", "г. Шымкент", "Шымкент"},
+		{"с пояснением", "Астана (ГО)", "Астана"},
+		{"опечатка", "Уст-Каменогорск", "Усть-Каменогорск"},
+		{"опечатка с точкой", "Шимкент.", "Шымкент"},
+		{"две правки", "Павладор", "Павлодар"},
+		{"короткое название", "Тараж", "Тараз"},
+		{"две правки в коротком", "Торас", ""}, // 2 правки из 5 букв — больше четверти
+		{"неизвестный", "Караганда", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if got := e.normalizeOfficeName(c.office); got != c.want {
+				t.Errorf("normalizeOfficeName(%q) = %q, ожидалось %q", c.office, got, c.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeCityName(t *testing.T) {
+	for _, c := range []struct{ city, want string }{
+		{"Семей", "семей"},
+		{"г. Семей", "семей"},
+		{"г.Семей", "семей"},
+		{"г Семей", "семей"},
+		{"Город Алма-Ата", "алма-ата"},
+		{"  Шевченко. ", "шевченко"},
+		{"Красный Яр,", "красный яр"},
+		{"Гурьев;", "гурьев"},
+	} {
+		if got := normalizeCityName(c.city); got != c.want {
+			t.Errorf("normalizeCityName(%q) = %q, ожидалось %q", c.city, got, c.want)
+		}
+	}
+}
+
+func TestLookupCityAlias(t *testing.T) {
+	e := NewEngine(nil, nil, nil)
+	for _, c := range []struct {
+		city, want string
+		ok         bool
+	}{
+		{"Семипалатинск", "Усть-Каменогорск", true},
+		{"г. Нур-Султан", "Астана", true},
+		{"ҚОНАЕВ", "Алматы", true},
+		{"Aktau", "Актау", true},
+		{"Петропавл.", "Петропавловск", true},
+		{"Караганда", "", false},
+		{"", "", false},
+	} {
+		got, ok := e.lookupCityAlias(c.city)
+		if got != c.want || ok != c.ok {
+			t.Errorf("lookupCityAlias(%q) = %q, %v; ожидалось %q, %v", c.city, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+// TestLoadCityAliases — файл дополняет и переопределяет встроенный словарь;
+// офис нормализуется, строки с неизвестным офисом пропускаются
+func TestLoadCityAliases(t *testing.T) {
+	e := officeTestEngine(t)
+	fp := filepath.Join(t.TempDir(), "city_aliases.csv")
+	data := "Город,Офис\n" +
+		"Г. Сарань,караганда\n" +
+		"Темиртау,  астана \n" +
+		"Семей,Семей\n" +
+		"Щучинск,Кокшетау.\n"
+	if err := os.WriteFile(fp, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e.LoadCityAliases(fp)
+
+	for _, c := range []struct {
+		city, want string
+		ok         bool
+	}{
+		{"Темиртау", "Астана", true},
+		{"Семей", "Семей", true}, // встроенный алиас → Усть-Каменогорск переопределён
+		{"Семипалатинск", "Усть-Каменогорск", true},
+		{"Щучинск", "Кокшетау", true},
+		{"Сарань", "", false}, // офиса Караганда нет
+	} {
+		got, ok := e.lookupCityAlias(c.city)
+		if got != c.want || ok != c.ok {
+			t.Errorf("lookupCityAlias(%q) = %q, %v; ожидалось %q, %v", c.city, got, ok, c.want, c.ok)
+		}
+	}
+	if got, _ := NewEngine(nil, nil, nil).lookupCityAlias("Семей"); got != "Усть-Каменогорск" {
+		t.Errorf("алиасы файла попали во встроенный словарь: Семей → %q", got)
+	}
+}
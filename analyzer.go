@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  АНАЛИЗАТОР — LLM батч-анализа (AI_PROVIDER=gemini | ollama)
+// ═══════════════════════════════════════════════════════════
+
+// Analyzer — промпт analyzeBatch → текст ответа (JSON-массив разбирает
+// analyzeBatch). Name — значение AIResult.Source для ответов этой модели.
+// apiKey — ключ из пула (Gemini); локальная модель его игнорирует.
+type Analyzer interface {
+	Name() string
+	Generate(ctx context.Context, apiKey, prompt string) (string, error)
+}
+
+// geminiAnalyzer — Gemini generateContent через geminiGenerate
+type geminiAnalyzer struct{}
+
+func (geminiAnalyzer) Name() string { return "Gemini" }
+
+func (geminiAnalyzer) Generate(ctx context.Context, apiKey, prompt string) (string, error) {
+	return geminiGenerate(ctx, apiKey, prompt)
+}
+
+// ollamaAnalyzer — локальная модель через Ollama /api/generate (без облака и ключей)
+type ollamaAnalyzer struct {
+	baseURL string
+	model   string
+}
+
+func (ollamaAnalyzer) Name() string { return "Ollama" }
+
+func (o ollamaAnalyzer) Generate(ctx context.Context, _, prompt string) (string, error) {
+	body, _ := json.Marshal(map[string]any{
+		"model":   o.model,
+		"prompt":  prompt,
+		"stream":  false,
+		"options": map[string]any{"temperature": 0.05},
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/generate", bytes.NewBuffer(body))
+	if err != nil {
+		return "", fmt.Errorf("HTTP-запрос: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Timeout: aiTimeout}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Ollama %s: %v", o.baseURL, err)
+	}
+	defer resp.Body.Close()
+	respBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Ollama HTTP %d: %.400s", resp.StatusCode, respBytes)
+	}
+
+	var out struct {
+		Response        string `json:"response"`
+		Done            bool   `json:"done"`
+		DoneReason      string `json:"done_reason"`
+		PromptEvalCount int    `json:"prompt_eval_count"`
+		EvalCount       int    `json:"eval_count"`
+	}
+	if err := json.Unmarshal(respBytes, &out); err != nil {
+		return "", fmt.Errorf("парсинг ответа Ollama: %v", err)
+	}
+	aiUsage.Add(tokenUsage{Prompt: out.PromptEvalCount, Candidates: out.EvalCount,
+		Total: out.PromptEvalCount + out.EvalCount})
+	if out.DoneReason == "length" {
+		fmt.Printf("   ✂️ Ответ Ollama обрезан (done_reason=length)\n")
+	}
+	if strings.TrimSpace(out.Response) == "" {
+		return "", fmt.Errorf("пустой ответ Ollama (done_reason=%s)", out.DoneReason)
+	}
+	return out.Response, nil
+}
+
+// analyzer — анализатор запуска (configureAnalyzer), передаётся в NewEngine
+var analyzer Analyzer = geminiAnalyzer{}
+
+// configureAnalyzer — AI_PROVIDER=gemini (по умолчанию) | ollama. ollama —
+// OLLAMA_URL (по умолчанию http://localhost:11434) и OLLAMA_MODEL: прогон без
+// облачного API (офлайн-демо); ключи Gemini не нужны.
+func configureAnalyzer() error {
+	switch provider := strings.ToLower(getEnv("AI_PROVIDER", "gemini")); provider {
+	case "gemini":
+		analyzer = geminiAnalyzer{}
+	case "ollama":
+		o := ollamaAnalyzer{
+			baseURL: strings.TrimRight(getEnv("OLLAMA_URL", "http://localhost:11434"), "/"),
+			model:   getEnv("OLLAMA_MODEL", "qwen2.5:7b"),
+		}
+		analyzer = o
+		fmt.Printf("🦙 Анализатор: Ollama %s, модель %s\n", o.baseURL, o.model)
+	default:
+		return fmt.Errorf("AI_PROVIDER=%q: ожидается gemini или ollama", provider)
+	}
+	return nil
+}
+
+// usesAPIKeys — нужны ли анализатору ключи из пула
+func usesAPIKeys(a Analyzer) bool {
+	_, ok := a.(geminiAnalyzer)
+	return ok
+}
+
+// isLLMSource — ответ модели (Gemini, Ollama), а не Keyword Fallback или
+// спам-фильтр по доменам
+func isLLMSource(source string) bool {
+	return source != "" && source != "Fallback" && source != sourcePrefilter
+}
+
+// pingOllama — доступность Ollama для -check (GET /api/tags)
+func pingOllama(ctx context.Context, o ollamaAnalyzer) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", o.baseURL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
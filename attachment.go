@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ТИП ВЛОЖЕНИЯ — по расширению: image | pdf | document | archive | unknown
+// ═══════════════════════════════════════════════════════════
+
+// Типы вложения (Тип_вложения, attachment_type)
+const (
+	attachmentImage    = "image"
+	attachmentPDF      = "pdf"
+	attachmentDocument = "document"
+	attachmentArchive  = "archive"
+	attachmentUnknown  = "unknown"
+)
+
+// attachmentExtTypes — расширение → тип вложения
+var attachmentExtTypes = map[string]string{
+	".png": attachmentImage, ".jpg": attachmentImage, ".jpeg": attachmentImage,
+	".gif": attachmentImage, ".bmp": attachmentImage, ".webp": attachmentImage,
+	".heic": attachmentImage, ".tif": attachmentImage, ".tiff": attachmentImage,
+	".pdf": attachmentPDF,
+	".doc": attachmentDocument, ".docx": attachmentDocument, ".odt": attachmentDocument,
+	".rtf": attachmentDocument, ".txt": attachmentDocument, ".xls": attachmentDocument,
+	".xlsx": attachmentDocument, ".csv": attachmentDocument,
+	".zip": attachmentArchive, ".rar": attachmentArchive, ".7z": attachmentArchive,
+	".tar": attachmentArchive, ".gz": attachmentArchive,
+}
+
+// attachmentType — тип вложения по имени файла или URL; "" — вложения нет
+func attachmentType(attachment string) string {
+	name := strings.TrimSpace(attachment)
+	if name == "" {
+		return ""
+	}
+	name, _, _ = strings.Cut(name, "?") // URL с параметрами
+	if typ, ok := attachmentExtTypes[strings.ToLower(filepath.Ext(name))]; ok {
+		return typ
+	}
+	return attachmentUnknown
+}
+
+// applyScreenshotHint — Keyword Fallback: скриншот без текста обращения и без
+// других признаков — почти всегда ошибка в приложении (клиент показывает,
+// что видит на экране)
+func applyScreenshotHint(t TicketInput, r AIResult) AIResult {
+	if r.Type != "Консультация" || strings.TrimSpace(t.Text) != "" || attachmentType(t.Attachment) != attachmentImage {
+		return r
+	}
+	r.Type = "Неработоспособность приложения"
+	r.Sentiment = "Негативный"
+	r.Priority = "6"
+	r.Summary = "Скриншот без описания — вероятно, ошибка в приложении. Запросить ОС, версию приложения и шаги воспроизведения."
+	return r
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  БЕНЧМАРКИ — горячий путь роутинга на синтетических данных (go test -bench .)
+// ═══════════════════════════════════════════════════════════
+
+// benchRoles, benchSkills — чередуются у синтетических менеджеров: в пуле есть
+// все должности, VIP у каждого третьего, языки у каждого второго
+var (
+	benchRoles  = []string{"Специалист", "Ведущий специалист", "Главный специалист"}
+	benchSkills = [][]string{{"VIP", "KZ"}, {"ENG"}, {}, {"KZ"}, {"VIP", "ENG"}, {}}
+)
+
+// benchEngine — движок с offices офисами (сначала реальные города, затем
+// синтетические точки по сетке над Казахстаном) и perOffice менеджерами в каждом
+func benchEngine(offices, perOffice int) *Engine {
+	e := NewEngine(nil, nil, nil)
+	cities := make([]string, 0, len(defaultOfficeCoords))
+	for city := range defaultOfficeCoords {
+		cities = append(cities, city)
+	}
+	sort.Strings(cities) // порядок офисов — как при загрузке из файла, без случайности map
+	for i := 0; i < offices; i++ {
+		office := "Офис " + strconv.Itoa(i+1)
+		if i < len(cities) {
+			office = cities[i]
+		} else {
+			e.officeCoords[office] = GeoPoint{40.5 + float64(i%97)*0.13, 50.0 + float64(i%211)*0.16}
+		}
+		e.offices = append(e.offices, office)
+		for m := 0; m < perOffice; m++ {
+			e.managers[office] = append(e.managers[office], &Manager{
+				Name:     office + " / " + strconv.Itoa(m+1),
+				Role:     benchRoles[m%len(benchRoles)],
+				Office:   office,
+				Skills:   benchSkills[m%len(benchSkills)],
+				Workload: m % 7,
+			})
+		}
+	}
+	return e
+}
+
+// BenchmarkFindBestManager — по размеру пула и набору фильтров
+func BenchmarkFindBestManager(b *testing.B) {
+	filters := []struct {
+		name    string
+		segment string
+		ai      AIResult
+	}{
+		{"без фильтров", "Mass", AIResult{Type: "Консультация", Language: "RU"}},
+		{"VIP+KZ", "VIP", AIResult{Type: "Жалоба", Language: "KZ"}},
+		{"Смена данных+ENG/KZ", "Mass", AIResult{Type: "Смена данных", Language: "ENG", AltLanguage: "KZ"}},
+	}
+	quietStdout(b)
+	for _, size := range []int{5, 50, 500} {
+		e := benchEngine(1, size)
+		office := e.offices[0]
+		pool := e.managers[office]
+		for _, f := range filters {
+			b.Run(fmt.Sprintf("пул=%d/%s", size, f.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					e.findBestManager(pool, f.segment, f.ai, office)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkHaversine(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		haversine(43.2220, 76.8512, 51.1801, 71.4598)
+	}
+}
+
+// BenchmarkFindNearestOfficeByCoords — по числу офисов
+func BenchmarkFindNearestOfficeByCoords(b *testing.B) {
+	quietStdout(b)
+	for _, n := range []int{15, 100, 1000} {
+		e := benchEngine(n, 0)
+		b.Run(fmt.Sprintf("офисов=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				e.findNearestOfficeByCoords(49.8047, 73.1094) // Караганда — своего офиса нет
+			}
+		})
+	}
+}
+
+// BenchmarkNormalizeOfficeName — по виду совпадения
+func BenchmarkNormalizeOfficeName(b *testing.B) {
+	quietStdout(b)
+	e := benchEngine(len(defaultOfficeCoords), 0)
+	for _, c := range []struct{ name, office string }{
+		{"точное", "Алматы"},
+		{"регистр", "  усть-каменогорск "},
+		{"подстрока", "г. Шымкент"},
+		{"опечатка", "Уст-Каменогорск"},
+		{"неизвестный", "Караганда"},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				e.normalizeOfficeName(c.office)
+			}
+		})
+	}
+}
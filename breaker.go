@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  CIRCUIT BREAKER — Gemini недоступен → сразу Keyword Fallback
+// ═══════════════════════════════════════════════════════════
+
+// errBreakerOpen — запрос к AI не отправлялся: breaker разомкнут
+var errBreakerOpen = errors.New("circuit breaker AI разомкнут")
+
+const (
+	breakerClosed   = "closed"    // запросы идут как обычно
+	breakerOpen     = "open"      // запросы не отправляются до конца cooldown
+	breakerHalfOpen = "half-open" // один пробный запрос проверяет восстановление
+)
+
+// circuitBreaker — после threshold неудачных запросов подряд размыкается
+// на cooldown; затем пропускает один пробный запрос (half-open): успех замыкает,
+// ошибка снова размыкает. 429 и блокировки контента неудачей не считаются.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     string
+	failures  int
+	threshold int
+	cooldown  time.Duration
+	openedAt  time.Time
+	trial     bool // пробный запрос half-open уже в полёте
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{state: breakerClosed, threshold: threshold, cooldown: cooldown}
+}
+
+// aiBreaker — общий breaker для всех запросов к AI (AI_BREAKER_THRESHOLD, AI_BREAKER_COOLDOWN)
+var aiBreaker = newCircuitBreaker(5, 2*time.Minute)
+
+func (b *circuitBreaker) setState(state string) {
+	if b.state == state {
+		return
+	}
+	fmt.Printf("🔌 Circuit breaker AI: %s → %s\n", b.state, state)
+	b.state = state
+}
+
+// Allow — можно ли отправить запрос сейчас
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.trial = true
+		return true
+	case breakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// Success — запрос прошёл: сброс счётчика, half-open → closed
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+	b.setState(breakerClosed)
+}
+
+// Failure — запрос упал: в half-open сразу размыкаемся, в closed — по порогу
+func (b *circuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		if b.state != breakerOpen {
+			fmt.Printf("🔌 %d ошибок AI подряд — дальше Keyword Fallback без запросов, проверка через %v\n",
+				b.failures, b.cooldown)
+		}
+		b.setState(breakerOpen)
+	}
+}
+
+// Release — запрос завершился без вердикта о здоровье AI (429, блокировка, отмена)
+func (b *circuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
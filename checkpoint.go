@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ЧЕКПОИНТ AI — data/ai_checkpoint.json (тенант|GUID → ответ Gemini)
+// ═══════════════════════════════════════════════════════════
+
+// aiCheckpointPath — ответы AI, сохранённые сразу после AI-фазы. Если процесс
+// упадёт на геокодировании или роутинге, следующий запуск не платит за
+// повторный анализ: тикеты из чекпоинта в Gemini не отправляются.
+const aiCheckpointPath = "data/ai_checkpoint.json"
+
+// aiCheckpoint — tenantKey → ответ AI с хэшем содержимого тикета
+type aiCheckpoint map[string]aiCheckpointEntry
+
+// aiCheckpointEntry — ответ AI и ticketContentHash тикета на момент анализа:
+// тикет, изменённый во входном файле после падения, анализируется заново
+type aiCheckpointEntry struct {
+	AI   AIResult `json:"ai"`
+	Hash string   `json:"content_hash"`
+}
+
+// Get — ответ AI для тикета, если он есть и содержимое тикета не менялось
+func (cp aiCheckpoint) Get(t TicketInput) (AIResult, bool) {
+	entry, ok := cp[tenantKey(t.Tenant, t.GUID)]
+	if !ok || entry.Hash != ticketContentHash(t) {
+		return AIResult{}, false
+	}
+	return entry.AI, true
+}
+
+// Put — ответ AI для тикета с хэшем его текущего содержимого
+func (cp aiCheckpoint) Put(t TicketInput, r AIResult) {
+	cp[tenantKey(t.Tenant, t.GUID)] = aiCheckpointEntry{AI: r, Hash: ticketContentHash(t)}
+}
+
+// Delete — убрать тикет из чекпоинта
+func (cp aiCheckpoint) Delete(t TicketInput) {
+	delete(cp, tenantKey(t.Tenant, t.GUID))
+}
+
+// loadAICheckpoint — чекпоинт прошлого запуска (ключ — tenantKey) без тикетов,
+// уже записанных в results.csv или БД (processed — как отсев processedGUIDs).
+// Нет файла — пустой чекпоинт; записи старого формата (GUID без тенанта, без
+// хэша) отбрасываются — такие тикеты снова пойдут в AI.
+func loadAICheckpoint(path string, processed func(tenant, guid string) bool) aiCheckpoint {
+	cp := make(aiCheckpoint)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cp
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		fmt.Printf("⚠️ Чекпоинт %s повреждён, AI-анализ с нуля: %v\n", path, err)
+		return make(aiCheckpoint)
+	}
+	for key, entry := range cp {
+		if tenant, guid, ok := strings.Cut(key, "|"); !ok || entry.Hash == "" || processed(tenant, guid) {
+			delete(cp, key)
+		}
+	}
+	if len(cp) > 0 {
+		fmt.Printf("♻️  Чекпоинт AI: %d тикетов уже проанализированы → %s\n", len(cp), path)
+	}
+	return cp
+}
+
+// saveAICheckpoint — атомарная запись (tmp + rename): падение посреди записи
+// не портит прежний чекпоинт. Сохраняются только ответы Gemini — Keyword
+// Fallback дёшев, и при повторном запуске такие тикеты снова пойдут в AI.
+func saveAICheckpoint(path string, cp aiCheckpoint) error {
+	gemini := make(aiCheckpoint, len(cp))
+	for key, entry := range cp {
+		if isLLMSource(entry.AI.Source) {
+			gemini[key] = entry
+		}
+	}
+	data, err := json.Marshal(gemini)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		os.MkdirAll(dir, 0755)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestAICheckpointTenants — один GUID у разных тенантов — разные записи;
+// отсев обработанных — по тенанту, ключи без тенанта (старый формат) отбрасываются
+func TestAICheckpointTenants(t *testing.T) {
+	quietStdout(t)
+	path := filepath.Join(t.TempDir(), "ai_checkpoint.json")
+	cp := make(aiCheckpoint)
+	cp.Put(TicketInput{GUID: "g1", Text: "a"}, AIResult{Type: "Жалоба", Source: "Gemini"})
+	cp.Put(TicketInput{GUID: "g1", Text: "a", Tenant: "acme"}, AIResult{Type: "Претензия", Source: "Gemini"})
+	cp.Put(TicketInput{GUID: "g2", Text: "b", Tenant: "acme"}, AIResult{Type: "Консультация", Source: "Fallback"})
+	cp["g3"] = aiCheckpointEntry{AI: AIResult{Type: "Консультация", Source: "Gemini"}, Hash: "x"}
+	if err := saveAICheckpoint(path, cp); err != nil {
+		t.Fatal(err)
+	}
+
+	got := loadAICheckpoint(path, func(tenant, guid string) bool {
+		return tenant == defaultTenant && guid == "g1"
+	})
+	if len(got) != 1 {
+		t.Errorf("чекпоинт: %v, ожидался только acme|g1", got)
+	}
+	if r, ok := got.Get(TicketInput{GUID: "g1", Text: "a", Tenant: "acme"}); !ok || r.Type != "Претензия" {
+		t.Errorf("acme|g1: %v, %v", r, ok)
+	}
+}
+
+// TestAICheckpointChangedContent — ответ по старому тексту тикета не переиспользуется
+func TestAICheckpointChangedContent(t *testing.T) {
+	quietStdout(t)
+	path := filepath.Join(t.TempDir(), "ai_checkpoint.json")
+	ticket := TicketInput{GUID: "g1", Text: "Не могу войти", Segment: "Mass"}
+	cp := make(aiCheckpoint)
+	cp.Put(ticket, AIResult{Type: "Неработоспособность приложения", Source: "Gemini"})
+	if err := saveAICheckpoint(path, cp); err != nil {
+		t.Fatal(err)
+	}
+
+	got := loadAICheckpoint(path, func(string, string) bool { return false })
+	if _, ok := got.Get(ticket); !ok {
+		t.Error("ответ для неизменённого тикета не найден")
+	}
+	changed := ticket
+	changed.Text = "Украли деньги со счёта"
+	if r, ok := got.Get(changed); ok {
+		t.Errorf("для изменённого тикета взят старый ответ: %v", r)
+	}
+}
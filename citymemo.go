@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ПАМЯТЬ ГОРОД → ОФИС — один геокод на город за проход (-city-memo)
+// ═══════════════════════════════════════════════════════════
+
+// cityMemo — город (страна, область, населённый пункт) → офис первого тикета
+// этого города, найденный Nominatim+Haversine. Остальные тикеты города берут
+// офис отсюда без своего запроса: улица и дом не учитываются, поэтому флаг
+// выключен по умолчанию (крупный город может делиться между офисами).
+type cityMemo struct {
+	mu      sync.Mutex
+	entries map[string]*cityMemoEntry
+	hits    int
+}
+
+// cityMemoEntry — результат первого тикета города; done закрывается после
+// его геокодирования, ok — офис найден по координатам (иначе каждый сам)
+type cityMemoEntry struct {
+	done     chan struct{}
+	ok       bool
+	office   string
+	oblast   string
+	lat, lon float64
+}
+
+func newCityMemo() *cityMemo {
+	return &cityMemo{entries: make(map[string]*cityMemoEntry)}
+}
+
+// cityMemoKey — ключ города без учёта регистра и пробелов; "" — города нет
+func cityMemoKey(t TicketInput) string {
+	city := strings.ToLower(strings.TrimSpace(t.RawCity))
+	if city == "" {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(t.Country)) + "|" +
+		strings.ToLower(strings.TrimSpace(t.Oblast)) + "|" + city
+}
+
+// claim — запись города тикета. leader=true — тикет первый в городе: он
+// геокодирует сам и обязан вызвать finish; остальные ждут его (wait).
+// nil — памяти нет (флаг выключен) или у тикета нет города.
+func (m *cityMemo) claim(t TicketInput) (entry *cityMemoEntry, leader bool) {
+	key := cityMemoKey(t)
+	if m == nil || key == "" {
+		return nil, true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.entries[key]; ok {
+		return entry, false
+	}
+	entry = &cityMemoEntry{done: make(chan struct{})}
+	m.entries[key] = entry
+	return entry, true
+}
+
+// finish — результат первого тикета города; в память попадает только офис,
+// найденный по координатам (алиас, LLM, 50/50 и «слишком далеко» — нет)
+func (c *cityMemoEntry) finish(office string, lat, lon float64, method, oblast string) {
+	if method == "nominatim" && office != "" {
+		c.ok, c.office, c.oblast, c.lat, c.lon = true, office, oblast, lat, lon
+	}
+	close(c.done)
+}
+
+// wait — дождаться первого тикета города; false — офиса в памяти нет
+// (или запуск отменён), тикет геокодируется сам
+func (c *cityMemoEntry) wait(ctx context.Context) bool {
+	select {
+	case <-c.done:
+		return c.ok
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// hit — учесть тикет, обслуженный из памяти
+func (m *cityMemo) hit() {
+	m.mu.Lock()
+	m.hits++
+	m.mu.Unlock()
+	timings.AddCityMemoHit()
+}
+
+// Hits — тикетов, обслуженных из памяти за проход
+func (m *cityMemo) Hits() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hits
+}
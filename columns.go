@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  КОЛОНКИ tickets.csv — поиск по заголовку, а не по позиции
+// ═══════════════════════════════════════════════════════════
+
+// ticketColumn — поле TicketInput и допустимые названия колонки в заголовке
+type ticketColumn struct {
+	Field    string
+	Aliases  []string
+	Required bool
+}
+
+// ticketColumns — стартовый список названий; дополняется data/ticket_columns.csv (Поле,Колонка)
+var ticketColumns = []*ticketColumn{
+	{Field: "guid", Aliases: []string{"GUID клиента", "GUID", "guid"}, Required: true},
+	{Field: "gender", Aliases: []string{"Пол клиента", "Пол", "gender"}},
+	{Field: "birthdate", Aliases: []string{"Дата рождения", "birthdate"}},
+	{Field: "text", Aliases: []string{"Описание", "Текст обращения", "Текст", "text", "description"}, Required: true},
+	{Field: "attachment", Aliases: []string{"Вложения", "Вложение", "attachment"}},
+	{Field: "segment", Aliases: []string{"Сегмент клиента", "Сегмент", "segment"}, Required: true},
+	{Field: "country", Aliases: []string{"Страна", "country"}, Required: true},
+	{Field: "oblast", Aliases: []string{"Область", "Регион", "oblast", "region"}, Required: true},
+	{Field: "city", Aliases: []string{"Населённый пункт", "Город", "city"}, Required: true},
+	{Field: "street", Aliases: []string{"Улица", "street"}},
+	{Field: "house", Aliases: []string{"Дом", "house"}},
+	{Field: "tenant", Aliases: []string{"Тенант", "tenant_id", "tenant"}},
+	{Field: "created_at", Aliases: []string{"Дата обращения", "Дата создания", "created_at"}},
+}
+
+// normalizeColumnName — для сравнения заголовков: без BOM/пробелов, регистра и «ё»
+func normalizeColumnName(s string) string {
+	s = strings.ToLower(normalizeField(s))
+	return strings.ReplaceAll(s, "ё", "е")
+}
+
+// columnIndex — индексы колонок по полям; отсутствующее поле → -1
+type columnIndex map[string]int
+
+// Get — значение поля в строке ("" для отсутствующей колонки или короткой строки)
+func (c columnIndex) Get(row []string, field string) string {
+	idx, ok := c[field]
+	if !ok || idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return row[idx]
+}
+
+// Ticket — тикет из строки входного файла (Index заполняет вызывающий)
+func (c columnIndex) Ticket(row []string) TicketInput {
+	return TicketInput{
+		GUID:       c.Get(row, "guid"),
+		Gender:     c.Get(row, "gender"),
+		Birthdate:  c.Get(row, "birthdate"),
+		Text:       c.Get(row, "text"),
+		Attachment: c.Get(row, "attachment"),
+		Segment:    c.Get(row, "segment"),
+		Country:    c.Get(row, "country"),
+		Oblast:     c.Get(row, "oblast"),
+		RawCity:    c.Get(row, "city"),
+		Street:     c.Get(row, "street"),
+		House:      c.Get(row, "house"),
+		Tenant:     c.Get(row, "tenant"),
+		CreatedAt:  c.Get(row, "created_at"),
+	}
+}
+
+// mapTicketColumns — сопоставляет заголовок с ticketColumns.
+// Ошибка перечисляет все обязательные колонки, которых нет в заголовке.
+func mapTicketColumns(header []string) (columnIndex, error) {
+	positions := make(map[string]int, len(header))
+	for i, h := range header {
+		if _, seen := positions[normalizeColumnName(h)]; !seen {
+			positions[normalizeColumnName(h)] = i
+		}
+	}
+
+	idx := make(columnIndex, len(ticketColumns))
+	var missing []string
+	for _, col := range ticketColumns {
+		idx[col.Field] = -1
+		for _, alias := range col.Aliases {
+			if i, ok := positions[normalizeColumnName(alias)]; ok {
+				idx[col.Field] = i
+				break
+			}
+		}
+		if idx[col.Field] < 0 && col.Required {
+			missing = append(missing, fmt.Sprintf("%s (%s)", col.Field, strings.Join(col.Aliases, " | ")))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("нет обязательных колонок: %s", strings.Join(missing, "; "))
+	}
+	return idx, nil
+}
+
+// loadTicketColumnAliases — дополнительные названия колонок из CSV (Поле,Колонка).
+// Файл необязателен; добавленные названия проверяются раньше встроенных.
+func loadTicketColumnAliases(fp string) {
+	file, err := os.Open(fp)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	records, err := readCSV(file)
+	if err != nil {
+		fmt.Printf("⚠️ Ошибка чтения %s: %v\n", fp, err)
+		return
+	}
+	byField := make(map[string]*ticketColumn, len(ticketColumns))
+	for _, col := range ticketColumns {
+		byField[col.Field] = col
+	}
+	added := 0
+	for i, row := range records {
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+		field := strings.ToLower(row[0])
+		col, ok := byField[field]
+		if !ok {
+			fmt.Printf("⚠️ %s: неизвестное поле '%s' — пропущено\n", fp, row[0])
+			continue
+		}
+		col.Aliases = append([]string{row[1]}, col.Aliases...)
+		added++
+	}
+	fmt.Printf("✅ Названий колонок tickets.csv из %s: %d\n", fp, added)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestReadCSVHeaderBOM — BOM и zero-width не только в начале файла: Excel и
+// склейка выгрузок оставляют их в середине заголовка и в ячейках
+func TestReadCSVHeaderBOM(t *testing.T) {
+	input := "\uFEFFGUID клиента,Пол клиента,Дата рождения,\uFEFFОписание ,Вложения," +
+		"Сегмент клиента\u200B,Страна,\uFEFFОбласть,Населённый пункт,Улица,Дом\n" +
+		"g-1,Мужской,1985-03-12,Текст обращения,,\uFEFFVIP,Казахстан,Алматинская,\u200BАлматы ,Абая,10\n"
+
+	records, err := readCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("строк %d, ожидалось 2", len(records))
+	}
+	for i, h := range records[0] {
+		if h != normalizeField(h) || strings.ContainsRune(h, '\uFEFF') {
+			t.Errorf("колонка %d: заголовок %q не нормализован", i, h)
+		}
+	}
+
+	cols, err := mapTicketColumns(records[0])
+	if err != nil {
+		t.Fatalf("mapTicketColumns: %v", err)
+	}
+	for field, want := range map[string]int{"guid": 0, "text": 3, "segment": 5, "oblast": 7, "city": 8, "tenant": -1} {
+		if cols[field] != want {
+			t.Errorf("поле %s: колонка %d, ожидалась %d", field, cols[field], want)
+		}
+	}
+
+	got := cols.Ticket(records[1])
+	want := TicketInput{
+		GUID: "g-1", Gender: "Мужской", Birthdate: "1985-03-12", Text: "Текст обращения",
+		Segment: "VIP", Country: "Казахстан", Oblast: "Алматинская", RawCity: "Алматы",
+		Street: "Абая", House: "10",
+	}
+	if got != want {
+		t.Errorf("тикет:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+// TestMapTicketColumnsMissing — все отсутствующие обязательные колонки в одной ошибке
+func TestMapTicketColumnsMissing(t *testing.T) {
+	_, err := mapTicketColumns([]string{"\uFEFFGUID", "Описание", "Сегмент"})
+	if err == nil {
+		t.Fatal("ошибки нет, хотя страны, области и города в заголовке нет")
+	}
+	for _, field := range []string{"country", "oblast", "city"} {
+		if !strings.Contains(err.Error(), field+" (") {
+			t.Errorf("в ошибке нет поля %s: %v", field, err)
+		}
+	}
+	if strings.Contains(err.Error(), "guid (") {
+		t.Errorf("guid с BOM не распознан: %v", err)
+	}
+}
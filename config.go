@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  CONFIG.YAML — настройки одним файлом (флаг -config)
+// ═══════════════════════════════════════════════════════════
+
+// defaultConfigPath — читается, если есть; -config задаёт другой путь
+const defaultConfigPath = "config.yaml"
+
+// fileConfig — config.yaml. Приоритет: флаги > окружение (.env) > файл > умолчания.
+//
+//	paths:
+//	  tickets: data/tickets.csv
+//	  managers: data/managers.xlsx
+//	flags:
+//	  sorted: true
+//	  db-batch: 500
+//	env:
+//	  GEMINI_API_KEYS: k1,k2
+//	  AI_MODEL: gemini-2.5-flash
+//	  DB_HOST: localhost
+//	  NOMINATIM_URL: http://nominatim.local:8080
+//	  MAX_OFFICE_DISTANCE_KM: 400
+type fileConfig struct {
+	Paths struct {
+		Tickets        string `yaml:"tickets"`
+		BusinessUnits  string `yaml:"business_units"`
+		Managers       string `yaml:"managers"`
+		CityAliases    string `yaml:"city_aliases"`
+		PriorityMatrix string `yaml:"priority_matrix"`
+		SegmentRules   string `yaml:"segment_rules"`
+		RoleRules      string `yaml:"role_rules"`
+		Overrides      string `yaml:"overrides"`
+		TicketColumns  string `yaml:"ticket_columns"`
+		Tenants        string `yaml:"tenants"`
+		Profanity      string `yaml:"profanity"`
+	} `yaml:"paths"`
+	Flags map[string]any `yaml:"flags"` // имя флага без «-» → значение
+	Env   map[string]any `yaml:"env"`   // любая переменная окружения движка
+}
+
+// loadFileConfig — читает config.yaml и применяет его там, где значение не
+// задано выше по приоритету: переменные — только отсутствующие в окружении,
+// флаги — только не указанные в командной строке. Вызывать после flag.Parse
+// и godotenv.Load. explicit=false — файла может не быть.
+func loadFileConfig(path string, explicit bool) (*fileConfig, error) {
+	cfg := &fileConfig{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	envSet := 0
+	for key, v := range cfg.Env {
+		if strings.TrimSpace(os.Getenv(key)) != "" {
+			continue // как и getEnv: пустая переменная считается незаданной
+		}
+		os.Setenv(key, fmt.Sprint(v))
+		envSet++
+	}
+
+	setOnCLI := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { setOnCLI[f.Name] = true })
+	flagsSet := 0
+	for name, v := range cfg.Flags {
+		if name == "config" || setOnCLI[name] {
+			continue
+		}
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("%s: неизвестный флаг '%s'", path, name)
+		}
+		if err := flag.Set(name, fmt.Sprint(v)); err != nil {
+			return nil, fmt.Errorf("%s: флаг '%s': %v", path, name, err)
+		}
+		flagsSet++
+	}
+	redirectStdout() // -out - из файла: и эта строка уже не в CSV
+	fmt.Printf("⚙️  Конфигурация %s: переменных %d, флагов %d\n", path, envSet, flagsSet)
+	return cfg, nil
+}
+
+// applyPaths — пути из paths: заменяют найденные findFile
+func (c *fileConfig) applyPaths(p *appPaths) {
+	for _, o := range []struct {
+		dst *string
+		src string
+	}{
+		{&p.Tickets, c.Paths.Tickets},
+		{&p.Offices, c.Paths.BusinessUnits},
+		{&p.Managers, c.Paths.Managers},
+		{&p.CityAliases, c.Paths.CityAliases},
+		{&p.PriorityMatrix, c.Paths.PriorityMatrix},
+		{&p.SegmentRules, c.Paths.SegmentRules},
+		{&p.RoleRules, c.Paths.RoleRules},
+		{&p.Overrides, c.Paths.Overrides},
+		{&p.TicketColumns, c.Paths.TicketColumns},
+		{&p.Tenants, c.Paths.Tenants},
+		{&p.Profanity, c.Paths.Profanity},
+	} {
+		if o.src != "" {
+			*o.dst = o.src
+		}
+	}
+}
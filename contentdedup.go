@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/md5"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ДЕДУПЛИКАЦИЯ ПО СОДЕРЖИМОМУ — -dedup-content: одна рассылка под многими GUID
+// ═══════════════════════════════════════════════════════════
+
+// contentKey — md5 нормализованного текста (регистр, пунктуация и пробелы не
+// важны) вместе с вложением и тенантом: у тенантов разные офисы в промпте
+func contentKey(t TicketInput) [md5.Size]byte {
+	norm := strings.FieldsFunc(strings.ToLower(t.Text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return md5.Sum([]byte(strings.Join(norm, " ") + "\x1f" + strings.TrimSpace(t.Attachment) + "\x1f" + tenantID(t.Tenant)))
+}
+
+// dedupByContent — по одному представителю на группу тикетов с одинаковым
+// содержимым (первый по порядку) и копии: Index копии → Index представителя
+func dedupByContent(tickets []TicketInput) ([]TicketInput, map[int]int) {
+	first := make(map[[md5.Size]byte]int, len(tickets))
+	copies := make(map[int]int)
+	var reps []TicketInput
+	for _, t := range tickets {
+		key := contentKey(t)
+		if rep, ok := first[key]; ok {
+			copies[t.Index] = rep
+			continue
+		}
+		first[key] = t.Index
+		reps = append(reps, t)
+	}
+	if len(copies) > 0 {
+		fmt.Printf("♊ Одинаковое содержимое: %d тикетов получат анализ представителя, в AI уходит %d из %d\n",
+			len(copies), len(reps), len(tickets))
+		aiUsage.AddDeduplicated(len(copies))
+	}
+	return reps, copies
+}
+
+// copyDuplicateResults — AIResult представителя копиям. Геокодирование и
+// роутинг у копий свои; офис от LLM-геолокации относится к адресу
+// представителя и не копируется. Представитель без результата — копия тоже
+// без результата (дальше — Keyword Fallback).
+func copyDuplicateResults(results map[int]AIResult, copies map[int]int) {
+	for idx, rep := range copies {
+		if r, ok := results[rep]; ok {
+			r.NearestOffice = ""
+			results[idx] = r
+		}
+	}
+}
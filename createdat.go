@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ДАТА ОБРАЩЕНИЯ — необязательная колонка created_at в tickets.csv
+// ═══════════════════════════════════════════════════════════
+
+// createdAtLayouts — форматы даты обращения: RFC 3339 и форматы выгрузок.
+// Без часового пояса время считается местным.
+var createdAtLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006",
+}
+
+// createdAtMaxSkew — допустимое опережение часов источника тикетов
+const createdAtMaxSkew = time.Hour
+
+// parseCreatedAt — дата обращения в любом из createdAtLayouts
+func parseCreatedAt(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range createdAtLayouts {
+		if d, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неизвестный формат даты '%s'", s)
+}
+
+// fillCreatedAt — заполняет t.CreatedTime по CreatedAt. Пустое значение — не
+// ошибка (в БД останется NOW()), нераспознанное или из будущего — ошибка.
+func fillCreatedAt(t *TicketInput) error {
+	if strings.TrimSpace(t.CreatedAt) == "" {
+		return nil
+	}
+	d, err := parseCreatedAt(t.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if d.After(time.Now().Add(createdAtMaxSkew)) {
+		return fmt.Errorf("дата '%s' в будущем", t.CreatedAt)
+	}
+	t.CreatedTime = d
+	return nil
+}
+
+// createdAtToDB — tickets.created_at: дата обращения или DEFAULT (NOW())
+func createdAtToDB(t TicketInput) any {
+	if t.CreatedTime.IsZero() {
+		return sqlDefault{}
+	}
+	return t.CreatedTime
+}
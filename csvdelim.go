@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  РАЗДЕЛИТЕЛЬ CSV — запятая, точка с запятой (европейский Excel) или tab
+// ═══════════════════════════════════════════════════════════
+
+// csvDelimiterOverride — разделитель из -csv-delim; ok=false, если флаг не задан
+func csvDelimiterOverride() (rune, bool) {
+	switch strings.ToLower(*csvDelim) {
+	case "":
+		return 0, false
+	case ";", "semicolon":
+		return ';', true
+	case "\\t", "\t", "tab":
+		return '\t', true
+	default:
+		if r := []rune(*csvDelim); len(r) == 1 {
+			return r[0], true
+		}
+		return ',', true
+	}
+}
+
+// sniffDelimiter — по строке заголовка: какой из , ; \t встречается чаще вне кавычек
+func sniffDelimiter(header string) rune {
+	counts := map[rune]int{}
+	inQuotes := false
+	for _, r := range header {
+		switch r {
+		case '"':
+			inQuotes = !inQuotes
+		case ',', ';', '\t':
+			if !inQuotes {
+				counts[r]++
+			}
+		}
+	}
+	best := ','
+	for _, d := range []rune{';', '\t'} {
+		if counts[d] > counts[best] {
+			best = d
+		}
+	}
+	return best
+}
+
+// newCSVReader — csv.Reader с разделителем из -csv-delim или определённым по заголовку.
+// Число полей в строках не проверяется: в results.csv прошлых версий старые строки
+// короче новых (колонки дописываются в конец), отсутствующие читаются как "".
+func newCSVReader(r io.Reader) *csv.Reader {
+	if d, ok := csvDelimiterOverride(); ok {
+		reader := csv.NewReader(r)
+		reader.Comma = d
+		reader.FieldsPerRecord = -1
+		return reader
+	}
+	br := bufio.NewReader(r)
+	header, _ := br.ReadString('\n')
+	reader := csv.NewReader(io.MultiReader(strings.NewReader(header), br))
+	reader.Comma = sniffDelimiter(header)
+	reader.FieldsPerRecord = -1
+	if reader.Comma != ',' {
+		fmt.Printf("🔎 CSV: разделитель %q определён по заголовку\n", reader.Comma)
+	}
+	return reader
+}
+
+// newCSVWriter — выходные CSV пишутся с разделителем -csv-delim (по умолчанию запятая)
+func newCSVWriter(w io.Writer) *csv.Writer {
+	writer := csv.NewWriter(w)
+	if d, ok := csvDelimiterOverride(); ok {
+		writer.Comma = d
+	}
+	return writer
+}
+
+// ═══════════════════════════════════════════════════════════
+//  НОРМАЛИЗАЦИЯ ПОЛЕЙ — BOM, пробелы, zero-width по краям
+// ═══════════════════════════════════════════════════════════
+
+// isInvisibleRune — BOM и zero-width символы, которые Excel и мессенджеры
+// оставляют в ячейках; на экране не видны, но ломают сравнение строк
+func isInvisibleRune(r rune) bool {
+	switch r {
+	case '\uFEFF', '\u200B', '\u200C', '\u200D', '\u2060':
+		return true
+	}
+	return unicode.IsSpace(r)
+}
+
+// normalizeField — единая очистка значения ячейки: BOM убирается везде,
+// пробелы и zero-width — по краям
+func normalizeField(s string) string {
+	return strings.TrimFunc(strings.ReplaceAll(s, "\uFEFF", ""), isInvisibleRune)
+}
+
+// readCSV — все записи входного CSV с нормализованными полями
+func readCSV(r io.Reader) ([][]string, error) {
+	records, err := newCSVReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range records {
+		for i := range row {
+			row[i] = normalizeField(row[i])
+		}
+	}
+	return records, nil
+}
+
+// ═══════════════════════════════════════════════════════════
+//  XLSX — первый лист читается так же, как CSV
+// ═══════════════════════════════════════════════════════════
+
+// stdinPath — путь-заглушка: читать CSV из стандартного ввода (-stdin)
+const stdinPath = "-"
+
+// readTable — записи из .csv или .xlsx (по расширению пути) или stdin, поля нормализованы
+func readTable(path string) ([][]string, error) {
+	if path == stdinPath {
+		return readCSV(os.Stdin)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".xlsx") {
+		return readXLSX(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readCSV(file)
+}
+
+// readXLSX — строки первого листа. excelize обрезает пустые ячейки в конце строки,
+// поэтому строки дополняются до ширины заголовка — как в CSV.
+func readXLSX(path string) ([][]string, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("в %s нет листов", path)
+	}
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, err
+	}
+	width := 0
+	if len(rows) > 0 {
+		width = len(rows[0])
+	}
+	for i, row := range rows {
+		for j := range row {
+			row[j] = normalizeField(row[j])
+		}
+		for len(row) < width {
+			row = append(row, "")
+		}
+		rows[i] = row
+	}
+	fmt.Printf("📗 XLSX: %s, лист '%s' — %d строк\n", path, sheets[0], len(rows))
+	return rows, nil
+}
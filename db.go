@@ -0,0 +1,621 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  POSTGRESQL — tickets → ai_analysis → routing_results (1:1:1)
+// ═══════════════════════════════════════════════════════════
+
+var (
+	dbWg sync.WaitGroup // асинхронные сохранения тикетов
+
+	// dbSaveSem — сколько фоновых сохранений (тикетов или пачек) пишут в БД
+	// одновременно (DB_SAVE_WORKERS, по умолчанию 10). Остальные ждут слот,
+	// не занимая соединений; CSV при этом пишется дальше.
+	dbSaveSem = make(chan struct{}, 10)
+)
+
+// dbSaveTimeout — предел на сохранение одного тикета (в т.ч. при остановке)
+const dbSaveTimeout = 10 * time.Second
+
+// initDB — подключение к PostgreSQL по тем же DB_* переменным, что и у Django.
+// БД опциональна: без DB_HOST/DB_NAME — nil, движок пишет только results.csv.
+// Подключение передаётся движку (Engine.SetDB); глобального соединения нет.
+func initDB() (*sql.DB, error) {
+	if !dbConfigured() {
+		return nil, nil
+	}
+	conn, err := sql.Open("postgres", dbDSN())
+	if err != nil {
+		return nil, fmt.Errorf("открытие БД: %v", err)
+	}
+	configurePool(conn)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("подключение к БД: %v", err)
+	}
+	if err := migrateSchema(context.Background(), conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("миграции схемы: %v", err)
+	}
+	fmt.Printf("✅ PostgreSQL подключён: %s\n", getEnv("DB_NAME", "fire_db"))
+	return conn, nil
+}
+
+// dbConfigured — заданы ли DB_HOST или DB_NAME (иначе работаем только с CSV)
+func dbConfigured() bool {
+	return os.Getenv("DB_HOST") != "" || os.Getenv("DB_NAME") != ""
+}
+
+// dbDSN — строка подключения из DB_* (значения по умолчанию — как у Django)
+func dbDSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		getEnv("DB_HOST", "localhost"),
+		getEnv("DB_PORT", "5433"),
+		getEnv("DB_USER", "postgres"),
+		getEnv("DB_PASS", ""),
+		getEnv("DB_NAME", "fire_db"),
+		getEnv("DB_SSLMODE", "disable"),
+	)
+}
+
+// pingDB — проверка доступности БД без создания схемы (для -check)
+func pingDB(ctx context.Context) error {
+	conn, err := sql.Open("postgres", dbDSN())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return conn.PingContext(ctx)
+}
+
+// configurePool — лимиты пула соединений из DB_MAX_OPEN / DB_MAX_IDLE / DB_CONN_MAX_LIFETIME.
+//
+// Каждый тикет сохраняется отдельной горутиной (saveAllAsync, dbWg), и без
+// лимита их сотни одновременно открывают соединения → "too many connections".
+// С лимитом лишние горутины ждут свободное соединение внутри database/sql;
+// ожидание входит в dbSaveTimeout, поэтому для очень больших запусков
+// лучше -db-batch (одно соединение на пачку). Число одновременно пишущих
+// горутин ограничивает dbSaveSem (DB_SAVE_WORKERS).
+func configurePool(conn *sql.DB) {
+	maxOpen, maxIdle := 10, 5
+	if n, err := strconv.Atoi(getEnv("DB_MAX_OPEN", "")); err == nil && n > 0 {
+		maxOpen = n
+	}
+	if n, err := strconv.Atoi(getEnv("DB_MAX_IDLE", "")); err == nil && n >= 0 {
+		maxIdle = n
+	}
+	if maxIdle > maxOpen {
+		maxIdle = maxOpen
+	}
+	lifetime := getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute)
+	if n, err := strconv.Atoi(getEnv("DB_SAVE_WORKERS", "")); err == nil && n > 0 {
+		dbSaveSem = make(chan struct{}, n)
+	}
+
+	conn.SetMaxOpenConns(maxOpen)
+	conn.SetMaxIdleConns(maxIdle)
+	conn.SetConnMaxLifetime(lifetime)
+	fmt.Printf("   🔗 Пул БД: max open %d, max idle %d, lifetime %v, сохранений параллельно %d\n",
+		maxOpen, maxIdle, lifetime, cap(dbSaveSem))
+}
+
+// priorityToDB — "1"-"10" → INT; нечисловой или вне 1–10 (CHECK ai_analysis_priority_range)
+// приоритет сохраняется как NULL
+func priorityToDB(p string) any {
+	n, err := strconv.Atoi(strings.TrimSpace(p))
+	if err != nil || n < 1 || n > 10 {
+		return nil
+	}
+	return n
+}
+
+// dbExecer — *sql.DB или *sql.Tx: save-функции работают и вне, и внутри транзакции
+type dbExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ageToDB — неизвестный возраст (0) сохраняется как NULL
+func ageToDB(age int) any {
+	if age <= 0 {
+		return nil
+	}
+	return age
+}
+
+// confidenceToDB — уверенность есть только у ответов Gemini; у Fallback — NULL
+func confidenceToDB(ai AIResult) any {
+	if !isLLMSource(ai.Source) {
+		return nil
+	}
+	return ai.Confidence
+}
+
+// nullIfEmpty — пустая строка → NULL (необязательные текстовые колонки)
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// ticketContentHash — md5 исходных полей тикета (hex). Совпадает с
+// md5(concat_ws(E'\x1f', ...)) в миграции 2 (migrations.go) — порядок полей важен.
+func ticketContentHash(t TicketInput) string {
+	sum := md5.Sum([]byte(strings.Join([]string{t.Gender, t.Birthdate, t.Text,
+		t.Attachment, t.Segment, t.Country, t.Oblast, t.RawCity, t.Street, t.House}, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadTicketHashes — tenantKey → content_hash сохранённых тикетов (для поиска
+// изменённых во входном файле)
+func loadTicketHashes(ctx context.Context, conn *sql.DB) (map[string]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT tenant_id, guid, content_hash FROM tickets WHERE content_hash IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var tenant, guid, hash string
+		if err := rows.Scan(&tenant, &guid, &hash); err != nil {
+			return nil, err
+		}
+		hashes[tenantKey(tenant, guid)] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// loadProcessedGUIDs — tenantKey тикетов с записанным роутингом (дедупликация
+// -dedup-db): тот же GUID другого тенанта обработанным не считается
+func loadProcessedGUIDs(ctx context.Context, conn *sql.DB) (map[string]bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT tenant_id, guid FROM routing_results`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	guids := make(map[string]bool)
+	for rows.Next() {
+		var tenant, guid string
+		if err := rows.Scan(&tenant, &guid); err != nil {
+			return nil, err
+		}
+		guids[tenantKey(tenant, guid)] = true
+	}
+	return guids, rows.Err()
+}
+
+// ticketUpsertTail — повторный тикет (тенант + GUID) перезаписывается, только если
+// изменилось содержимое (content_hash); неизменённый тикет не трогается.
+// created_at не обновляется — дата обращения остаётся от первой записи.
+const ticketUpsertTail = `
+		ON CONFLICT (tenant_id, guid) DO UPDATE SET
+			gender = EXCLUDED.gender, birthdate = EXCLUDED.birthdate,
+			description = EXCLUDED.description, attachment = EXCLUDED.attachment,
+			segment = EXCLUDED.segment, country = EXCLUDED.country,
+			oblast = EXCLUDED.oblast, city = EXCLUDED.city,
+			street = EXCLUDED.street, house = EXCLUDED.house, age = EXCLUDED.age,
+			content_hash = EXCLUDED.content_hash,
+			run_id = EXCLUDED.run_id, updated_at = NOW()
+		WHERE tickets.content_hash IS DISTINCT FROM EXCLUDED.content_hash`
+
+// ticketRowArgs — значения колонок tickets в порядке INSERT (description —
+// с учётом MASK_STORED_PII, content_hash — от исходного текста или StoredHash)
+func ticketRowArgs(t TicketInput) []any {
+	hash := t.StoredHash
+	if hash == "" {
+		hash = ticketContentHash(t)
+	}
+	return []any{t.GUID, t.Gender, t.Birthdate, storedDescription(t), t.Attachment, t.Segment,
+		t.Country, t.Oblast, t.RawCity, t.Street, t.House, ageToDB(t.Age), hash,
+		tenantID(t.Tenant), createdAtToDB(t), nullIfEmpty(runID)}
+}
+
+// ticketInsertHead — INSERT в tickets до VALUES (колонки — как в ticketRowArgs)
+const ticketInsertHead = `INSERT INTO tickets (guid, gender, birthdate, description, attachment,
+		segment, country, oblast, city, street, house, age, content_hash, tenant_id, created_at, run_id) VALUES `
+
+// saveTicketToDB — исходный тикет (upsert по content_hash)
+func saveTicketToDB(ctx context.Context, ex dbExecer, t TicketInput) error {
+	err := insertMulti(ctx, ex, ticketInsertHead, ticketUpsertTail, [][]any{ticketRowArgs(t)})
+	if err != nil {
+		return err
+	}
+	return saveRawDescription(ctx, ex, t)
+}
+
+// aiAnalysisUpsertSet — SET-часть upsert ai_analysis (общая для поштучной и пакетной записи)
+const aiAnalysisUpsertSet = `
+			type = EXCLUDED.type, sentiment = EXCLUDED.sentiment,
+			language = EXCLUDED.language, priority = EXCLUDED.priority,
+			summary = EXCLUDED.summary, nearest_office = EXCLUDED.nearest_office,
+			geo_lat = EXCLUDED.geo_lat, geo_lon = EXCLUDED.geo_lon,
+			geo_method = EXCLUDED.geo_method, source = EXCLUDED.source,
+			raw_ai = EXCLUDED.raw_ai, prompt_version = EXCLUDED.prompt_version,
+			link_domains = EXCLUDED.link_domains, derived_oblast = EXCLUDED.derived_oblast,
+			confidence = EXCLUDED.confidence,
+			type_source = EXCLUDED.type_source, geo_precision = EXCLUDED.geo_precision,
+			priority_category = EXCLUDED.priority_category, spam_domain = EXCLUDED.spam_domain,
+			run_id = EXCLUDED.run_id, analyzed_at = NOW(), updated_at = NOW()`
+
+// saveAIResultToDB — результат AI-анализа и геокодирования (upsert)
+func saveAIResultToDB(ctx context.Context, ex dbExecer, guid, tenant string, ai AIResult) error {
+	_, err := ex.ExecContext(ctx, `
+		INSERT INTO ai_analysis (guid, type, sentiment, language, priority, summary,
+		                         nearest_office, geo_lat, geo_lon, geo_method, source,
+		                         raw_ai, prompt_version, link_domains, derived_oblast, confidence,
+		                         tenant_id, type_source, geo_precision, priority_category, spam_domain, run_id)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22)
+		ON CONFLICT (tenant_id, guid) DO UPDATE SET`+aiAnalysisUpsertSet,
+		guid, ai.Type, ai.Sentiment, ai.Language, priorityToDB(ai.Priority), ai.Summary,
+		ai.NearestOffice, ai.GeoLat, ai.GeoLon, ai.GeoMethod, ai.Source,
+		ai.RawAI, ai.PromptVersion, ai.LinkDomains, nullIfEmpty(ai.DerivedOblast), confidenceToDB(ai),
+		tenantID(tenant), nullIfEmpty(ai.TypeSource), nullIfEmpty(ai.GeoPrecision),
+		nullIfEmpty(priorityCategory(ai.Priority)), nullIfEmpty(ai.SpamDomain), nullIfEmpty(runID))
+	return err
+}
+
+// routingUpsertSet — общее для одиночного и пакетного upsert в routing_results
+const routingUpsertSet = `
+			manager_name = EXCLUDED.manager_name, manager_role = EXCLUDED.manager_role,
+			assigned_office = EXCLUDED.assigned_office,
+			routing_reason = EXCLUDED.routing_reason,
+			is_escalated = EXCLUDED.is_escalated, distance_km = EXCLUDED.distance_km,
+			alt_offices = EXCLUDED.alt_offices,
+			due_at = EXCLUDED.due_at, run_id = EXCLUDED.run_id,
+			routed_at = NOW(), updated_at = NOW()`
+
+// distanceToDB — 0 (расстояние неизвестно) сохраняется как NULL
+func distanceToDB(km float64) any {
+	if km == 0 {
+		return nil
+	}
+	return km
+}
+
+// saveRoutingToDB — итог роутинга (upsert)
+func saveRoutingToDB(ctx context.Context, ex dbExecer, r RoutingResult) error {
+	_, err := ex.ExecContext(ctx, `
+		INSERT INTO routing_results (guid, manager_name, manager_role, assigned_office,
+		                             routing_reason, is_escalated, distance_km, alt_offices, tenant_id, due_at, run_id)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		ON CONFLICT (tenant_id, guid) DO UPDATE SET`+routingUpsertSet,
+		r.GUID, r.ManagerName, r.ManagerRole, r.AssignedOffice, r.RoutingReason, r.IsEscalated,
+		distanceToDB(r.DistanceKm), r.AltOffices, tenantID(r.Tenant), dueAtToDB(r.DueAt), nullIfEmpty(runID))
+	return err
+}
+
+// saveTicketChainTx — tickets → ai_analysis → routing_results одной транзакцией:
+// либо вся цепочка 1:1:1, либо ничего (rollback при любой ошибке)
+func saveTicketChainTx(ctx context.Context, conn *sql.DB, t TicketInput, ai AIResult, r RoutingResult) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin: %v", err)
+	}
+	defer tx.Rollback() // no-op после Commit
+
+	if err := saveTicketToDB(ctx, tx, t); err != nil {
+		return fmt.Errorf("tickets: %v", err)
+	}
+	if err := saveAIResultToDB(ctx, tx, t.GUID, t.Tenant, ai); err != nil {
+		return fmt.Errorf("ai_analysis: %v", err)
+	}
+	if err := saveRoutingToDB(ctx, tx, r); err != nil {
+		return fmt.Errorf("routing_results: %v", err)
+	}
+	if r.ReviewReason != "" {
+		if err := enqueueReview(ctx, tx, r.GUID, r.Tenant, r.ReviewReason); err != nil {
+			return fmt.Errorf("review_queue: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// saveAllAsync — сохраняет цепочку тикета в фоне (одной транзакцией); CSV не ждёт БД.
+// Перед выходом нужно дождаться dbWg.Wait(). Отмена ctx (Ctrl-C) не обрывает
+// уже начатое сохранение — строка, записанная в CSV, дописывается и в БД.
+func saveAllAsync(ctx context.Context, conn *sql.DB, t TicketInput, ai AIResult, r RoutingResult) {
+	if conn == nil {
+		return
+	}
+	dbWg.Add(1)
+	go func() {
+		defer dbWg.Done()
+		dbSaveSem <- struct{}{}
+		defer func() { <-dbSaveSem }()
+		start := time.Now()
+		err := withDBRetry(context.WithoutCancel(ctx), dbSaveTimeout, func(ctx context.Context) error {
+			return saveTicketChainTx(ctx, conn, t, ai, r)
+		})
+		timings.AddDB(time.Since(start))
+		if err != nil {
+			log.Printf("⚠️ БД %s: цепочка не сохранена (rollback): %v", t.GUID, err)
+			recordDBFailure([]dbRow{{t, ai, r}}, err)
+		}
+	}()
+}
+
+// ═══════════════════════════════════════════════════════════
+//  ПАКЕТНАЯ ЗАПИСЬ — multi-row INSERT ... ON CONFLICT
+// ═══════════════════════════════════════════════════════════
+
+// dbRow — цепочка одного тикета для пакетной записи
+type dbRow struct {
+	T  TicketInput
+	AI AIResult
+	R  RoutingResult
+}
+
+// dbBatcher — копит строки и сбрасывает их пачками по size в фоне (через dbWg).
+// Семантика та же, что у поштучных save-функций: tickets — DO NOTHING,
+// ai_analysis и routing_results — upsert.
+type dbBatcher struct {
+	ctx     context.Context
+	conn    *sql.DB
+	size    int
+	pending []dbRow
+}
+
+// maxDBBatch — Postgres принимает не более 65535 параметров на запрос (22 колонки × 2950)
+const maxDBBatch = 2950
+
+func newDBBatcher(ctx context.Context, conn *sql.DB, size int) *dbBatcher {
+	if size > maxDBBatch {
+		size = maxDBBatch
+	}
+	return &dbBatcher{ctx: ctx, conn: conn, size: size}
+}
+
+// Add — добавить тикет; при накоплении size строк пачка уходит в БД
+func (b *dbBatcher) Add(t TicketInput, ai AIResult, r RoutingResult) {
+	b.pending = append(b.pending, dbRow{t, ai, r})
+	if len(b.pending) >= b.size {
+		b.flushAsync()
+	}
+}
+
+// Close — сбросить остаток. Дождаться записи — dbWg.Wait().
+func (b *dbBatcher) Close() {
+	if len(b.pending) > 0 {
+		b.flushAsync()
+	}
+}
+
+func (b *dbBatcher) flushAsync() {
+	rows := b.pending
+	b.pending = nil
+	dbWg.Add(1)
+	go func() {
+		defer dbWg.Done()
+		dbSaveSem <- struct{}{}
+		defer func() { <-dbSaveSem }()
+		start := time.Now()
+		err := withDBRetry(context.WithoutCancel(b.ctx), dbSaveTimeout*3, func(ctx context.Context) error {
+			return saveBatchToDB(ctx, b.conn, rows)
+		})
+		timings.AddDB(time.Since(start))
+		if err != nil {
+			log.Printf("⚠️ БД пакет из %d тикетов: %v", len(rows), err)
+			recordDBFailure(rows, err)
+			return
+		}
+		fmt.Printf("   💾 БД: пакет из %d тикетов сохранён\n", len(rows))
+	}()
+}
+
+// sqlDefault — значение для insertMulti: в VALUES пишется DEFAULT (значение
+// колонки по умолчанию, например NOW())
+type sqlDefault struct{}
+
+// insertMulti — INSERT с VALUES на все строки сразу: head + ($1..$n),(...) + tail
+func insertMulti(ctx context.Context, ex dbExecer, head, tail string, rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString(head)
+	args := make([]any, 0, len(rows)*len(rows[0]))
+	for i, row := range rows {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("(")
+		for j, v := range row {
+			if j > 0 {
+				sb.WriteString(",")
+			}
+			if _, ok := v.(sqlDefault); ok {
+				sb.WriteString("DEFAULT")
+				continue
+			}
+			args = append(args, v)
+			sb.WriteString("$" + strconv.Itoa(len(args)))
+		}
+		sb.WriteString(")")
+	}
+	sb.WriteString(tail)
+	_, err := ex.ExecContext(ctx, sb.String(), args...)
+	return err
+}
+
+// saveBatchToDB — пачка тикетов тремя multi-row INSERT в одной транзакции
+func saveBatchToDB(ctx context.Context, conn *sql.DB, rows []dbRow) error {
+	// Один ключ дважды в одном INSERT ... ON CONFLICT DO UPDATE — ошибка Postgres
+	seen := make(map[string]bool, len(rows))
+	var tRows, aRows, rRows, qRows [][]any
+	for i := len(rows) - 1; i >= 0; i-- {
+		row := rows[i]
+		key := tenantKey(row.T.Tenant, row.T.GUID)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		t, ai, r := row.T, row.AI, row.R
+		tRows = append(tRows, ticketRowArgs(t))
+		aRows = append(aRows, []any{t.GUID, ai.Type, ai.Sentiment, ai.Language, priorityToDB(ai.Priority),
+			ai.Summary, ai.NearestOffice, ai.GeoLat, ai.GeoLon, ai.GeoMethod, ai.Source,
+			ai.RawAI, ai.PromptVersion, ai.LinkDomains, nullIfEmpty(ai.DerivedOblast), confidenceToDB(ai),
+			tenantID(t.Tenant), nullIfEmpty(ai.TypeSource), nullIfEmpty(ai.GeoPrecision),
+			nullIfEmpty(priorityCategory(ai.Priority)), nullIfEmpty(ai.SpamDomain), nullIfEmpty(runID)})
+		rRows = append(rRows, []any{r.GUID, r.ManagerName, r.ManagerRole, r.AssignedOffice,
+			r.RoutingReason, r.IsEscalated, distanceToDB(r.DistanceKm), r.AltOffices, tenantID(r.Tenant),
+			dueAtToDB(r.DueAt), nullIfEmpty(runID)})
+		if r.ReviewReason != "" {
+			qRows = append(qRows, []any{r.GUID, r.ReviewReason, tenantID(r.Tenant)})
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertMulti(ctx, tx, ticketInsertHead, ticketUpsertTail, tRows); err != nil {
+		return fmt.Errorf("tickets: %v", err)
+	}
+	for _, row := range rows {
+		if err := saveRawDescription(ctx, tx, row.T); err != nil {
+			return fmt.Errorf("tickets_raw: %v", err)
+		}
+	}
+	if err := insertMulti(ctx, tx, `INSERT INTO ai_analysis (guid, type, sentiment, language, priority,
+		summary, nearest_office, geo_lat, geo_lon, geo_method, source, raw_ai, prompt_version, link_domains,
+		derived_oblast, confidence, tenant_id, type_source, geo_precision, priority_category, spam_domain,
+		run_id) VALUES `,
+		` ON CONFLICT (tenant_id, guid) DO UPDATE SET`+aiAnalysisUpsertSet, aRows); err != nil {
+		return fmt.Errorf("ai_analysis: %v", err)
+	}
+	if err := insertMulti(ctx, tx, `INSERT INTO routing_results (guid, manager_name, manager_role,
+		assigned_office, routing_reason, is_escalated, distance_km, alt_offices, tenant_id, due_at, run_id) VALUES `,
+		` ON CONFLICT (tenant_id, guid) DO UPDATE SET`+routingUpsertSet, rRows); err != nil {
+		return fmt.Errorf("routing_results: %v", err)
+	}
+	if len(qRows) > 0 {
+		if err := insertMulti(ctx, tx, `INSERT INTO review_queue (guid, reason, tenant_id) VALUES `,
+			` ON CONFLICT (tenant_id, guid) DO UPDATE SET reason = EXCLUDED.reason
+			WHERE review_queue.status = 'pending'`, qRows); err != nil {
+			return fmt.Errorf("review_queue: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ═══════════════════════════════════════════════════════════
+//  ПОВТОРЫ ПРИ СБОЯХ БД + журнал несохранённых строк
+// ═══════════════════════════════════════════════════════════
+
+const (
+	dbRetryAttempts = 4
+	dbRetryBase     = 250 * time.Millisecond
+	dbFailuresPath  = "data/db_failures.jsonl"
+)
+
+var dbFailuresMu sync.Mutex
+
+// isTransientDBError — ошибка, которая может пройти при повторе:
+// обрыв соединения, serialization failure / deadlock, перегрузка сервера
+func isTransientDBError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "40001", pqErr.Code == "40P01": // serialization_failure, deadlock_detected
+			return true
+		case strings.HasPrefix(string(pqErr.Code), "08"): // connection_exception
+			return true
+		case pqErr.Code == "53300", pqErr.Code == "57P01", pqErr.Code == "57P03": // too_many_connections, admin_shutdown, cannot_connect_now
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "connection reset") || strings.Contains(msg, "broken pipe") ||
+		strings.Contains(msg, "connection refused")
+}
+
+// withDBRetry — op с таймаутом timeout на попытку; временные ошибки повторяются
+// с экспоненциальной паузой (250ms, 500ms, 1s), остальные возвращаются сразу
+func withDBRetry(ctx context.Context, timeout time.Duration, op func(ctx context.Context) error) error {
+	var err error
+	for attempt := 1; attempt <= dbRetryAttempts; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err = op(attemptCtx)
+		cancel()
+		if err == nil || !isTransientDBError(err) || attempt == dbRetryAttempts {
+			return err
+		}
+		pause := dbRetryBase << (attempt - 1)
+		log.Printf("⚠️ БД: временная ошибка (попытка %d/%d): %v → повтор через %v", attempt, dbRetryAttempts, err, pause)
+		if !sleepCtx(ctx, pause) {
+			return err
+		}
+	}
+	return err
+}
+
+// recordDBFailure — дописывает несохранённые цепочки в data/db_failures.jsonl,
+// чтобы их можно было дозагрузить вручную: строки не теряются молча. Файл —
+// только для владельца; ПДн маскируются как в БД (failureTicket), content_hash —
+// от исходного текста.
+func recordDBFailure(rows []dbRow, cause error) {
+	dbFailuresMu.Lock()
+	defer dbFailuresMu.Unlock()
+
+	os.MkdirAll("data", 0755)
+	f, err := os.OpenFile(dbFailuresPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("❌ %s: %v — потеряно %d строк", dbFailuresPath, err, len(rows))
+		return
+	}
+	defer f.Close()
+	f.Chmod(0600) // файл мог остаться от старой версии с 0644
+
+	enc := json.NewEncoder(f)
+	for _, row := range rows {
+		hash := row.T.StoredHash
+		if hash == "" {
+			hash = ticketContentHash(row.T)
+		}
+		enc.Encode(map[string]any{
+			"failed_at":    time.Now().Format(time.RFC3339),
+			"error":        cause.Error(),
+			"guid":         row.T.GUID,
+			"content_hash": hash,
+			"ticket":       failureTicket(row.T),
+			"ai":           row.AI,
+			"routing":      row.R,
+		})
+	}
+	fmt.Printf("   📝 %d несохранённых тикетов записано в %s\n", len(rows), dbFailuresPath)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  КОДИРОВКА results.csv — -out-encoding: utf-8 (с BOM) или cp1251
+// ═══════════════════════════════════════════════════════════
+
+// resultsCP1251 — results.csv в Windows-1251 (-out-encoding cp1251), без BOM
+var resultsCP1251 bool
+
+// cp1251Substitutes — буквы казахского алфавита, которых нет в Windows-1251,
+// заменяются ближайшими русскими; прочие непредставимые символы — «?»
+var cp1251Substitutes = map[rune]rune{
+	'Ә': 'А', 'ә': 'а', 'Ғ': 'Г', 'ғ': 'г', 'Қ': 'К', 'қ': 'к',
+	'Ң': 'Н', 'ң': 'н', 'Ө': 'О', 'ө': 'о', 'Ұ': 'У', 'ұ': 'у',
+	'Ү': 'У', 'ү': 'у', 'Һ': 'Х', 'һ': 'х',
+}
+
+// loadOutputEncoding — значение -out-encoding: utf-8 | cp1251 (windows-1251)
+func loadOutputEncoding() error {
+	switch strings.ToLower(strings.TrimSpace(*outEncoding)) {
+	case "", "utf-8", "utf8":
+		resultsCP1251 = false
+	case "cp1251", "windows-1251", "1251":
+		resultsCP1251 = true
+		fmt.Println("🔤 results.csv в Windows-1251 (без BOM); казахские буквы → русские, прочее непредставимое → ?")
+	default:
+		return fmt.Errorf("-out-encoding %q: ожидается utf-8 или cp1251", *outEncoding)
+	}
+	return nil
+}
+
+// resultsBOM — BOM в начале нового results.csv; у cp1251 BOM нет
+func resultsBOM() string {
+	if resultsCP1251 {
+		return ""
+	}
+	return utf8BOM
+}
+
+// cp1251Rune — символ, представимый в Windows-1251 (см. cp1251Substitutes)
+func cp1251Rune(r rune) rune {
+	if s, ok := cp1251Substitutes[r]; ok {
+		return s
+	}
+	if _, ok := charmap.Windows1251.EncodeRune(r); !ok {
+		return '?'
+	}
+	return r
+}
+
+// nopWriteCloser — Close без действия (вывод в UTF-8 не буферизуется)
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// encodeResults — writer для results.csv в кодировке -out-encoding. Close
+// дописывает хвост преобразования; сам w не закрывает.
+func encodeResults(w io.Writer) io.WriteCloser {
+	if !resultsCP1251 {
+		return nopWriteCloser{w}
+	}
+	return transform.NewWriter(w, transform.Chain(runes.Map(cp1251Rune), charmap.Windows1251.NewEncoder()))
+}
+
+// decodeResults — чтение results.csv, записанного с -out-encoding
+func decodeResults(r io.Reader) io.Reader {
+	if !resultsCP1251 {
+		return r
+	}
+	return charmap.Windows1251.NewDecoder().Reader(r)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"database/sql"
+	"maps"
+	"sync"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ENGINE — данные и состояние роутинга одного набора офисов
+// ═══════════════════════════════════════════════════════════
+
+// Engine — офисы, менеджеры, алиасы, Round Robin и подключения одного
+// движка. Движки независимы: у каждого свои счётчики и нагрузка менеджеров,
+// поэтому в одном процессе их может быть несколько.
+type Engine struct {
+	managers     map[string][]*Manager // офис → менеджеры (managers.csv)
+	offices      []string              // офисы из business_units.csv
+	officeCoords map[string]GeoPoint   // координаты офисов для Haversine
+	hqCities     []string              // ГО для эскалации
+	cityAliases  map[string]string     // нормализованный город → офис
+	geocoder     Geocoder
+	analyzer     Analyzer
+	db           *sql.DB // nil — работаем только с CSV
+
+	// tenant — tenant_id тикетов этого движка; tenants — движки остальных
+	// тенантов (LoadTenants), только у движка default
+	tenant  string
+	tenants map[string]*Engine
+
+	// mu — защищает rrCounters, hqSplit, vipGapEscalations, vipGapTickets и
+	// Manager.Workload при параллельном роутинге (-route-workers, POST /route)
+	mu         sync.Mutex
+	rrCounters map[string]int
+	hqSplit    map[string]int // ГО → тикетов по 50/50 (pickSplitHQ, data/hq_split.json)
+
+	// vipGapOffices — офисы без VIP-менеджеров (CheckVIPCoverage);
+	// vipGapEscalations — сколько VIP-тикетов ушло из них в ГО к менеджеру (под mu)
+	vipGapOffices     map[string]bool
+	vipGapEscalations map[string]int
+
+	// vipGapTickets — VIP-тикеты из этих офисов поштучно, в т.ч. без менеджера
+	// и в ГО (для списка в итогах, под mu)
+	vipGapTickets []vipGapTicket
+}
+
+// vipGapTicket — VIP/Priority-тикет, ушедший в ГО из-за офиса без VIP-менеджеров
+type vipGapTicket struct {
+	GUID     string
+	Segment  string
+	Priority string
+	Office   string // офис клиента без VIP-покрытия
+	HQ       string // куда эскалирован; "—" — менеджер не найден и в ГО
+}
+
+// NewEngine — пустой движок со встроенными координатами офисов, ГО и
+// алиасами городов; офисы и менеджеры — LoadOffices/LoadManagers.
+func NewEngine(g Geocoder, a Analyzer, conn *sql.DB) *Engine {
+	return &Engine{
+		managers:          make(map[string][]*Manager),
+		officeCoords:      maps.Clone(defaultOfficeCoords),
+		hqCities:          append([]string(nil), HQ_CITIES...),
+		cityAliases:       maps.Clone(defaultCityAliases),
+		geocoder:          g,
+		analyzer:          a,
+		db:                conn,
+		tenant:            defaultTenant,
+		tenants:           make(map[string]*Engine),
+		rrCounters:        make(map[string]int),
+		hqSplit:           make(map[string]int),
+		vipGapOffices:     make(map[string]bool),
+		vipGapEscalations: make(map[string]int),
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+
+// ═══════════════════════════════════════════════════════════
+//  ТИП ОБРАЩЕНИЯ — AI против Keyword Fallback (-ensemble)
+// ═══════════════════════════════════════════════════════════
+
+// Кто определил тип (колонка Источник_типа)
+const (
+	typeSourceAI       = "AI"
+	typeSourceFallback = "Fallback"
+	typeSourceBoth     = "AI+Fallback" // модель и ключевые слова согласны
+)
+
+// highSeverityTypes — с -ensemble при расхождении побеждает тип из этого списка
+var highSeverityTypes = map[string]bool{
+	"Мошеннические действия": true,
+	"Претензия":              true,
+}
+
+// resolveType — итоговый Type и TypeSource после сверки AI с Keyword Fallback
+// (fallbackType, disagree — из crossCheckAI). С -ensemble при расхождении, где
+// ровно одна сторона — тип высокой важности, берётся он (тикет и так уходит на
+// проверку: NeedsReview = disagree). Иначе — правило низкой уверенности
+// (applyLowConfidence).
+func resolveType(t TicketInput, ai AIResult, fallbackType string, disagree bool) AIResult {
+	typ := ai.Type
+	ai = chooseType(ai, fallbackType, disagree)
+	if ai.Type != typ {
+		ai = applyPriorityRules(t, ai) // правила — по итоговому типу, а не по ответу AI
+	}
+	return ai
+}
+
+// chooseType — выбор типа для resolveType (без пересчёта приоритета)
+func chooseType(ai AIResult, fallbackType string, disagree bool) AIResult {
+	switch {
+	case !isLLMSource(ai.Source):
+		ai.TypeSource = typeSourceFallback
+		return ai
+	case !disagree:
+		ai.TypeSource = typeSourceBoth
+		return ai
+	}
+
+	if *ensembleMode && highSeverityTypes[fallbackType] != highSeverityTypes[ai.Type] {
+		if highSeverityTypes[fallbackType] {
+			fmt.Printf("   🗳 Ансамбль: тип '%s' (ключевые слова) важнее '%s' (AI)\n", fallbackType, ai.Type)
+			ai.Type = fallbackType
+			ai.TypeSource = typeSourceFallback
+		} else {
+			ai.TypeSource = typeSourceAI
+		}
+		return ai
+	}
+
+	ai = applyLowConfidence(ai, fallbackType, disagree)
+	ai.TypeSource = typeSourceAI
+	if ai.Type == fallbackType {
+		ai.TypeSource = typeSourceFallback
+	}
+	return ai
+}
+
+// ensembleVote — с -ensemble: AI и ключевые слова разошлись, и победил тип
+// высокой важности — причина для review_queue независимо от REVIEW_ON_DISAGREEMENT
+func ensembleVote(r RoutingResult) bool {
+	return *ensembleMode && r.NeedsReview && highSeverityTypes[r.Type]
+}
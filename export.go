@@ -0,0 +1,480 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ЭКСПОРТ — GeoJSON для визуализации на карте
+// ═══════════════════════════════════════════════════════════
+
+type geoJSONFeature struct {
+	Type     string         `json:"type"`
+	Geometry geoJSONPoint   `json:"geometry"`
+	Props    map[string]any `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // [lon, lat] — порядок по RFC 7946
+}
+
+// exportGeoJSON — пишет FeatureCollection: один Point на тикет с известными координатами.
+// Тикеты без координат (иностранцы, 50/50, LLM-геолокация) пропускаются.
+func exportGeoJSON(path string, results []RoutingResult) error {
+	features := []geoJSONFeature{}
+	for _, r := range results {
+		if r.GeoLat == 0 && r.GeoLon == 0 {
+			continue
+		}
+		features = append(features, geoJSONFeature{
+			Type: "Feature",
+			Geometry: geoJSONPoint{
+				Type:        "Point",
+				Coordinates: [2]float64{r.GeoLon, r.GeoLat},
+			},
+			Props: map[string]any{
+				"guid":              r.GUID,
+				"type":              r.Type,
+				"priority":          r.Priority,
+				"assigned_office":   r.AssignedOffice,
+				"is_escalated":      r.IsEscalated,
+				"geo_precision":     r.GeoPrecision,
+				"priority_category": r.PriorityCategory,
+			},
+		})
+	}
+
+	data, err := json.MarshalIndent(map[string]any{
+		"type":     "FeatureCollection",
+		"features": features,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		os.MkdirAll(dir, 0755)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("🗺  GeoJSON: %d точек из %d тикетов → %s\n", len(features), len(results), path)
+	return nil
+}
+
+// ═══════════════════════════════════════════════════════════
+//  ОТЧЁТ ОБ ОТБРАКОВАННЫХ И ДЕГРАДИРОВАННЫХ ТИКЕТАХ
+// ═══════════════════════════════════════════════════════════
+
+const rejectedPath = "data/rejected.csv"
+
+// Причины для rejected.csv
+const (
+	rejectEmptyContent     = "Пустое обращение"
+	rejectAISkipped        = "AI пропустил → Keyword Fallback"
+	rejectAIFailed         = "AI недоступен → Keyword Fallback"
+	rejectManagerNotFound  = "Менеджер не найден"
+	rejectGeocodeFailed    = "Геокодирование не удалось"
+	rejectInvalidBirthdate = "Некорректная дата рождения"
+	rejectDuplicateGUID    = "Дубликат GUID в файле"
+	rejectUnknownTenant    = "Неизвестный тенант"
+	rejectInvalidCreatedAt = "Некорректная дата обращения"
+)
+
+type rejectedEntry struct {
+	GUID   string
+	Reason string
+	Detail string
+}
+
+// rejectReport — единый список проблем качества данных за запуск.
+// Тикет может попасть в отчёт несколько раз с разными причинами.
+type rejectReport struct {
+	mu      sync.Mutex
+	entries []rejectedEntry
+}
+
+func (r *rejectReport) Add(guid, reason, detail string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, rejectedEntry{guid, reason, detail})
+}
+
+// Write — перезаписывает отчёт: в нём только проблемы текущего запуска
+func (r *rejectReport) Write(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if dir := filepath.Dir(path); dir != "." {
+		os.MkdirAll(dir, 0755)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := newCSVWriter(f)
+	w.Write([]string{"GUID", "Причина", "Детали"})
+	for _, e := range r.entries {
+		w.Write([]string{e.GUID, e.Reason, e.Detail})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, e := range r.entries {
+		counts[e.Reason]++
+	}
+	if len(r.entries) > 0 {
+		fmt.Printf("📋 Отбраковано/деградировало: %d записей → %s %v\n", len(r.entries), path, counts)
+	}
+	return nil
+}
+
+// ═══════════════════════════════════════════════════════════
+//  РАСХОЖДЕНИЯ AI И KEYWORD FALLBACK — выборка для QA
+// ═══════════════════════════════════════════════════════════
+
+const disagreementsPath = "data/disagreements.csv"
+
+type disagreement struct {
+	R            RoutingResult
+	AIType       string // тип от Gemini (R.Type мог быть заменён при низкой уверенности)
+	FallbackType string
+}
+
+// disagreementReport — тикеты, где тип от Gemini не совпал с ключевыми словами
+type disagreementReport struct {
+	mu      sync.Mutex
+	entries []disagreement
+}
+
+func (d *disagreementReport) Add(r RoutingResult, aiType, fallbackType string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = append(d.entries, disagreement{r, aiType, fallbackType})
+}
+
+// Write — перезаписывает отчёт: только расхождения текущего запуска
+func (d *disagreementReport) Write(path string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if dir := filepath.Dir(path); dir != "." {
+		os.MkdirAll(dir, 0755)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := newCSVWriter(f)
+	w.Write([]string{"GUID", "Тип_AI", "Тип_Fallback", "Уверенность_AI", "Приоритет", "Офис Назначения", "Назначенный Менеджер", "Рекомендации менеджеру"})
+	for _, e := range d.entries {
+		w.Write([]string{e.R.GUID, e.AIType, e.FallbackType, strconv.FormatFloat(e.R.Confidence, 'f', 2, 64),
+			e.R.Priority, e.R.AssignedOffice, e.R.ManagerName, e.R.Summary})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if len(d.entries) > 0 {
+		fmt.Printf("🔍 Расхождений AI и Keyword Fallback: %d → %s\n", len(d.entries), path)
+	}
+	return nil
+}
+
+// ═══════════════════════════════════════════════════════════
+//  СТРОКИ ИТОГО В results.csv (флаг -totals)
+// ═══════════════════════════════════════════════════════════
+
+// totalsGUID — значение колонки GUID у строк итогов; такие строки не тикеты
+const totalsGUID = "ИТОГО"
+
+// rewriteTotals — убирает прежние строки ИТОГО и дописывает свежие в конец файла.
+// Итоги считаются по всем тикетам файла (а не только текущего запуска),
+// так что при инкрементальных запусках они остаются корректными.
+// Формат строки: GUID=ИТОГО, Сегмент=название показателя, Тип=значение.
+func rewriteTotals(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	rows, err := newCSVReader(decodeResults(f)).ReadAll()
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	header := rows[0]
+	var data [][]string
+	var results []RoutingResult
+	for _, row := range rows[1:] {
+		if len(row) == 0 || row[0] == totalsGUID {
+			continue
+		}
+		data = append(data, row)
+		results = append(results, routingResultFromCSV(row))
+	}
+
+	st := computeSummary(results)
+	totals := [][2]string{
+		{"Всего тикетов", strconv.Itoa(st.Total)},
+		{"Спам", strconv.Itoa(st.Spam)},
+		{"Эскалировано в ГО", strconv.Itoa(st.Escalated)},
+		{"Без менеджера", strconv.Itoa(st.NoManager)},
+	}
+	for _, k := range sortedKeys(st.Types) {
+		totals = append(totals, [2]string{"Тип: " + k, strconv.Itoa(st.Types[k])})
+	}
+	for _, k := range sortedKeys(st.Sentiments) {
+		totals = append(totals, [2]string{"Тональность: " + k, strconv.Itoa(st.Sentiments[k])})
+	}
+
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := encodeResults(out)
+	w := newCSVWriter(enc)
+	w.Write(header)
+	w.WriteAll(data)
+	for _, t := range totals {
+		row := make([]string, len(header))
+		row[0], row[1], row[2] = totalsGUID, t[0], t[1]
+		w.Write(row)
+	}
+	w.Flush()
+	if err = w.Error(); err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	fmt.Printf("🧮 Строки %s (%d) дописаны в %s\n", totalsGUID, len(totals), path)
+	return os.Rename(tmp, path)
+}
+
+// dropResultRows — переписывает results.csv без строк указанных GUID
+// (устаревшие результаты тикетов, которые обрабатываются заново)
+func dropResultRows(path string, guids map[string]bool) error {
+	return rewriteResultRows(path, func(row []string) []string {
+		if guids[row[0]] {
+			return nil
+		}
+		return row
+	})
+}
+
+// readResultRows — все строки results.csv (в кодировке -out-encoding), с заголовком
+func readResultRows(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readCSV(decodeResults(f))
+}
+
+// rewriteResultRows — переписывает results.csv через tmp + rename: edit получает
+// каждую непустую строку после заголовка и возвращает новую (nil — удалить)
+func rewriteResultRows(path string, edit func(row []string) []string) error {
+	rows, err := readResultRows(path)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	out.WriteString(resultsBOM())
+	enc := encodeResults(out)
+	w := newCSVWriter(enc)
+	for i, row := range rows {
+		if i > 0 && len(row) > 0 {
+			if row = edit(row); row == nil {
+				continue
+			}
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err = w.Error(); err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// routingResultFromCSV — обратное к resultCSVRow (для пересчёта итогов по файлу)
+func routingResultFromCSV(row []string) RoutingResult {
+	get := func(i int) string {
+		if i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	distance, _ := strconv.ParseFloat(get(16), 64)
+	responseMin, _ := strconv.Atoi(get(25))
+	return RoutingResult{
+		GUID:           get(0),
+		Segment:        get(1),
+		Type:           get(2),
+		Sentiment:      get(3),
+		Language:       get(4),
+		Priority:       get(5),
+		Summary:        get(6),
+		Attachment:     get(7),
+		ManagerName:    get(8),
+		ManagerRole:    get(9),
+		AssignedOffice: get(10),
+		IsEscalated:    get(11) == "Да",
+		CityOriginal:   get(12),
+		RoutingReason:  get(13),
+		Source:         get(14),
+		GeoMethod:      get(15),
+		DistanceKm:     distance,
+		AltOffices:     get(17),
+		LinkDomains:    get(18),
+		TypeSource:     get(19),
+		GeoPrecision:   get(20),
+
+		PriorityCategory: get(21),
+		AttachmentType:   get(22),
+		SpamDomain:       get(23),
+		DueAt:            get(24),
+		ResponseMin:      responseMin,
+	}
+}
+
+// sortedKeys — ключи map по алфавиту (стабильный порядок в файлах)
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ═══════════════════════════════════════════════════════════
+//  РАБОЧИЕ СПИСКИ МЕНЕДЖЕРОВ — data/worklists/
+// ═══════════════════════════════════════════════════════════
+
+const worklistsDir = "data/worklists"
+
+var worklistHeader = []string{"GUID", "Приоритет", "Тип", "Тональность", "Сегмент", "Город", "Эскалирован", "Суммари"}
+
+// worklistRunDir — новый подкаталог base для списков прохода (2026-01-15_09-40-00;
+// при совпадении времени — с суффиксом _2, _3...). Списки прошлых проходов
+// остаются: results текущего прохода — только новые тикеты, а назначенные
+// раньше не должны пропадать из списков менеджеров.
+func worklistRunDir(base string, now time.Time) (string, error) {
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return "", err
+	}
+	stamp := now.Format("2006-01-02_15-04-05")
+	for n := 1; ; n++ {
+		dir := filepath.Join(base, stamp)
+		if n > 1 {
+			dir += "_" + strconv.Itoa(n)
+		}
+		if err := os.Mkdir(dir, 0755); err == nil || !os.IsExist(err) {
+			return dir, err
+		}
+	}
+}
+
+// exportWorklists — по файлу на менеджера (<офис>_<менеджер>.csv, у тенантов
+// кроме default — <тенант>-<офис>_<менеджер>.csv) с его тикетами текущего
+// прохода, по убыванию приоритета. Тикеты без менеджера — в <офис>_без_менеджера.csv.
+// Спам в списки не попадает. dir — каталог прохода (worklistRunDir).
+func exportWorklists(dir string, results []RoutingResult) error {
+	groups := make(map[string][]RoutingResult)
+	for _, r := range results {
+		if r.Type == "Спам" {
+			continue // менеджер не назначается — отрабатывать нечего
+		}
+		manager := r.ManagerName
+		if manager == "" || manager == "Не найден" {
+			manager = "без_менеджера"
+		}
+		name := worklistFileName(r.Tenant, r.AssignedOffice, manager)
+		groups[name] = append(groups[name], r)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, list := range groups {
+		sort.SliceStable(list, func(i, j int) bool {
+			return priorityNum(list[i].Priority) > priorityNum(list[j].Priority)
+		})
+		if err := writeWorklist(filepath.Join(dir, name), list); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("📇 Рабочие списки: %d файлов → %s/\n", len(groups), dir)
+	return nil
+}
+
+func writeWorklist(path string, list []RoutingResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString(utf8BOM)
+	w := newCSVWriter(f)
+	w.Write(worklistHeader)
+	for _, r := range list {
+		escalated := "Нет"
+		if r.IsEscalated {
+			escalated = "Да"
+		}
+		w.Write([]string{r.GUID, r.Priority, r.Type, r.Sentiment, r.Segment, r.CityOriginal, escalated, r.Summary})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// worklistFileName — безопасное имя файла: без разделителей пути и пробелов.
+// Тенант — в имени: одноимённые офис и менеджер у разных тенантов не смешиваются.
+func worklistFileName(tenant, office, manager string) string {
+	clean := strings.NewReplacer("/", "-", "\\", "-", ":", "-", " ", "_")
+	if office == "" {
+		office = "без_офиса"
+	}
+	return clean.Replace(tenantOffice(tenantID(tenant), office)) + "_" + clean.Replace(manager) + ".csv"
+}
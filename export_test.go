@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWorklistsKeepPreviousRuns — второй проход пишет свой каталог, списки
+// первого (в т.ч. в ту же секунду) остаются на месте
+func TestWorklistsKeepPreviousRuns(t *testing.T) {
+	quietStdout(t)
+	base := filepath.Join(t.TempDir(), "worklists")
+	now := time.Date(2026, 1, 15, 9, 40, 0, 0, time.UTC)
+	first := []RoutingResult{{GUID: "g1", ManagerName: "Менеджер 1", AssignedOffice: "Астана", Priority: "6", Type: "Жалоба"}}
+	second := []RoutingResult{{GUID: "g2", ManagerName: "Менеджер 1", AssignedOffice: "Астана", Priority: "5", Type: "Консультация"}}
+
+	var dirs []string
+	for _, results := range [][]RoutingResult{first, second} {
+		dir, err := worklistRunDir(base, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := exportWorklists(dir, results); err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+	}
+	if want := []string{"2026-01-15_09-40-00", "2026-01-15_09-40-00_2"}; filepath.Base(dirs[0]) != want[0] || filepath.Base(dirs[1]) != want[1] {
+		t.Errorf("каталоги проходов %v, ожидались %v", dirs, want)
+	}
+	name := worklistFileName("", "Астана", "Менеджер 1")
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("список прохода пропал: %v", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  GEMINI — один запрос generateContent
+// ═══════════════════════════════════════════════════════════
+
+// geminiURL — generateContent модели aiModel
+func geminiURL(apiKey string) string {
+	return "https://generativelanguage.googleapis.com/v1beta/models/" + aiModel + ":generateContent?key=" + apiKey
+}
+
+// geminiGenerate — промпт → текст первого кандидата (JSON-режим ответа).
+// 429 → *rateLimitError, блокировка промпта или ответа → errAIBlocked.
+func geminiGenerate(ctx context.Context, apiKey, prompt string) (string, error) {
+	body, _ := json.Marshal(map[string]any{
+		"contents": []map[string]any{
+			{"parts": []map[string]any{{"text": prompt}}},
+		},
+		"generationConfig": map[string]any{
+			"temperature":      0.05,
+			"maxOutputTokens":  65536,
+			"responseMimeType": "application/json",
+		},
+	})
+
+	req, err := http.NewRequestWithContext(ctx, "POST", geminiURL(apiKey), bytes.NewBuffer(body))
+	if err != nil {
+		return "", fmt.Errorf("HTTP-запрос: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: aiTimeout}
+	reqStart := time.Now()
+	resp, err := client.Do(req)
+	metricGeminiLatency.Observe(time.Since(reqStart).Seconds())
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() && ctx.Err() == nil {
+			metricGeminiRequests.WithLabelValues("timeout").Inc()
+			return "", fmt.Errorf("таймаут AI-запроса (%v): %v", aiTimeout, err)
+		}
+		metricGeminiRequests.WithLabelValues("error").Inc()
+		return "", fmt.Errorf("HTTP-ошибка: %v", err)
+	}
+	defer resp.Body.Close()
+	metricGeminiRequests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+
+	if resp.StatusCode == 429 {
+		return "", &rateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	if resp.StatusCode != 200 {
+		b, _ := io.ReadAll(resp.Body)
+		snippet := string(b)
+		if len(snippet) > 400 {
+			snippet = snippet[:400]
+		}
+		return "", fmt.Errorf("API HTTP %d: %s", resp.StatusCode, snippet)
+	}
+
+	respBytes, _ := io.ReadAll(resp.Body)
+
+	// Парсинг ответа Gemini
+	var geminiResp struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason  string `json:"finishReason"`
+			SafetyRatings []struct {
+				Category string `json:"category"`
+				Blocked  bool   `json:"blocked"`
+			} `json:"safetyRatings"`
+		} `json:"candidates"`
+		PromptFeedback struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+		UsageMetadata *tokenUsage `json:"usageMetadata"`
+	}
+	if err := json.Unmarshal(respBytes, &geminiResp); err != nil {
+		return "", fmt.Errorf("парсинг Gemini ответа: %v", err)
+	}
+	// Токены оплачены, даже если ответ дальше окажется непригодным
+	if geminiResp.UsageMetadata != nil {
+		aiUsage.Add(*geminiResp.UsageMetadata)
+	}
+
+	// Блокировка всего промпта (promptFeedback) — кандидатов не будет
+	if br := geminiResp.PromptFeedback.BlockReason; br != "" {
+		fmt.Printf("   🛡 Gemini заблокировал промпт: blockReason=%s\n", br)
+		return "", fmt.Errorf("%w: blockReason=%s", errAIBlocked, br)
+	}
+	if len(geminiResp.Candidates) == 0 {
+		return "", fmt.Errorf("пустой ответ от AI (нет кандидатов)")
+	}
+
+	cand := geminiResp.Candidates[0]
+	finishReason := cand.FinishReason
+	truncated := finishReason == "MAX_TOKENS"
+	if len(cand.Content.Parts) == 0 {
+		if isBlockFinishReason(finishReason) {
+			var blocked []string
+			for _, r := range cand.SafetyRatings {
+				if r.Blocked {
+					blocked = append(blocked, r.Category)
+				}
+			}
+			fmt.Printf("   🛡 Gemini заблокировал ответ: finishReason=%s %v\n", finishReason, blocked)
+			return "", fmt.Errorf("%w: finishReason=%s", errAIBlocked, finishReason)
+		}
+		return "", fmt.Errorf("пустой ответ от AI (finishReason=%s)", finishReason)
+	}
+	switch {
+	case truncated:
+		fmt.Printf("   ✂️ Ответ AI обрезан (finishReason=MAX_TOKENS)\n")
+	case finishReason != "" && finishReason != "STOP":
+		fmt.Printf("   ⚠️ Нестандартное завершение ответа AI: finishReason=%s\n", finishReason)
+	}
+
+	return cand.Content.Parts[0].Text, nil
+}
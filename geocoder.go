@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ГЕОКОДЕР — интерфейс над Nominatim (подмена без сети: GEOCODER=offline)
+// ═══════════════════════════════════════════════════════════
+
+// Geocoder — адрес → координаты. region — область из ответа (address.state),
+// "" — неизвестна; precision — точность совпадения (geoPrecision*).
+// ok=false — адрес не найден или запрос не удался.
+type Geocoder interface {
+	Geocode(ctx context.Context, country, oblast, city, street, house string) (lat, lon float64, region, precision string, ok bool)
+}
+
+// Точность геокодирования (Точность_гео): до какого уровня адреса найдена точка.
+// Центр города или области — ближайший офис мог выбраться неверно.
+const (
+	geoPrecisionHouse  = "house"
+	geoPrecisionStreet = "street"
+	geoPrecisionCity   = "city"
+	geoPrecisionRegion = "region"
+)
+
+// nominatimGeocoder — Nominatim через geocodeAddress (лимитер, повторы на 429/503)
+type nominatimGeocoder struct{}
+
+func (nominatimGeocoder) Geocode(ctx context.Context, country, oblast, city, street, house string) (float64, float64, string, string, bool) {
+	return geocodeAddress(ctx, country, oblast, city, street, house)
+}
+
+// staticGeocoder — заранее заданные координаты по названию города (без учёта
+// регистра), без сети. Улица и дом не учитываются: точность — город.
+type staticGeocoder map[string]GeoPoint
+
+// newStaticGeocoder — coords: город → координаты
+func newStaticGeocoder(coords map[string]GeoPoint) staticGeocoder {
+	g := make(staticGeocoder, len(coords))
+	for city, p := range coords {
+		g[strings.ToLower(strings.TrimSpace(city))] = p
+	}
+	return g
+}
+
+func (g staticGeocoder) Geocode(_ context.Context, _, _, city, _, _ string) (float64, float64, string, string, bool) {
+	p, ok := g[strings.ToLower(strings.TrimSpace(city))]
+	if !ok {
+		return 0, 0, "", "", false
+	}
+	return p.Lat, p.Lon, "", geoPrecisionCity, true
+}
+
+// geocoder — геокодер запуска (configureGeocoder), передаётся в NewEngine
+var geocoder Geocoder = nominatimGeocoder{}
+
+// configureGeocoder — GEOCODER=nominatim (по умолчанию) | offline. offline —
+// только города офисов по defaultOfficeCoords: прогон без сети (демо, CI), остальные
+// адреса уходят в LLM-геолокацию и 50/50.
+func configureGeocoder() error {
+	switch mode := strings.ToLower(getEnv("GEOCODER", "nominatim")); mode {
+	case "nominatim":
+		geocoder = nominatimGeocoder{}
+	case "offline":
+		geocoder = newStaticGeocoder(defaultOfficeCoords)
+		fmt.Printf("🌐 Геокодер: offline (%d городов офисов, без Nominatim)\n", len(defaultOfficeCoords))
+	default:
+		return fmt.Errorf("GEOCODER=%q: ожидается nominatim или offline", mode)
+	}
+	return nil
+}
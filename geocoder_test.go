@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// TestResolveOfficeForTicket — каскад определения офиса на статическом
+// геокодере: иностранец → алиас → координаты + Haversine → LLM → центр области
+func TestResolveOfficeForTicket(t *testing.T) {
+	e := officeTestEngine(t)
+	e.geocoder = newStaticGeocoder(map[string]GeoPoint{
+		"Шымкент":   {42.3417, 69.5901},
+		"Караганда": {49.8047, 73.1094}, // своего офиса нет — ближайший Астана
+		"Аральск":   {46.7970, 61.6660}, // до Кызылорды ~390 км
+	})
+
+	for _, c := range []struct {
+		name      string
+		ticket    TicketInput
+		llmOffice string
+		maxKm     float64
+		office    string
+		method    string
+		precision string
+	}{
+		{"иностранец", TicketInput{Country: "Россия", RawCity: "Омск"}, "Павлодар", 0, "", "foreign", ""},
+		{"алиас", TicketInput{Country: "Казахстан", RawCity: "Семипалатинск"}, "", 0, "Усть-Каменогорск", "alias", ""},
+		{"алиас латиницей", TicketInput{Country: "KZ", RawCity: "Aktau"}, "", 0, "Актау", "alias", ""},
+		{"латиница → геокодер", TicketInput{Country: "Kazakhstan", RawCity: "Shymkent"}, "", 0, "Шымкент", "nominatim", geoPrecisionCity},
+		{"ближайший офис", TicketInput{RawCity: "Караганда"}, "Павлодар", 0, "Астана", "nominatim", geoPrecisionCity},
+		{"в пределах лимита", TicketInput{RawCity: "Караганда"}, "", 300, "Астана", "nominatim", geoPrecisionCity},
+		{"дальше лимита", TicketInput{RawCity: "Аральск"}, "Кызылорда", 300, "", "too_far", geoPrecisionCity},
+		{"не найден → LLM", TicketInput{RawCity: "Нет такого"}, "Павлодар", 0, "Павлодар", "llm", ""},
+		{"не найден → область", TicketInput{Oblast: "Мангистауская", RawCity: "Нет такого"}, "", 0, "Актау", "oblast-centroid", geoPrecisionRegion},
+		{"область без офиса", TicketInput{Oblast: "Карагандинская обл.", RawCity: "Нет такого"}, "", 0, "Астана", "oblast-centroid", geoPrecisionRegion},
+		{"ничего не известно", TicketInput{RawCity: "Нет такого"}, "", 0, "", "unknown", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			maxKm := MaxOfficeDistanceKm
+			MaxOfficeDistanceKm = c.maxKm
+			defer func() { MaxOfficeDistanceKm = maxKm }()
+
+			office, _, _, method, _, precision := e.resolveOfficeForTicket(context.Background(), c.ticket, c.llmOffice)
+			if office != c.office || method != c.method || precision != c.precision {
+				t.Errorf("офис %q, метод %q, точность %q; ожидалось %q, %q, %q",
+					office, method, precision, c.office, c.method, c.precision)
+			}
+		})
+	}
+}
+
+// TestResolveOfficeForTicketCoords — координаты тикета: от геокодера,
+// центр области для oblast-centroid, нули для алиаса и LLM
+func TestResolveOfficeForTicketCoords(t *testing.T) {
+	e := officeTestEngine(t)
+	e.geocoder = newStaticGeocoder(map[string]GeoPoint{"Караганда": {49.8047, 73.1094}})
+	ctx := context.Background()
+
+	if _, lat, lon, _, _, _ := e.resolveOfficeForTicket(ctx, TicketInput{RawCity: " караганда "}, ""); lat != 49.8047 || lon != 73.1094 {
+		t.Errorf("геокодер: %v, %v", lat, lon)
+	}
+	if _, lat, lon, _, _, _ := e.resolveOfficeForTicket(ctx, TicketInput{Oblast: "Павлодарская", RawCity: "Нет такого"}, ""); lat != 52.2 || lon != 76.6 {
+		t.Errorf("центр области: %v, %v", lat, lon)
+	}
+	for _, city := range []string{"Семей", "Нет такого"} {
+		if _, lat, lon, _, _, _ := e.resolveOfficeForTicket(ctx, TicketInput{RawCity: city}, "Павлодар"); lat != 0 || lon != 0 {
+			t.Errorf("%s: координаты %v, %v, ожидались нули", city, lat, lon)
+		}
+	}
+}
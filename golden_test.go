@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  GOLDEN — регрессионный прогон на фикстурах (go test -run TestGolden)
+// ═══════════════════════════════════════════════════════════
+
+// goldenDir — каталог фикстур относительно пакета. Файлы каталога. ai.json — готовые ответы модели в формате промпта
+// ([{"i":0,"type":...}], "i" — номер тикета в tickets.csv без заголовка);
+// geo.csv (Город,Широта,Долгота) необязателен — без него координаты городов офисов.
+const (
+	goldenDir      = "data/golden"
+	goldenTickets  = "tickets.csv"
+	goldenOffices  = "business_units.csv"
+	goldenManagers = "managers.csv"
+	goldenAI       = "ai.json"
+	goldenGeo      = "geo.csv"
+	goldenResults  = "results.csv"
+)
+
+// goldenListLimit — столько расхождений печатается поштучно
+const goldenListLimit = 30
+
+var goldenUpdate = flag.Bool("golden-update", false, "TestGolden: перезаписать эталонный results.csv вместо сравнения")
+
+// cannedAnalyzer — подмена модели: на любой промпт (анализ батча, повторный
+// запрос summary) отвечает заготовленными объектами для тикетов из промпта
+type cannedAnalyzer map[int]json.RawMessage
+
+func (cannedAnalyzer) Name() string { return "Golden" }
+
+// Generate — тикеты промпта — последний JSON-массив в его тексте (поле "i")
+func (a cannedAnalyzer) Generate(_ context.Context, _, prompt string) (string, error) {
+	start := strings.LastIndex(prompt, "\n[")
+	if start < 0 {
+		return "[]", nil
+	}
+	var items []struct {
+		Index int `json:"i"`
+	}
+	if err := json.Unmarshal([]byte(prompt[start+1:]), &items); err != nil {
+		return "", fmt.Errorf("golden: тикеты промпта не разобраны: %v", err)
+	}
+	var answer []json.RawMessage
+	for _, it := range items {
+		if r, ok := a[it.Index]; ok {
+			answer = append(answer, r)
+		}
+	}
+	out, err := json.Marshal(answer)
+	return string(out), err
+}
+
+// loadCannedAnalyzer — ответы из ai.json по номеру тикета
+func loadCannedAnalyzer(fp string) (cannedAnalyzer, error) {
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("%s: %v", fp, err)
+	}
+	a := make(cannedAnalyzer, len(items))
+	for n, raw := range items {
+		var it struct {
+			Index *int `json:"i"`
+		}
+		if err := json.Unmarshal(raw, &it); err != nil || it.Index == nil {
+			return nil, fmt.Errorf("%s: объект %d без поля \"i\"", fp, n+1)
+		}
+		a[*it.Index] = raw
+	}
+	return a, nil
+}
+
+// loadGoldenGeocoder — статический геокодер из geo.csv; без файла — города офисов
+func loadGoldenGeocoder(fp string) (Geocoder, error) {
+	coords := defaultOfficeCoords
+	if _, err := os.Stat(fp); err == nil {
+		records, err := readTable(fp)
+		if err != nil {
+			return nil, err
+		}
+		coords = make(map[string]GeoPoint)
+		for i, row := range records {
+			if i == 0 || len(row) < 3 {
+				continue
+			}
+			lat, err1 := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+			lon, err2 := strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("%s, строка %d: координаты '%s', '%s'", fp, i+1, row[1], row[2])
+			}
+			coords[row[0]] = GeoPoint{lat, lon}
+		}
+	}
+	return newStaticGeocoder(coords), nil
+}
+
+// TestGolden — полный конвейер (processAllTickets) на тикетах из data/golden с
+// подменой модели и геокодера, без БД; results.csv сравнивается с эталоном
+// каталога. -golden-update — перезаписать эталон после намеренного изменения
+// правил. Рабочий каталог — t.TempDir(): чекпоинт, rejected.csv и прочие data/...
+// прогона не трогают настоящие. Правила — встроенные: файлы из data/ не читаются.
+func TestGolden(t *testing.T) {
+	dir, err := filepath.Abs(goldenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyzer, err := loadCannedAnalyzer(filepath.Join(dir, goldenAI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	geocoder, err := loadGoldenGeocoder(filepath.Join(dir, goldenGeo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine(geocoder, analyzer, nil)
+	if err := engine.LoadOffices(filepath.Join(dir, goldenOffices)); err != nil {
+		t.Fatalf("офисы: %v", err)
+	}
+	if err := engine.LoadManagers(filepath.Join(dir, goldenManagers)); err != nil {
+		t.Fatalf("менеджеры: %v", err)
+	}
+	engine.CheckVIPCoverage()
+
+	work := t.TempDir()
+	if err := os.Mkdir(filepath.Join(work, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	produced := filepath.Join(work, goldenResults)
+	out := *resultsOut
+	*resultsOut = produced
+	t.Cleanup(func() { *resultsOut = out })
+	keys := newAPIKeyPool("", analyzer.Name())
+	if _, err := engine.processAllTickets(context.Background(), filepath.Join(dir, goldenTickets), keys); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := filepath.Join(dir, goldenResults)
+	if *goldenUpdate {
+		data, err := os.ReadFile(produced)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(expected, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("эталон обновлён: %s", expected)
+		return
+	}
+	diffs, err := diffResults(expected, produced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range diffs {
+		if i == goldenListLimit {
+			t.Errorf("... и ещё %d", len(diffs)-i)
+			break
+		}
+		t.Error(d)
+	}
+	if len(diffs) > 0 {
+		t.Errorf("%d расхождений с %s (обновить: go test -run TestGolden -golden-update)", len(diffs), expected)
+	}
+}
+
+// diffResults — расхождения двух results.csv по GUID: «GUID колонка: было → стало»,
+// лишние и пропавшие тикеты, изменённый заголовок
+func diffResults(expectedPath, producedPath string) ([]string, error) {
+	read := func(fp string) ([]string, map[string][]string, []string, error) {
+		f, err := os.Open(fp)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer f.Close()
+		rows, err := readCSV(decodeResults(f))
+		if err != nil || len(rows) == 0 {
+			return nil, nil, nil, fmt.Errorf("%s: пуст или не читается (%v)", fp, err)
+		}
+		byGUID := make(map[string][]string)
+		var order []string
+		for _, row := range rows[1:] {
+			if len(row) > 0 {
+				byGUID[row[0]] = row
+				order = append(order, row[0])
+			}
+		}
+		return rows[0], byGUID, order, nil
+	}
+	wantHeader, want, order, err := read(expectedPath)
+	if err != nil {
+		return nil, err
+	}
+	gotHeader, got, gotOrder, err := read(producedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	if strings.Join(wantHeader, ",") != strings.Join(gotHeader, ",") {
+		diffs = append(diffs, fmt.Sprintf("заголовок: было %v → стало %v", wantHeader, gotHeader))
+		return diffs, nil // колонки сдвинуты — построчное сравнение бессмысленно
+	}
+	for _, guid := range order {
+		short := guid[:min(8, len(guid))]
+		g, ok := got[guid]
+		if !ok {
+			diffs = append(diffs, short+": тикета нет в результате")
+			continue
+		}
+		w := want[guid]
+		for c, name := range wantHeader {
+			wv, gv := resultCell(w, c), resultCell(g, c)
+			if wv != gv {
+				diffs = append(diffs, fmt.Sprintf("%s %s: '%s' → '%s'", short, name, wv, gv))
+			}
+		}
+	}
+	for _, guid := range gotOrder {
+		if _, ok := want[guid]; !ok {
+			diffs = append(diffs, guid[:min(8, len(guid))]+": лишний тикет в результате")
+		}
+	}
+	return diffs, nil
+}
+
+// resultCell — значение колонки или "" для короткой строки
+func resultCell(row []string, c int) string {
+	if c < len(row) {
+		return row[c]
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  РАСПРЕДЕЛЕНИЕ ПО ГО — иностранцы и неизвестные адреса (50/50 между запусками)
+// ═══════════════════════════════════════════════════════════
+
+// hqSplitPath — сколько тикетов каждый ГО получил по 50/50 за все запуски
+// (тенант → ГО → число). Без файла инкрементальные запуски начинали бы с
+// Астаны каждый раз и баланс уплывал бы.
+const hqSplitPath = "data/hq_split.json"
+
+// hqSplitWeights — веса ГО в порядке hqCities (HQ_SPLIT_WEIGHTS, например
+// "2,1" — Астане вдвое больше, чем Алматы); нет веса — 1, 0 — ГО исключён
+var hqSplitWeights []int
+
+// loadHQSplitWeights — веса из окружения; некорректное значение — поровну
+func loadHQSplitWeights() {
+	v := getEnv("HQ_SPLIT_WEIGHTS", "")
+	if v == "" {
+		return
+	}
+	var weights []int
+	for _, s := range strings.Split(v, ",") {
+		w, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || w < 0 {
+			log.Printf("⚠️ HQ_SPLIT_WEIGHTS=%q не распознан, ГО поровну", v)
+			return
+		}
+		weights = append(weights, w)
+	}
+	hqSplitWeights = weights
+}
+
+// hqWeight — вес i-го ГО
+func hqWeight(i int) int {
+	if i < len(hqSplitWeights) {
+		return hqSplitWeights[i]
+	}
+	return 1
+}
+
+// pickSplitHQ — ГО для тикета без офиса (под e.mu): с наименьшим отношением
+// назначенных к весу, при равенстве — первый в hqCities. Результат зависит
+// только от накопленных счётчиков и порядка тикетов.
+func (e *Engine) pickSplitHQ() string {
+	best := -1
+	for i, hq := range e.hqCities {
+		w := hqWeight(i)
+		if w == 0 {
+			continue
+		}
+		// hqSplit[hq]/w < hqSplit[best]/wBest без деления
+		if best < 0 || e.hqSplit[hq]*hqWeight(best) < e.hqSplit[e.hqCities[best]]*w {
+			best = i
+		}
+	}
+	if best < 0 {
+		best = 0 // все веса нулевые — первый ГО
+	}
+	hq := e.hqCities[best]
+	e.hqSplit[hq]++
+	return hq
+}
+
+// LoadHQSplit — счётчики прошлых запусков для e и тенантов; нет файла — с нуля
+func (e *Engine) LoadHQSplit(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var saved map[string]map[string]int
+	if err := json.Unmarshal(data, &saved); err != nil {
+		fmt.Printf("⚠️ %s повреждён, распределение по ГО с нуля: %v\n", path, err)
+		return
+	}
+	for _, te := range e.allTenants() {
+		for hq, n := range saved[te.tenant] {
+			te.hqSplit[hq] = n
+		}
+	}
+	fmt.Printf("✅ Распределение по ГО прошлых запусков: %s\n", e.hqSplitReport())
+}
+
+// SaveHQSplit — атомарная запись счётчиков (tmp + rename)
+func (e *Engine) SaveHQSplit(path string) {
+	state := make(map[string]map[string]int)
+	for _, te := range e.allTenants() {
+		te.mu.Lock()
+		if len(te.hqSplit) > 0 {
+			state[te.tenant] = make(map[string]int, len(te.hqSplit))
+			for hq, n := range te.hqSplit {
+				state[te.tenant][hq] = n
+			}
+		}
+		te.mu.Unlock()
+	}
+	if len(state) == 0 {
+		return
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		log.Printf("⚠️ %s не записан: %v", path, err)
+		return
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		os.MkdirAll(dir, 0755)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("⚠️ %s не записан: %v", path, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("⚠️ %s не записан: %v", path, err)
+		return
+	}
+	fmt.Printf("💾 Распределение по ГО: %s → %s\n", e.hqSplitReport(), path)
+}
+
+// hqSplitReport — «Астана 12, Алматы 11» (у тенантов — с префиксом)
+func (e *Engine) hqSplitReport() string {
+	var parts []string
+	for _, te := range e.allTenants() {
+		te.mu.Lock()
+		for _, hq := range te.hqCities {
+			if n := te.hqSplit[hq]; n > 0 {
+				parts = append(parts, fmt.Sprintf("%s %d", tenantOffice(te.tenant, hq), n))
+			}
+		}
+		te.mu.Unlock()
+	}
+	if len(parts) == 0 {
+		return "пока нет"
+	}
+	return strings.Join(parts, ", ")
+}
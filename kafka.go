@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  KAFKA — потоковый приём тикетов (-kafka-brokers, -kafka-topic)
+// ═══════════════════════════════════════════════════════════
+
+const (
+	// kafkaBatchWindow — сколько копим сообщения перед запуском конвейера
+	kafkaBatchWindow = 2 * time.Second
+	// kafkaBatchSize — не больше одного чанка AI за раз
+	kafkaBatchSize = 10
+)
+
+// runKafkaConsumer — читает TicketInput (JSON) из топика группой потребителей,
+// копит до kafkaBatchSize сообщений или kafkaBatchWindow, прогоняет через
+// тот же AI → геокодирование → роутинг и пишет результат в БД и/или
+// выходной топик. Offset коммитится только после сохранения тикета: при
+// падении несохранённые сообщения прочитаются снова.
+func runKafkaConsumer(ctx context.Context, e *Engine, brokers, topic, outTopic, group string, keys *apiKeyPool) error {
+	if e.db == nil && outTopic == "" {
+		return errors.New("некуда сохранять результаты: нужна БД (DB_HOST/DB_NAME) или -kafka-out-topic")
+	}
+	brokerList := strings.Split(brokers, ",")
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: brokerList,
+		Topic:   topic,
+		GroupID: group,
+	})
+	defer reader.Close()
+
+	var writer *kafka.Writer
+	if outTopic != "" {
+		writer = &kafka.Writer{
+			Addr:         kafka.TCP(brokerList...),
+			Topic:        outTopic,
+			Balancer:     &kafka.Hash{}, // один GUID — одна партиция
+			RequiredAcks: kafka.RequireAll,
+		}
+		defer writer.Close()
+	}
+
+	fmt.Printf("📡 Kafka: %s → топик '%s' (группа '%s'), результаты → БД=%v, топик='%s'\n",
+		brokers, topic, group, e.db != nil, outTopic)
+
+	for ctx.Err() == nil {
+		msgs, err := fetchKafkaBatch(ctx, reader)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return fmt.Errorf("чтение %s: %v", topic, err)
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+		if err := e.processKafkaBatch(ctx, reader, writer, msgs, keys); err != nil {
+			return err
+		}
+	}
+	aiUsage.Print()
+	fmt.Println("🛑 Kafka-потребитель остановлен")
+	return nil
+}
+
+// fetchKafkaBatch — сообщения за kafkaBatchWindow (не больше kafkaBatchSize)
+func fetchKafkaBatch(ctx context.Context, reader *kafka.Reader) ([]kafka.Message, error) {
+	windowCtx, cancel := context.WithTimeout(ctx, kafkaBatchWindow)
+	defer cancel()
+	var msgs []kafka.Message
+	for len(msgs) < kafkaBatchSize {
+		m, err := reader.FetchMessage(windowCtx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				break // окно закрылось — обрабатываем, что успели получить
+			}
+			return msgs, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// processKafkaBatch — конвейер по пачке и коммит offset'ов по порядку.
+// Некорректные сообщения считаются обработанными (повтор не поможет); на первой
+// ошибке сохранения дальше не идём — коммитится только непрерывный префикс
+// обработанных сообщений каждой партиции (commitHandled), возвращается ошибка.
+func (e *Engine) processKafkaBatch(ctx context.Context, reader *kafka.Reader, writer *kafka.Writer, msgs []kafka.Message, keys *apiKeyPool) error {
+	var tickets []TicketInput
+	byIndex := make(map[int]int) // Index тикета → номер сообщения в msgs
+	handled := make([]bool, len(msgs))
+	for i, m := range msgs {
+		var t TicketInput
+		if err := json.Unmarshal(m.Value, &t); err != nil {
+			log.Printf("⚠️ Kafka %s/%d@%d: некорректный JSON, пропуск: %v", m.Topic, m.Partition, m.Offset, err)
+			handled[i] = true
+			continue
+		}
+		t.GUID = strings.TrimSpace(t.GUID)
+		if t.GUID == "" || (strings.TrimSpace(t.Text) == "" && strings.TrimSpace(t.Attachment) == "") {
+			log.Printf("⚠️ Kafka %s/%d@%d: нет guid или текста, пропуск", m.Topic, m.Partition, m.Offset)
+			handled[i] = true
+			continue
+		}
+		if _, ok := e.forTenant(t.Tenant); !ok {
+			log.Printf("⚠️ Kafka %s: неизвестный tenant_id '%s', пропуск", t.GUID, t.Tenant)
+			handled[i] = true
+			continue
+		}
+		if err := fillAge(&t); err != nil {
+			log.Printf("⚠️ Kafka %s: дата рождения: %v — возраст не учитывается", t.GUID, err)
+		}
+		if err := fillCreatedAt(&t); err != nil {
+			log.Printf("⚠️ Kafka %s: дата обращения: %v — в БД время обработки", t.GUID, err)
+		}
+		t.Index = len(tickets)
+		byIndex[t.Index] = i
+		tickets = append(tickets, t)
+	}
+	if len(tickets) == 0 {
+		if err := commitHandled(ctx, reader, msgs, handled); err != nil {
+			return fmt.Errorf("коммит пропущенных сообщений: %v", err)
+		}
+		return nil
+	}
+
+	fmt.Printf("\n📡 Kafka: пачка из %d тикетов\n", len(tickets))
+	applyRemoteOCR(ctx, tickets)
+	aiResults, results := e.routeTicketBatch(ctx, tickets, keys)
+	if ctx.Err() != nil {
+		return nil // offset'ы не закоммичены — пачка прочитается при следующем запуске
+	}
+
+	var saveErr error
+	for _, t := range tickets {
+		ai, r := aiResults[t.Index], results[t.Index]
+		if e.db != nil {
+			err := withDBRetry(context.WithoutCancel(ctx), dbSaveTimeout, func(ctx context.Context) error {
+				return saveTicketChainTx(ctx, e.db, t, ai, r)
+			})
+			if err != nil {
+				saveErr = fmt.Errorf("БД %s: %v (offset не закоммичен)", t.GUID, err)
+				break
+			}
+		}
+		if writer != nil {
+			value, _ := json.Marshal(r)
+			if err := writer.WriteMessages(ctx, kafka.Message{Key: []byte(t.GUID), Value: value}); err != nil {
+				saveErr = fmt.Errorf("выходной топик %s: %v (offset не закоммичен)", t.GUID, err)
+				break
+			}
+		}
+		handled[byIndex[t.Index]] = true
+	}
+	if err := commitHandled(ctx, reader, msgs, handled); err != nil {
+		return fmt.Errorf("коммит offset'ов: %v", err)
+	}
+	if saveErr != nil {
+		return saveErr
+	}
+	fmt.Printf("✅ Kafka: %d тикетов сохранено, offset'ы закоммичены\n", len(tickets))
+	return nil
+}
+
+// commitHandled — коммит каждой партиции до последнего сообщения непрерывного
+// префикса обработанных. Коммит offset'а подтверждает и все меньшие, поэтому
+// сообщение после необработанного не коммитится, даже если само обработано.
+// msgs — в порядке чтения (внутри партиции — по возрастанию offset'а).
+func commitHandled(ctx context.Context, reader *kafka.Reader, msgs []kafka.Message, handled []bool) error {
+	type partition struct {
+		topic string
+		id    int
+	}
+	last := make(map[partition]int)
+	blocked := make(map[partition]bool)
+	var order []partition
+	for i, m := range msgs {
+		p := partition{m.Topic, m.Partition}
+		if blocked[p] {
+			continue
+		}
+		if !handled[i] {
+			blocked[p] = true
+			continue
+		}
+		if _, ok := last[p]; !ok {
+			order = append(order, p)
+		}
+		last[p] = i
+	}
+	var commit []kafka.Message
+	for _, p := range order {
+		commit = append(commit, msgs[last[p]])
+	}
+	if len(commit) == 0 {
+		return nil
+	}
+	return reader.CommitMessages(ctx, commit...)
+}
+
+// routeTicketBatch — AI одним чанком (на тенанта), бизнес-правила, геокодирование
+// и роутинг для пачки (та же последовательность, что в processAllTickets и POST /route).
+// Индексы результатов — TicketInput.Index.
+func (e *Engine) routeTicketBatch(ctx context.Context, tickets []TicketInput, keys *apiKeyPool) (map[int]AIResult, map[int]RoutingResult) {
+	aiResults, err := e.analyzeByTenant(ctx, tickets, keys, kafkaBatchSize, 0)
+	if err != nil && ctx.Err() != nil {
+		return aiResults, nil
+	}
+	for _, t := range tickets {
+		r, ok := aiResults[t.Index]
+		if !ok {
+			r = fallbackAnalyze(t)
+		}
+		aiResults[t.Index] = applyPriorityRules(t, applyLinkSpam(t, r))
+	}
+
+	e.geocodeByTenant(ctx, tickets, aiResults)
+	if ctx.Err() != nil {
+		return aiResults, nil
+	}
+
+	results := make(map[int]RoutingResult, len(tickets))
+	for _, t := range tickets {
+		ai := aiResults[t.Index]
+		fbType, disagree := crossCheckAI(t, ai)
+		ai = resolveType(t, ai, fbType, disagree)
+		aiResults[t.Index] = ai
+
+		r := e.engineFor(t).buildRoutingResult(t, ai)
+		r.NeedsReview = disagree
+		r.ReviewReason = reviewReason(r)
+		results[t.Index] = r
+	}
+	return aiResults, results
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ПУЛ API-КЛЮЧЕЙ GEMINI — ротация при 429
+// ═══════════════════════════════════════════════════════════
+
+// keyCooldownCap — максимальный «отдых» ключа, который стабильно получает 429
+const keyCooldownCap = 5 * time.Minute
+
+// apiKeyPool — несколько ключей Gemini. На 429 текущий ключ уходит на паузу
+// (тем дольше, чем больше 429 подряд), запрос повторяется со следующим.
+// Спать приходится только когда на паузе все ключи.
+type apiKeyPool struct {
+	mu            sync.Mutex
+	keys          []string
+	cur           int
+	rateLimited   []int       // всего 429 по ключу — для итогового лога
+	consecutive   []int       // 429 подряд — растит паузу
+	cooldownUntil []time.Time // до этого момента ключ пропускается
+}
+
+// newAPIKeyPool — из GEMINI_API_KEYS ("k1,k2,k3") или одиночного GEMINI_API_KEY
+func newAPIKeyPool(keysCSV, single string) *apiKeyPool {
+	var keys []string
+	for _, k := range strings.Split(keysCSV, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 && strings.TrimSpace(single) != "" {
+		keys = []string{strings.TrimSpace(single)}
+	}
+	return &apiKeyPool{
+		keys:          keys,
+		rateLimited:   make([]int, len(keys)),
+		consecutive:   make([]int, len(keys)),
+		cooldownUntil: make([]time.Time, len(keys)),
+	}
+}
+
+func (p *apiKeyPool) Len() int { return len(p.keys) }
+
+// Acquire — первый доступный ключ начиная с текущего. Если все на паузе,
+// возвращает ok=false и сколько ждать до освобождения ближайшего.
+func (p *apiKeyPool) Acquire() (key string, idx int, wait time.Duration, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	soonest := time.Duration(-1)
+	for n := 0; n < len(p.keys); n++ {
+		i := (p.cur + n) % len(p.keys)
+		if d := p.cooldownUntil[i].Sub(now); d > 0 {
+			if soonest < 0 || d < soonest {
+				soonest = d
+			}
+			continue
+		}
+		p.cur = i
+		return p.keys[i], i, 0, true
+	}
+	return "", -1, soonest, false
+}
+
+// MarkRateLimited — 429 по ключу idx: пауза = Retry-After (или 60s) × число 429 подряд
+func (p *apiKeyPool) MarkRateLimited(idx int, retryAfter time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if retryAfter <= 0 {
+		retryAfter = rateLimitDefaultWait
+	}
+	p.rateLimited[idx]++
+	p.consecutive[idx]++
+	cooldown := retryAfter * time.Duration(p.consecutive[idx])
+	if cooldown > keyCooldownCap {
+		cooldown = keyCooldownCap
+	}
+	p.cooldownUntil[idx] = time.Now().Add(cooldown)
+	p.cur = (idx + 1) % len(p.keys)
+	if len(p.keys) > 1 {
+		fmt.Printf("🔑 Ключ #%d получил 429 (%d подряд) → пауза %v, переключаемся на следующий\n",
+			idx+1, p.consecutive[idx], cooldown.Round(time.Second))
+	}
+}
+
+// MarkOK — успешный запрос сбрасывает серию 429
+func (p *apiKeyPool) MarkOK(idx int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.consecutive[idx] = 0
+}
+
+// Report — строка со счётчиками 429 по ключам (ключи не печатаются)
+func (p *apiKeyPool) Report() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	parts := make([]string, len(p.keys))
+	for i, n := range p.rateLimited {
+		parts[i] = fmt.Sprintf("#%d: %d", i+1, n)
+	}
+	return strings.Join(parts, ", ")
+}
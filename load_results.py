@@ -44,7 +44,7 @@ def load_results():
 
         for _, row in df.iterrows():
             guid = clean_text(row.get('GUID'))
-            if not guid:
+            if not guid or guid == 'ИТОГО':  # строки итогов (go run . -totals)
                 continue
                 
             ticket = Ticket.objects.filter(guid=guid).first()
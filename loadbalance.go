@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  РАВНОМЕРНОСТЬ НАГРУЗКИ — по офисам, после запуска
+// ═══════════════════════════════════════════════════════════
+
+// loadImbalanceCV — коэффициент вариации нагрузки (σ / среднее), выше
+// которого офис помечается ⚠️: кому-то из менеджеров досталось заметно больше
+const loadImbalanceCV = 0.5
+
+// officeBalance — нагрузка менеджеров одного офиса
+type officeBalance struct {
+	Office   string
+	Managers int
+	Assigned int // назначено за запуск (Workload − нагрузка из managers.csv)
+	Min, Max int // итоговый Workload
+	Avg, Std float64
+}
+
+// loadBalance — отчёт по офисам с менеджерами в порядке названий (под e.mu)
+func (e *Engine) loadBalance() []officeBalance {
+	var report []officeBalance
+	for office, managers := range e.managers {
+		if len(managers) == 0 {
+			continue
+		}
+		b := officeBalance{Office: office, Managers: len(managers), Min: math.MaxInt}
+		sum := 0
+		for _, m := range managers {
+			b.Assigned += m.Workload - m.baseWorkload
+			b.Min = min(b.Min, m.Workload)
+			b.Max = max(b.Max, m.Workload)
+			sum += m.Workload
+		}
+		b.Avg = float64(sum) / float64(len(managers))
+		for _, m := range managers {
+			d := float64(m.Workload) - b.Avg
+			b.Std += d * d
+		}
+		b.Std = math.Sqrt(b.Std / float64(len(managers)))
+		report = append(report, b)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Office < report[j].Office })
+	return report
+}
+
+// printLoadBalance — таблица нагрузки по офисам, где за запуск что-то
+// назначено; withTenant — подписать тенанта (их несколько)
+func (e *Engine) printLoadBalance(withTenant bool) {
+	e.mu.Lock()
+	report := e.loadBalance()
+	e.mu.Unlock()
+
+	title := "Нагрузка менеджеров по офисам (итоговый Workload)"
+	if withTenant {
+		title += " (тенант " + e.tenant + ")"
+	}
+	printed := false
+	for _, b := range report {
+		if b.Assigned == 0 {
+			continue
+		}
+		if !printed {
+			fmt.Println("\n  " + title + ":")
+			fmt.Printf("    %-24s %6s %9s %5s %5s %7s %6s\n", "Офис", "Менедж", "Назначено", "Мин", "Макс", "Средн", "σ")
+			printed = true
+		}
+		mark := ""
+		if b.Avg > 0 && b.Std/b.Avg > loadImbalanceCV {
+			mark = " ⚠️"
+		}
+		fmt.Printf("    %-24s %6d %9d %5d %5d %7.1f %6.1f%s\n",
+			b.Office, b.Managers, b.Assigned, b.Min, b.Max, b.Avg, b.Std, mark)
+	}
+}
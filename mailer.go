@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  РАССЫЛКА РАБОЧИХ СПИСКОВ — -mail-worklists, SMTP_*
+// ═══════════════════════════════════════════════════════════
+
+// smtpConfig — SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD, SMTP_FROM
+type smtpConfig struct {
+	Host, Port     string
+	User, Password string
+	From           string
+}
+
+// loadSMTPConfig — настройки SMTP из окружения; без SMTP_USER — без авторизации
+func loadSMTPConfig() smtpConfig {
+	c := smtpConfig{
+		Host:     getEnv("SMTP_HOST", ""),
+		Port:     getEnv("SMTP_PORT", "587"),
+		User:     getEnv("SMTP_USER", ""),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+	c.From = getEnv("SMTP_FROM", c.User)
+	return c
+}
+
+// sendWorklists — каждому менеджеру с email (колонка Email в managers.csv)
+// его файл из dir (exportWorklists) вложением. Менеджеры без email
+// пропускаются; ошибка отправки логируется, запуск продолжается.
+func (e *Engine) sendWorklists(cfg smtpConfig, dir string, results []RoutingResult) {
+	type recipient struct {
+		manager *Manager
+		file    string
+		tickets int
+	}
+	var order []string
+	recipients := make(map[string]*recipient)
+	noEmail := 0
+	for _, r := range results {
+		te, ok := e.forTenant(r.Tenant)
+		if !ok {
+			continue
+		}
+		m := te.findManager(r.AssignedOffice, r.ManagerName)
+		if m == nil {
+			continue
+		}
+		key := tenantOffice(te.tenant, m.Office) + "/" + m.Name
+		if rc, ok := recipients[key]; ok {
+			rc.tickets++
+			continue
+		}
+		if m.Email == "" {
+			noEmail++
+		}
+		recipients[key] = &recipient{m, worklistFileName(te.tenant, r.AssignedOffice, m.Name), 1}
+		order = append(order, key)
+	}
+
+	sent, failed := 0, 0
+	for _, key := range order {
+		rc := recipients[key]
+		if rc.manager.Email == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, rc.file))
+		if err == nil {
+			subject := fmt.Sprintf("FIRE: рабочий список — %d обращений (%s)", rc.tickets, time.Now().Format("02.01.2006"))
+			body := fmt.Sprintf("%s, во вложении обращения, назначенные вам в этом запуске (%d), по убыванию приоритета.\r\n",
+				rc.manager.Name, rc.tickets)
+			err = sendMailWithCSV(cfg, rc.manager.Email, subject, body, rc.file, data)
+		}
+		if err != nil {
+			failed++
+			log.Printf("⚠️ Рабочий список %s <%s> не отправлен: %v", key, rc.manager.Email, err)
+			continue
+		}
+		sent++
+	}
+	fmt.Printf("📧 Рабочие списки по почте: отправлено %d", sent)
+	if failed > 0 {
+		fmt.Printf(", ошибок %d", failed)
+	}
+	if noEmail > 0 {
+		fmt.Printf(", без email пропущено %d", noEmail)
+	}
+	fmt.Println()
+}
+
+// findManager — менеджер офиса по имени (nil — не найден или «Не найден»)
+func (e *Engine) findManager(office, name string) *Manager {
+	for _, m := range e.managers[office] {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// sendMailWithCSV — письмо с одним CSV-вложением (multipart/mixed)
+func sendMailWithCSV(cfg smtpConfig, to, subject, body, fileName string, data []byte) error {
+	const boundary = "fire-worklist-boundary"
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&msg, "--%s\r\n", boundary)
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64Lines(&msg, []byte(body))
+
+	fmt.Fprintf(&msg, "--%s\r\n", boundary)
+	fmt.Fprintf(&msg, "Content-Type: text/csv; charset=UTF-8; name=%q\r\n", mime.BEncoding.Encode("UTF-8", fileName))
+	fmt.Fprintf(&msg, "Content-Disposition: attachment; filename=%q\r\n", mime.BEncoding.Encode("UTF-8", fileName))
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64Lines(&msg, data)
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+
+	var auth smtp.Auth
+	if cfg.User != "" {
+		auth = smtp.PlainAuth("", cfg.User, cfg.Password, cfg.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(cfg.Host, cfg.Port), auth, cfg.From, []string{to}, msg.Bytes())
+}
+
+// writeBase64Lines — base64 строками по 76 символов (RFC 2045)
+func writeBase64Lines(buf *bytes.Buffer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		buf.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	buf.WriteString(enc + "\r\n")
+}
+
+// parseEmail — адрес из колонки Email (net/mail; «Имя <адрес>» → адрес);
+// некорректный — "" (менеджер пропускается). Адрес уходит в заголовки и RCPT,
+// поэтому переводы строк, пробелы, кавычки и несколько адресов отбрасываются.
+func parseEmail(s string) string {
+	addr, err := mail.ParseAddress(strings.TrimSpace(s))
+	if err != nil || strings.ContainsAny(addr.Address, " ,;\"<>\r\n\t") {
+		return ""
+	}
+	if at := strings.Index(addr.Address, "@"); at <= 0 || at == len(addr.Address)-1 {
+		return ""
+	}
+	return addr.Address
+}
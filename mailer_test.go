@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestParseEmail(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{"manager@ffin.kz", "manager@ffin.kz"},
+		{"  manager@ffin.kz ", "manager@ffin.kz"},
+		{"Айгуль <aigul@ffin.kz>", "aigul@ffin.kz"},
+		{"", ""},
+		{"manager", ""},
+		{"@ffin.kz", ""},
+		{"manager@", ""},
+		{"a@ffin.kz, b@ffin.kz", ""},
+		{"a@ffin.kz;b@ffin.kz", ""},
+		{"a@ffin.kz\r\nBcc: x@evil.com", ""},
+		{"a@ffin.kz\nBcc: x@evil.com", ""},
+		{"a\r\n@ffin.kz", ""},
+		{`"a b"@ffin.kz`, ""},
+	} {
+		if got := parseEmail(c.in); got != c.want {
+			t.Errorf("parseEmail(%q) = %q, ожидалось %q", c.in, got, c.want)
+		}
+	}
+}
@@ -1,19 +1,24 @@
 package main
 
 import (
-	"bytes"
-	"encoding/csv"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math"
+	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -30,22 +35,34 @@ type Manager struct {
 	Office   string
 	Skills   []string // VIP, ENG, KZ
 	Workload int
+	Email    string // необязательная 6-я колонка managers.csv (-mail-worklists)
+
+	baseWorkload int // Workload из managers.csv — к нему возвращает ResetRoutingState
 }
 
 // TicketInput — входные данные одного тикета
 type TicketInput struct {
-	Index      int
-	GUID       string
-	Gender     string
-	Birthdate  string
-	Text       string
-	Attachment string
-	Segment    string // Mass | VIP | Priority
-	Country    string
-	Oblast     string
-	RawCity    string
-	Street     string
-	House      string
+	Index      int    `json:"-"`
+	GUID       string `json:"guid"`
+	Gender     string `json:"gender"`
+	Birthdate  string `json:"birthdate"`
+	Text       string `json:"text"`
+	Attachment string `json:"attachment"`
+	Segment    string `json:"segment"` // Mass | VIP | Priority
+	Country    string `json:"country"`
+	Oblast     string `json:"oblast"`
+	RawCity    string `json:"city"`
+	Street     string `json:"street"`
+	House      string `json:"house"`
+	Tenant     string `json:"tenant_id"` // "" — тенант default
+	Age        int    `json:"-"`         // Возраст по Birthdate (0 — неизвестен)
+	OCRText    string `json:"-"`         // Текст вложения (-ocr), если Text пуст
+	StoredHash string `json:"-"`         // content_hash из БД (-regeo): текст может быть маскированным
+
+	// CreatedAt — дата обращения из источника ("" — неизвестна, в БД NOW());
+	// CreatedTime — она же после fillCreatedAt
+	CreatedAt   string    `json:"created_at,omitempty"`
+	CreatedTime time.Time `json:"-"`
 }
 
 // AIResult — результат AI-анализа одного тикета
@@ -53,32 +70,67 @@ type AIResult struct {
 	Type          string  // Жалоба | Смена данных | Консультация | Претензия | Неработоспособность приложения | Мошеннические действия | Спам
 	Sentiment     string  // Позитивный | Нейтральный | Негативный
 	Language      string  // RU | KZ | ENG
+	AltLanguage   string  // Второй язык смешанного обращения (KZ с английскими терминами); "" — нет
 	Priority      string  // "1"-"10"
+	ModelPriority string  // Приоритет до правил (applyPriorityRules): модель, Fallback или спам по ссылкам
 	Summary       string  // Краткая выжимка + рекомендация (на языке обращения)
-	NearestOffice string  // Офис из knownOffices (финальный, после геокодирования)
+	NearestOffice string  // Офис из Engine.offices (финальный, после геокодирования)
 	GeoLat        float64 // Широта клиента (Nominatim)
 	GeoLon        float64 // Долгота клиента (Nominatim)
-	GeoMethod     string  // "nominatim" | "llm" | "50/50"
-	Source        string  // Gemini | Fallback
+	GeoMethod     string  // "nominatim" | "city-memo" | "alias" | "llm" | "oblast-centroid" | "too_far" | "foreign" | "unknown"
+	GeoPrecision  string  // Точность геокодирования: house | street | city | region ("" — не геокодировался)
+	Source        string  // Gemini | Ollama | Fallback | Prefilter
+	RawAI         string  // Объект тикета из ответа модели (JSON, аудит); пусто для Fallback
+	PromptVersion string  // PromptVersion, с которым получен ответ; пусто для Fallback
+	LinkDomains   string  // Домены ссылок из текста через запятую (аудит спама)
+	DerivedOblast string  // Область по Nominatim, если в тикете пустая (исходный Oblast не меняется)
+	Confidence    float64 // Самооценка модели 0–1 (нет в ответе → 1.0); для Fallback не используется
+	TypeSource    string  // Кто определил Type: AI | Fallback | AI+Fallback (resolveType)
+	SpamDomain    string  // Рекламный домен: по нему тикет признан спамом (spamPrefilter, блок-лист) или отмечен для аудита
 }
 
 // RoutingResult — итог роутинга одного тикета
 type RoutingResult struct {
-	GUID           string
-	CityOriginal   string // Город_оригинал
-	Segment        string
-	Type           string
-	Sentiment      string
-	Language       string
-	Priority       string
-	Summary        string
-	ManagerName    string
-	ManagerRole    string
-	AssignedOffice string
-	RoutingReason  string // Причина_роутинга
-	GeoMethod      string // Метод геокодирования
-	Source         string // AI_Источник: Gemini | Fallback
-	IsEscalated    bool   // Был ли тикет эскалирован в ГО
+	GUID           string  `json:"guid"`
+	CityOriginal   string  `json:"city_original"` // Город_оригинал
+	Segment        string  `json:"segment"`
+	Type           string  `json:"type"`
+	Sentiment      string  `json:"sentiment"`
+	Language       string  `json:"language"`
+	Priority       string  `json:"priority"`
+	Summary        string  `json:"summary"`
+	ManagerName    string  `json:"manager_name"`
+	ManagerRole    string  `json:"manager_role"`
+	AssignedOffice string  `json:"assigned_office"`
+	RoutingReason  string  `json:"routing_reason"` // Причина_роутинга
+	GeoMethod      string  `json:"geo_method"`     // Метод геокодирования
+	GeoPrecision   string  `json:"geo_precision"`  // Точность_гео: house | street | city | region
+	Source         string  `json:"source"`         // AI_Источник: Gemini | Ollama | Fallback | Prefilter
+	IsEscalated    bool    `json:"is_escalated"`   // Был ли тикет эскалирован в ГО
+	GeoLat         float64 `json:"geo_lat"`        // Широта клиента (0 — неизвестна)
+	GeoLon         float64 `json:"geo_lon"`        // Долгота клиента (0 — неизвестна)
+	Attachment     string  `json:"attachment"`     // Вложения (имя файла из тикета)
+	DistanceKm     float64 `json:"distance_km"`    // Клиент → офис назначения, км (0 — неизвестно)
+	AltOffices     string  `json:"alt_offices"`    // Топ-3 ближайших офиса: «Офис (N км); ...»
+	NeedsReview    bool    `json:"needs_review"`   // Тип от AI расходится с Keyword Fallback
+	ReviewReason   string  `json:"review_reason"`  // Причина постановки в review_queue ("" — не нужно)
+	LinkDomains    string  `json:"link_domains"`   // Домены ссылок из обращения
+	Confidence     float64 `json:"confidence"`     // Уверенность AI 0–1 (Gemini; для Fallback — 0)
+	Tenant         string  `json:"tenant_id"`      // Тенант, по офисам которого роутился тикет
+	TypeSource     string  `json:"type_source"`    // Источник_типа: AI | Fallback | AI+Fallback
+
+	// PriorityCategory — Категория_приоритета: Low | Medium | High | Critical (-priority-category)
+	PriorityCategory string `json:"priority_category,omitempty"`
+	// AttachmentType — Тип_вложения: image | pdf | document | archive | unknown ("" — нет вложения)
+	AttachmentType string `json:"attachment_type,omitempty"`
+	// SpamDomain — Домен_спама: рекламный домен из ссылок или отправителя (спам или аудит)
+	SpamDomain string `json:"spam_domain,omitempty"`
+	// DueAt — Срок_SLA: крайний срок ответа (RFC 3339) по приоритету от даты обращения ("" — без срока)
+	DueAt string `json:"due_at,omitempty"`
+	// ResponseMin — Ожидаемый_ответ_мин: оценка времени ответа (estimateResponse), 0 — нет оценки
+	ResponseMin int `json:"estimated_response_min,omitempty"`
+	// VIPGap — VIP/Priority-тикет из офиса без VIP-менеджеров (итоги запуска)
+	VIPGap bool `json:"-"`
 }
 
 // ═══════════════════════════════════════════════════════════
@@ -92,14 +144,32 @@ type GeoPoint struct {
 }
 
 var (
-	ManagersMap     = make(map[string][]*Manager)
-	RRCounters      = make(map[string]int)
-	foreignSplitCtr int
-	HQ_CITIES       = []string{"Астана", "Алматы"}
-	knownOffices    []string
-
-	// OfficeCoords — координаты офисов для расчёта реального расстояния
-	OfficeCoords = map[string]GeoPoint{
+	HQ_CITIES = []string{"Астана", "Алматы"}
+
+	// aiTimeout — предел на один HTTP-запрос к Gemini (AI_TIMEOUT, по умолчанию 120s:
+	// батч из 10 тикетов с большим промптом генерируется долго)
+	aiTimeout = 120 * time.Second
+
+	// aiMaxRetries — попыток на один чанк до Keyword Fallback (AI_MAX_RETRIES)
+	aiMaxRetries = 3
+
+	// resultsStdout — куда писать CSV при -out - (исходный stdout процесса)
+	resultsStdout io.Writer = os.Stdout
+
+	// aiModel — модель Gemini (AI_MODEL)
+	aiModel = "gemini-2.5-flash"
+
+	// aiMinConfidence — ниже этой уверенности тикет идёт на проверку, а при
+	// расхождении с ключевыми словами тип берётся из Keyword Fallback (AI_MIN_CONFIDENCE)
+	aiMinConfidence = 0.6
+
+	// MaxOfficeDistanceKm — если ближайший офис дальше, адрес считается
+	// неразрешённым → 50/50 Астана/Алматы (MAX_OFFICE_DISTANCE_KM, 0 — без ограничения)
+	MaxOfficeDistanceKm = 0.0
+
+	// defaultOfficeCoords — координаты офисов для расчёта реального расстояния
+	// (копируются в каждый Engine)
+	defaultOfficeCoords = map[string]GeoPoint{
 		"Алматы":           {43.2220, 76.8512},
 		"Астана":           {51.1801, 71.4598},
 		"Шымкент":          {42.3417, 69.5901},
@@ -118,38 +188,29 @@ var (
 	}
 )
 
-func loadOffices(fp string) {
-	file, err := os.Open(fp)
+// LoadOffices — список офисов из business_units; ошибку чтения решает вызывающий
+func (e *Engine) LoadOffices(fp string) error {
+	records, err := readTable(fp)
 	if err != nil {
-		log.Fatalf("❌ Ошибка открытия %s: %v", fp, err)
-	}
-	defer file.Close()
-
-	records, err := csv.NewReader(file).ReadAll()
-	if err != nil {
-		log.Fatalf("❌ Ошибка чтения %s: %v", fp, err)
+		return fmt.Errorf("чтение %s: %v", fp, err)
 	}
 
 	for i, row := range records {
 		if i == 0 || len(row) < 2 {
 			continue
 		}
-		city := strings.TrimSpace(strings.TrimPrefix(row[0], "\uFEFF"))
-		knownOffices = append(knownOffices, city)
+		city := row[0]
+		e.offices = append(e.offices, city)
 	}
-	fmt.Printf("✅ Офисов загружено: %d → %v\n", len(knownOffices), knownOffices)
+	fmt.Printf("✅ Офисов загружено: %d → %v\n", len(e.offices), e.offices)
+	return nil
 }
 
-func loadManagers(fp string) {
-	file, err := os.Open(fp)
-	if err != nil {
-		log.Fatalf("❌ Ошибка открытия %s: %v", fp, err)
-	}
-	defer file.Close()
-
-	records, err := csv.NewReader(file).ReadAll()
+// LoadManagers — менеджеры по офисам; ошибку чтения решает вызывающий
+func (e *Engine) LoadManagers(fp string) error {
+	records, err := readTable(fp)
 	if err != nil {
-		log.Fatalf("❌ Ошибка чтения %s: %v", fp, err)
+		return fmt.Errorf("чтение %s: %v", fp, err)
 	}
 
 	for i, row := range records {
@@ -159,34 +220,90 @@ func loadManagers(fp string) {
 		rawSkills := strings.Split(row[3], ",")
 		var skills []string
 		for _, s := range rawSkills {
-			skills = append(skills, strings.TrimSpace(s))
+			skills = append(skills, normalizeField(s))
 		}
-		workload, _ := strconv.Atoi(strings.TrimSpace(row[4]))
-		name := strings.TrimSpace(strings.TrimPrefix(row[0], "\uFEFF"))
-		role := strings.TrimSpace(strings.TrimPrefix(row[1], "\uFEFF"))
-		office := strings.TrimSpace(row[2])
+		workload, _ := strconv.Atoi(row[4])
+		name, role, office := row[0], row[1], row[2]
 
 		m := &Manager{
-			Name:     name,
-			Role:     role,
-			Office:   office,
-			Skills:   skills,
-			Workload: workload,
+			Name:         name,
+			Role:         role,
+			Office:       office,
+			Skills:       skills,
+			Workload:     workload,
+			baseWorkload: workload,
+		}
+		if len(row) > 5 && strings.TrimSpace(row[5]) != "" {
+			if m.Email = parseEmail(row[5]); m.Email == "" {
+				fmt.Printf("⚠️ %s: некорректный email '%s' — рабочий список не отправляется\n", name, strings.TrimSpace(row[5]))
+			}
 		}
-		ManagersMap[office] = append(ManagersMap[office], m)
+		e.managers[office] = append(e.managers[office], m)
 	}
 
 	total := 0
-	for _, v := range ManagersMap {
+	for _, v := range e.managers {
 		total += len(v)
 	}
-	fmt.Printf("✅ Менеджеров загружено: %d по %d офисам\n", total, len(ManagersMap))
+	if total == 0 {
+		// Обычно это неверное число колонок в каждой строке: без менеджеров
+		// все тикеты ушли бы в «Менеджер не найден» без понятной причины
+		return fmt.Errorf("%s: не загружено ни одного менеджера из %d строк (нужно ≥5 колонок: ФИО, Должность, Офис, Навыки, Нагрузка)",
+			fp, max(len(records)-1, 0))
+	}
+	fmt.Printf("✅ Менеджеров загружено: %d по %d офисам\n", total, len(e.managers))
+	return nil
+}
+
+// ResetRoutingState — обнуляет Round Robin (rrCounters, hqSplit) и
+// возвращает нагрузку менеджеров к значениям из managers.csv. При одинаковом
+// входе роутинг после сброса повторяется один в один — регрессионные и
+// golden-прогоны вызывают его перед каждым запуском.
+func (e *Engine) ResetRoutingState() {
+	for _, te := range e.tenants {
+		te.ResetRoutingState()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rrCounters = make(map[string]int)
+	e.hqSplit = make(map[string]int)
+	e.vipGapEscalations = make(map[string]int)
+	e.vipGapTickets = nil
+	for _, pool := range e.managers {
+		for _, m := range pool {
+			m.Workload = m.baseWorkload
+		}
+	}
 }
 
 // ═══════════════════════════════════════════════════════════
 //  ВСПОМОГАТЕЛЬНЫЕ ФУНКЦИИ
 // ═══════════════════════════════════════════════════════════
 
+// getEnv — переменная окружения или значение по умолчанию
+func getEnv(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// getEnvDuration — длительность из окружения: "90s", "2m" или просто секунды ("120")
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d
+	}
+	log.Printf("⚠️ %s=%q не распознан, используется %v", key, v, def)
+	return def
+}
+
 func isHighPriority(priority string) bool {
 	p, err := strconv.Atoi(strings.TrimSpace(priority))
 	if err != nil {
@@ -195,9 +312,84 @@ func isHighPriority(priority string) bool {
 	return p >= 7
 }
 
-func needsVIP(segment string) bool {
-	s := strings.TrimSpace(segment)
-	return s == "VIP" || s == "Priority"
+// ═══════════════════════════════════════════════════════════
+//  ДАТА РОЖДЕНИЯ → ВОЗРАСТ; пожилые клиенты выше в очереди
+// ═══════════════════════════════════════════════════════════
+
+// birthdateLayouts — форматы, встречающиеся в выгрузках («2002-07-11 0:00» и т.п.)
+var birthdateLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02.01.2006",
+	"02.01.2006 15:04",
+	"02/01/2006",
+}
+
+const (
+	seniorAge           = 65 // с этого возраста клиент считается пожилым
+	seniorPriorityBoost = 2  // на сколько поднимается приоритет (не выше 10)
+)
+
+// seniorPriorityTypes — типы обращений, где возраст повышает приоритет
+// (SENIOR_PRIORITY_TYPES через запятую; пустое значение отключает правило)
+var seniorPriorityTypes = map[string]bool{
+	"Претензия":              true,
+	"Мошеннические действия": true,
+}
+
+// parseBirthdate — дата рождения в любом из birthdateLayouts
+func parseBirthdate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range birthdateLayouts {
+		if d, err := time.Parse(layout, s); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неизвестный формат даты '%s'", s)
+}
+
+// ageAt — полных лет на момент now
+func ageAt(birth, now time.Time) int {
+	age := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		age--
+	}
+	return age
+}
+
+// fillAge — заполняет t.Age по Birthdate. Пустая дата — не ошибка (возраст неизвестен),
+// нераспознанная или неправдоподобная (в будущем, старше 120 лет) — ошибка.
+func fillAge(t *TicketInput) error {
+	if strings.TrimSpace(t.Birthdate) == "" {
+		return nil
+	}
+	birth, err := parseBirthdate(t.Birthdate)
+	if err != nil {
+		return err
+	}
+	age := ageAt(birth, time.Now())
+	if age < 0 || age > 120 {
+		return fmt.Errorf("неправдоподобная дата '%s' (возраст %d)", t.Birthdate, age)
+	}
+	t.Age = age
+	return nil
+}
+
+// applySeniorPriority — клиентам 65+ по seniorPriorityTypes приоритет +2 (не выше 10)
+func applySeniorPriority(t TicketInput, r AIResult) AIResult {
+	if t.Age < seniorAge || !seniorPriorityTypes[r.Type] {
+		return r
+	}
+	p, err := strconv.Atoi(strings.TrimSpace(r.Priority))
+	if err != nil || p >= 10 {
+		return r
+	}
+	boosted := min(p+seniorPriorityBoost, 10)
+	fmt.Printf("   🧓 %s | Клиенту %d лет, %s → приоритет %d (было %d)\n",
+		t.GUID[:min(8, len(t.GUID))], t.Age, r.Type, boosted, p)
+	r.Priority = strconv.Itoa(boosted)
+	return r
 }
 
 func containsAny(s string, words ...string) bool {
@@ -211,27 +403,90 @@ func containsAny(s string, words ...string) bool {
 }
 
 // normalizeOfficeName — возвращает точное название офиса с правильным регистром
-func normalizeOfficeName(office string) string {
+func (e *Engine) normalizeOfficeName(office string) string {
 	office = strings.TrimSpace(office)
-	for _, o := range knownOffices {
+	for _, o := range e.offices {
 		if strings.EqualFold(o, office) {
 			return o
 		}
 	}
 	// Нечёткое совпадение
-	for _, o := range knownOffices {
+	for _, o := range e.offices {
 		if strings.Contains(strings.ToLower(office), strings.ToLower(o)) ||
 			strings.Contains(strings.ToLower(o), strings.ToLower(office)) {
 			return o
 		}
 	}
+	// Опечатки LLM («Уст-Каменогорск», «Шимкент.») — ближайший офис по Левенштейну
+	if o := e.fuzzyOfficeMatch(office); o != "" {
+		fmt.Printf("   🔤 Офис '%s' исправлен на '%s' (Левенштейн)\n", office, o)
+		return o
+	}
 	return ""
 }
 
+// fuzzyOfficeMatch — известный офис с расстоянием правки ≤2 и не больше четверти
+// длины названия; при равных кандидатах — "" (неоднозначно, лучше не угадывать)
+func (e *Engine) fuzzyOfficeMatch(office string) string {
+	name := []rune(strings.ToLower(strings.Trim(office, " .,;:!?\"'«»")))
+	if len(name) == 0 {
+		return ""
+	}
+	best, bestDist, tie := "", 3, false
+	for _, o := range e.offices {
+		candidate := []rune(strings.ToLower(o))
+		d := levenshtein(name, candidate)
+		if d > 2 || d*4 > len(candidate) {
+			continue
+		}
+		switch {
+		case d < bestDist:
+			best, bestDist, tie = o, d, false
+		case d == bestDist:
+			tie = true
+		}
+	}
+	if tie {
+		return ""
+	}
+	return best
+}
+
+// levenshtein — расстояние правки по рунам (кириллица — не по байтам)
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(min(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
 // ═══════════════════════════════════════════════════════════
 //  ГЕОКОДИРОВАНИЕ — Nominatim (OpenStreetMap) + Haversine
 // ═══════════════════════════════════════════════════════════
 
+// distanceToOffice — км от клиента до офиса; 0, если координаты клиента
+// неизвестны (иностранцы, 50/50, LLM-геолокация) или офиса нет в officeCoords
+func (e *Engine) distanceToOffice(lat, lon float64, office string) float64 {
+	coords, ok := e.officeCoords[office]
+	if !ok || (lat == 0 && lon == 0) {
+		return 0
+	}
+	return haversine(lat, lon, coords.Lat, coords.Lon)
+}
+
 // haversine — расстояние между двумя точками в километрах
 func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 	const R = 6371.0
@@ -243,28 +498,58 @@ func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 	return R * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
 }
 
-// findNearestOfficeByCoords — ближайший офис по координатам (Haversine)
-func findNearestOfficeByCoords(lat, lon float64) string {
-	bestOffice := ""
-	bestDist := 1e18
-	for _, office := range knownOffices {
-		coords, ok := OfficeCoords[office]
+// officeDistance — офис и расстояние до него от клиента, км
+type officeDistance struct {
+	Office string
+	DistKm float64
+}
+
+// nearestOffices — до n ближайших известных офисов по возрастанию расстояния (Haversine)
+func (e *Engine) nearestOffices(lat, lon float64, n int) []officeDistance {
+	var all []officeDistance
+	for _, office := range e.offices {
+		coords, ok := e.officeCoords[office]
 		if !ok {
 			continue
 		}
-		d := haversine(lat, lon, coords.Lat, coords.Lon)
-		if d < bestDist {
-			bestDist = d
-			bestOffice = office
-		}
+		all = append(all, officeDistance{office, haversine(lat, lon, coords.Lat, coords.Lon)})
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].DistKm < all[j].DistKm })
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// findNearestOfficeByCoords — ближайший офис по координатам (Haversine) и расстояние до него, км
+func (e *Engine) findNearestOfficeByCoords(lat, lon float64) (string, float64) {
+	nearest := e.nearestOffices(lat, lon, 1)
+	if len(nearest) == 0 {
+		return "", 0
+	}
+	best := nearest[0]
+	fmt.Printf("   📐 Haversine: ближайший офис '%s' (%.0f км)\n", best.Office, best.DistKm)
+	return best.Office, best.DistKm
+}
+
+// formatAltOffices — топ-3 ближайших офиса строкой «Офис (N км); ...» для ручного
+// переназначения; пусто, если координаты клиента неизвестны
+func (e *Engine) formatAltOffices(lat, lon float64) string {
+	if lat == 0 && lon == 0 {
+		return ""
+	}
+	var parts []string
+	for _, o := range e.nearestOffices(lat, lon, 3) {
+		parts = append(parts, fmt.Sprintf("%s (%.0f км)", o.Office, o.DistKm))
 	}
-	fmt.Printf("   📐 Haversine: ближайший офис '%s' (%.0f км)\n", bestOffice, bestDist)
-	return bestOffice
+	return strings.Join(parts, "; ")
 }
 
-// geocodeAddress — геокодирование через Nominatim OpenStreetMap
-// Возвращает (lat, lon, ok). При ошибке ok=false.
-func geocodeAddress(country, oblast, city, street, house string) (float64, float64, bool) {
+// geocodeAddress — геокодирование через Nominatim (публичный OpenStreetMap или NOMINATIM_URL)
+// Возвращает (lat, lon, region, precision, ok): region — область из address.state (может
+// быть пустой), precision — точность совпадения по place_rank/class (nominatimPrecision).
+// При ошибке ok=false.
+func geocodeAddress(ctx context.Context, country, oblast, city, street, house string) (float64, float64, string, string, bool) {
 	// Составляем строку запроса из доступных полей
 	parts := []string{}
 	if house != "" && street != "" {
@@ -282,112 +567,232 @@ func geocodeAddress(country, oblast, city, street, house string) (float64, float
 	}
 
 	if len(parts) == 0 {
-		return 0, 0, false
+		return 0, 0, "", "", false
 	}
 
-	query := strings.Join(parts, ", ")
-	encoded := strings.ReplaceAll(query, " ", "+")
-	url := "https://nominatim.openstreetmap.org/search?q=" + encoded + "&format=json&limit=1&countrycodes=kz"
+	searchURL := nominatimSearchURL(strings.Join(parts, ", "))
 
 	client := &http.Client{Timeout: 5 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return 0, 0, false
-	}
-	// Nominatim требует User-Agent
-	req.Header.Set("User-Agent", "FIRE-RoutingEngine/6.0 (freedom.broker)")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, 0, false
-	}
-	defer resp.Body.Close()
-
 	var results []struct {
-		Lat string `json:"lat"`
-		Lon string `json:"lon"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil || len(results) == 0 {
-		return 0, 0, false
+		Lat       string `json:"lat"`
+		Lon       string `json:"lon"`
+		Class     string `json:"class"`
+		Type      string `json:"type"`
+		PlaceRank int    `json:"place_rank"`
+		Address   struct {
+			State  string `json:"state"`
+			Region string `json:"region"`
+		} `json:"address"`
+	}
+	for attempt := 1; ; attempt++ {
+		// Все запросы к Nominatim идут через общий адаптивный лимитер
+		if !nominatimLimiter.Wait(ctx) {
+			return 0, 0, "", "", false
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+		if err != nil {
+			return 0, 0, "", "", false
+		}
+		// Nominatim требует User-Agent
+		req.Header.Set("User-Agent", "FIRE-RoutingEngine/6.0 (freedom.broker)")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			metricNominatimRequests.WithLabelValues("error").Inc()
+			return 0, 0, "", "", false
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			resp.Body.Close()
+			metricNominatimRequests.WithLabelValues("throttled").Inc()
+			nominatimLimiter.Throttled()
+			if attempt >= nominatimMaxAttempts {
+				return 0, 0, "", "", false
+			}
+			continue
+		}
+		nominatimLimiter.Success()
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			metricNominatimRequests.WithLabelValues("error").Inc()
+			return 0, 0, "", "", false
+		}
+		err = json.NewDecoder(resp.Body).Decode(&results)
+		resp.Body.Close()
+		if err != nil || len(results) == 0 {
+			metricNominatimRequests.WithLabelValues("empty").Inc()
+			return 0, 0, "", "", false
+		}
+		break
 	}
+	metricNominatimRequests.WithLabelValues("ok").Inc()
 
 	lat, err1 := strconv.ParseFloat(results[0].Lat, 64)
 	lon, err2 := strconv.ParseFloat(results[0].Lon, 64)
 	if err1 != nil || err2 != nil {
-		return 0, 0, false
+		return 0, 0, "", "", false
 	}
-	return lat, lon, true
+	region := results[0].Address.State
+	if region == "" {
+		region = results[0].Address.Region
+	}
+	precision := nominatimPrecision(results[0].Class, results[0].Type, results[0].PlaceRank)
+	return lat, lon, region, precision, true
 }
 
 // resolveOfficeForTicket — определяет офис через:
-//  1. Nominatim геокодирование + Haversine (приоритет)
+//  1. Геокодирование (e.geocoder — Nominatim или подмена) + Haversine (приоритет)
 //  2. Fallback: LLM-определение (nearest_office из промпта)
-func resolveOfficeForTicket(t TicketInput, llmOffice string) (office string, lat, lon float64, method string) {
+//  3. Fallback: офис области тикета (oblastRules), метод "oblast-centroid"
+//
+// derivedOblast — область по геокодированию, только если в тикете она пустая;
+// precision — точность геокодирования ("" — адрес не геокодировался).
+func (e *Engine) resolveOfficeForTicket(ctx context.Context, t TicketInput, llmOffice string) (office string, lat, lon float64, method, derivedOblast, precision string) {
 	isKZ := t.Country == "" ||
 		strings.Contains(strings.ToLower(t.Country), "казахстан") ||
 		strings.EqualFold(t.Country, "kz") ||
 		strings.EqualFold(t.Country, "kazakhstan")
 
 	if !isKZ {
-		return "", 0, 0, "foreign"
+		return "", 0, 0, "foreign", "", ""
+	}
+
+	// Латиница (Aktau, Shymkent) → сначала кириллический вариант, исходное название — запасное
+	cities := []string{t.RawCity}
+	if looksLatin(t.RawCity) {
+		cyr := translitToCyrillic(t.RawCity)
+		fmt.Printf("   🔡 Транслитерация: '%s' → '%s'\n", t.RawCity, cyr)
+		cities = []string{cyr, t.RawCity}
+	}
+
+	// Старые и альтернативные названия (Семипалатинск, Целиноград, Капчагай...) — без геокодирования
+	for _, city := range cities {
+		if office, ok := e.lookupCityAlias(city); ok {
+			fmt.Printf("   🏷  Алиас: '%s' → офис '%s'\n", city, office)
+			return office, 0, 0, "alias", "", ""
+		}
 	}
 
 	// Пробуем Nominatim
-	lat, lon, ok := geocodeAddress(t.Country, t.Oblast, t.RawCity, t.Street, t.House)
+	ok, region := false, ""
+	for _, city := range cities {
+		if lat, lon, region, precision, ok = e.geocoder.Geocode(ctx, t.Country, t.Oblast, city, t.Street, t.House); ok {
+			break
+		}
+	}
 	if ok {
-		fmt.Printf("   🌐 Nominatim: %.4f, %.4f\n", lat, lon)
-		nearestOffice := findNearestOfficeByCoords(lat, lon)
+		fmt.Printf("   🌐 Nominatim: %.4f, %.4f (точность: %s)\n", lat, lon, precision)
+		if t.Oblast == "" && region != "" {
+			derivedOblast = region
+			fmt.Printf("   🗺  Область по геокодированию: '%s'\n", region)
+		}
+		nearestOffice, dist := e.findNearestOfficeByCoords(lat, lon)
+		if nearestOffice != "" && MaxOfficeDistanceKm > 0 && dist > MaxOfficeDistanceKm {
+			// Глухие сёла: «ближайший» офис за сотни км — не ближе ГО. LLM тут не поможет
+			fmt.Printf("   📏 До '%s' %.0f км > лимита %.0f км (%s, %s) → 50/50\n",
+				nearestOffice, dist, MaxOfficeDistanceKm, t.Oblast, t.RawCity)
+			return "", lat, lon, "too_far", derivedOblast, precision
+		}
 		if nearestOffice != "" {
-			return nearestOffice, lat, lon, "nominatim"
+			return nearestOffice, lat, lon, "nominatim", derivedOblast, precision
 		}
 	}
 
 	// Fallback: LLM-результат
 	if llmOffice != "" {
 		fmt.Printf("   🤖 LLM-геолокация: офис '%s'\n", llmOffice)
-		return llmOffice, 0, 0, "llm"
+		return llmOffice, 0, 0, "llm", derivedOblast, ""
 	}
 
-	return "", 0, 0, "unknown"
+	// Fallback: область известна — её офис вместо 50/50 по ГО
+	if office, center, ok := e.resolveOblastOffice(t.Oblast); ok {
+		return office, center.Lat, center.Lon, "oblast-centroid", "", geoPrecisionRegion
+	}
+
+	return "", 0, 0, "unknown", "", ""
 }
 
-func fallbackAnalyze(t TicketInput) AIResult {
-	text := t.Text + " " + t.Attachment
-	lower := strings.ToLower(text)
+// crossCheckAI — тип по ключевым словам для сверки с AI. disagree=true, если Gemini
+// и Keyword Fallback классифицировали тикет по-разному (сигнал для выборочной проверки)
+func crossCheckAI(t TicketInput, ai AIResult) (fallbackType string, disagree bool) {
+	if !isLLMSource(ai.Source) {
+		return "", false
+	}
+	fallbackType = fallbackAnalyze(t).Type
+	return fallbackType, fallbackType != ai.Type
+}
 
-	r := AIResult{
-		Type:          "Консультация",
-		Sentiment:     "Нейтральный",
-		Language:      "RU",
-		Priority:      "5",
-		Summary:       "Keyword-анализ. Требуется проверка менеджером.",
-		NearestOffice: "",
-		Source:        "Fallback",
+// lowConfidence — Gemini сам не уверен в классификации (ниже aiMinConfidence)
+func lowConfidence(source string, confidence float64) bool {
+	return isLLMSource(source) && confidence < aiMinConfidence
+}
+
+// applyLowConfidence — при низкой уверенности AI и расхождении с ключевыми
+// словами тип берётся из Keyword Fallback (до роутинга: тип влияет на фильтры)
+func applyLowConfidence(ai AIResult, fallbackType string, disagree bool) AIResult {
+	if !disagree || !lowConfidence(ai.Source, ai.Confidence) {
+		return ai
 	}
+	fmt.Printf("   🎲 Уверенность AI %.2f < %.2f: тип '%s' → '%s' (ключевые слова)\n",
+		ai.Confidence, aiMinConfidence, ai.Type, fallbackType)
+	ai.Type = fallbackType
+	return ai
+}
 
-	// ── Определение языка ────────────────────────────────────
-	kazWords := []string{"сіз", "өтінемін", "қате", "көмек", "рахмет", "жоқ", "болады",
+// Слова-маркеры языка (Keyword Fallback и проверка языка summary)
+var (
+	kazMarkerWords = []string{"сіз", "өтінемін", "қате", "көмек", "рахмет", "жоқ", "болады",
 		"саламатсыздарма", "менде", "бұйрық", "неге", "алуға"}
-	engWords := []string{"please", "help", "error", "account", "transfer", "unable",
+	engMarkerWords = []string{"please", "help", "error", "account", "transfer", "unable",
 		"issue", "hello", "dear", "regards", "blocked", "verify", "validation"}
+)
 
+// detectTextLanguage — язык по словам-маркерам в тексте (нижний регистр):
+// KZ или ENG при двух и более маркерах, иначе RU; alt — ENG в казахском тексте
+func detectTextLanguage(lower string) (lang, alt string) {
 	kazCount, engCount := 0, 0
-	for _, w := range kazWords {
+	for _, w := range kazMarkerWords {
 		if strings.Contains(lower, w) {
 			kazCount++
 		}
 	}
-	for _, w := range engWords {
+	for _, w := range engMarkerWords {
 		if strings.Contains(lower, w) {
 			engCount++
 		}
 	}
-	if kazCount >= 2 {
-		r.Language = "KZ"
-	} else if engCount >= 2 {
-		r.Language = "ENG"
+	switch {
+	case kazCount >= 2 && engCount >= 2:
+		return "KZ", "ENG" // казахский текст с английскими терминами
+	case kazCount >= 2:
+		return "KZ", ""
+	case engCount >= 2:
+		return "ENG", ""
+	}
+	return "RU", ""
+}
+
+// fraudKeywords — слова, по которым Keyword Fallback ставит «Мошеннические
+// действия»; с ними тикет не признаётся спамом до AI (spamPrefilter)
+var fraudKeywords = []string{"мошенник", "украли", "взлом", "несанкционированн", "fraud",
+	"scam", "мошеннические", "финансовые махинации"}
+
+func fallbackAnalyze(t TicketInput) AIResult {
+	text := t.Text + " " + t.Attachment + " " + t.OCRText
+	lower := strings.ToLower(text)
+
+	r := AIResult{
+		Type:          "Консультация",
+		Sentiment:     "Нейтральный",
+		Language:      "RU",
+		Priority:      "5",
+		Summary:       "Keyword-анализ. Требуется проверка менеджером.",
+		NearestOffice: "",
+		Source:        "Fallback",
 	}
 
+	// ── Определение языка ────────────────────────────────────
+	r.Language, r.AltLanguage = detectTextLanguage(lower)
+
 	// ── Классификация по ключевым словам ─────────────────────
 	switch {
 	case containsAny(text, "суд", "прокуратура", "адвокат", "иск", "court", "lawyer",
@@ -397,8 +802,7 @@ func fallbackAnalyze(t TicketInput) AIResult {
 		r.Priority = "10"
 		r.Summary = "Клиент угрожает обращением в правоохранительные органы или суд. Немедленная эскалация Главному специалисту."
 
-	case containsAny(text, "мошенник", "украли", "взлом", "несанкционированн", "fraud",
-		"scam", "мошеннические", "финансовые махинации"):
+	case containsAny(text, fraudKeywords...):
 		r.Type = "Мошеннические действия"
 		r.Sentiment = "Негативный"
 		r.Priority = "9"
@@ -442,7 +846,7 @@ func fallbackAnalyze(t TicketInput) AIResult {
 		r.Summary = "Входящее сообщение классифицировано как рекламная рассылка."
 	}
 
-	return r
+	return applyScreenshotHint(t, r)
 }
 
 // ═══════════════════════════════════════════════════════════
@@ -465,18 +869,26 @@ type ticketForPrompt struct {
 	Country string `json:"country,omitempty"`
 	Oblast  string `json:"oblast,omitempty"`
 	City    string `json:"city,omitempty"`
+	// AttachmentType — тип вложения (attachmentType): image — скриншот
+	AttachmentType string `json:"attachment_type,omitempty"`
 }
 
-func analyzeBatch(tickets []TicketInput, apiKey string) (map[int]AIResult, error) {
-	url := "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-flash:generateContent?key=" + apiKey
+// PromptVersion — версия промпта analyzeBatch. Повышать при ЛЮБОМ изменении текста
+// промпта: значение сохраняется в ai_analysis.prompt_version вместе с сырым ответом,
+// чтобы связывать качество классификации с правками промпта.
+const PromptVersion = "2026-10-16.5"
 
-	officesList := strings.Join(knownOffices, " | ")
+func (e *Engine) analyzeBatch(ctx context.Context, tickets []TicketInput, apiKey string) (map[int]AIResult, error) {
+	officesList := strings.Join(e.offices, " | ")
 
 	var promptTickets []ticketForPrompt
 	for _, t := range tickets {
 		text := t.Text
 		if t.Attachment != "" && t.Text == "" {
 			text = "[Вложение: " + t.Attachment + "] — текста нет, проанализируй по имени файла"
+			if t.OCRText != "" {
+				text = "[Текст из вложения " + t.Attachment + ", распознан OCR]: " + t.OCRText
+			}
 		}
 		if len(text) > 700 {
 			text = text[:700] + "..."
@@ -490,6 +902,8 @@ func analyzeBatch(tickets []TicketInput, apiKey string) (map[int]AIResult, error
 			Country: t.Country,
 			Oblast:  t.Oblast,
 			City:    t.RawCity,
+
+			AttachmentType: attachmentType(t.Attachment),
 		})
 	}
 
@@ -527,6 +941,7 @@ func analyzeBatch(tickets []TicketInput, apiKey string) (map[int]AIResult, error
   — технические проблемы мешают клиенту ИСПОЛЬЗОВАТЬ сервис: не входит, не приходит SMS, ошибка
   — примеры: "не могу войти", "пароль не принимает", "смс не приходит", "ошибка при входе", "не могу зарегистрироваться"
   — ОТЛИЧИЕ от Консультации: клиент ПЫТАЕТСЯ что-то сделать, но система не даёт
+  — attachment_type "image" (скриншот) — сильный признак этого типа, если текст не говорит об ином
 
 "Мошеннические действия"
   — клиент подозревает мошенничество, несанкционированный доступ, просит проверить легитимность
@@ -563,6 +978,8 @@ func analyzeBatch(tickets []TicketInput, apiKey string) (map[int]AIResult, error
 "KZ" — казахский (саламатсыздарма, қандай, алуға, бұйрық, неге, рахмет, сіз, өтінемін)
 "ENG" — английский (hello, please, help, I am, my account, unable, verification)
 "RU" — русский или если язык не определён
+"alt_language" — второй язык, если обращение смешанное (например, казахский текст с английскими
+терминами: language="KZ", alt_language="ENG"); иначе "". Не совпадает с language.
 
 ═══════════════════════════════════════════════════════
 SUMMARY (поле "summary"):
@@ -604,64 +1021,24 @@ SUMMARY (поле "summary"):
   Абайская, Бескарагай → "Усть-Каменогорск"
   Алматинская обл., Конаев (Капчагай) → "Алматы"
 
+═══════════════════════════════════════════════════════
+УВЕРЕННОСТЬ (confidence):
+═══════════════════════════════════════════════════════
+Число от 0 до 1 — насколько ты уверен в type. 1 — однозначно, 0.5 — можно отнести к двум
+типам, ниже 0.5 — текст непонятен или противоречив.
+
 ═══════════════════════════════════════════════════════
 ВЕРНИ ТОЛЬКО JSON-МАССИВ (без markdown и пояснений):
-[{"i":<число>,"type":"...","sentiment":"...","language":"...","priority":<1-10>,"summary":"...","nearest_office":"..."}]
+[{"i":<число>,"type":"...","sentiment":"...","language":"...","priority":<1-10>,"summary":"...","nearest_office":"...","alt_language":"...","confidence":<0-1>}]
 
 ТИКЕТЫ (поле segment передаётся для учёта при расчёте приоритета):
 %s`, officesList, string(ticketsJSON))
 
-	body, _ := json.Marshal(map[string]any{
-		"contents": []map[string]any{
-			{"parts": []map[string]any{{"text": prompt}}},
-		},
-		"generationConfig": map[string]any{
-			"temperature":      0.05,
-			"maxOutputTokens":  65536,
-			"responseMimeType": "application/json",
-		},
-	})
-
-	fmt.Printf("📤 Отправка батча: %d тикетов → 1 запрос к Gemini AI...\n", len(tickets))
-
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	fmt.Printf("📤 Отправка батча: %d тикетов → 1 запрос к %s...\n", len(tickets), e.analyzer.Name())
+	rawText, err := e.analyzer.Generate(ctx, apiKey, prompt)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP-ошибка: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 429 {
-		return nil, fmt.Errorf("rate limit 429 — подождите 60 сек и запустите снова")
+		return nil, err
 	}
-	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
-		snippet := string(b)
-		if len(snippet) > 400 {
-			snippet = snippet[:400]
-		}
-		return nil, fmt.Errorf("API HTTP %d: %s", resp.StatusCode, snippet)
-	}
-
-	respBytes, _ := io.ReadAll(resp.Body)
-
-	// Парсинг ответа Gemini
-	var geminiResp struct {
-		Candidates []struct {
-			Content struct {
-				Parts []struct {
-					Text string `json:"text"`
-				} `json:"parts"`
-			} `json:"content"`
-		} `json:"candidates"`
-	}
-	if err := json.Unmarshal(respBytes, &geminiResp); err != nil {
-		return nil, fmt.Errorf("парсинг Gemini ответа: %v", err)
-	}
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("пустой ответ от AI")
-	}
-
-	rawText := geminiResp.Candidates[0].Content.Parts[0].Text
 
 	// Очистка markdown-обёртки
 	tbt := "```" // три обратных кавычки — нельзя писать внутри raw string
@@ -669,6 +1046,8 @@ SUMMARY (поле "summary"):
 	rawText = strings.ReplaceAll(rawText, tbt, "")
 	rawText = strings.TrimSpace(rawText)
 
+	cleanedText := rawText
+
 	// Поиск JSON массива внутри текста (на случай если LLM добавил пояснения)
 	start := strings.Index(rawText, "[")
 	end := strings.LastIndex(rawText, "]")
@@ -679,7 +1058,14 @@ SUMMARY (поле "summary"):
 	// Парсинг через any — устойчиво к типу priority (число или строка)
 	var rawResults []map[string]any
 	if err := json.Unmarshal([]byte(rawText), &rawResults); err != nil {
-		return nil, fmt.Errorf("парсинг JSON результатов: %v\nОтвет AI (первые 600 символов): %.600s", err, rawText)
+		// Модель оборвалась посреди объекта — спасаем полные объекты из начала массива
+		salvaged := salvageJSONArray(cleanedText)
+		if len(salvaged) == 0 {
+			return nil, fmt.Errorf("парсинг JSON результатов: %v\nОтвет AI (первые 600 символов): %.600s", err, rawText)
+		}
+		fmt.Printf("   🩹 JSON ответа AI повреждён (%v): восстановлено %d объектов, потеряно %d из %d\n",
+			err, len(salvaged), max(0, len(tickets)-len(salvaged)), len(tickets))
+		rawResults = salvaged
 	}
 
 	results := make(map[int]AIResult)
@@ -708,53 +1094,236 @@ SUMMARY (поле "summary"):
 		// nearest_office — валидируем и нормализуем
 		nearestOffice := ""
 		if raw, ok := item["nearest_office"].(string); ok {
-			nearestOffice = normalizeOfficeName(raw)
+			nearestOffice = e.normalizeOfficeName(raw)
 			if raw != "" && nearestOffice == "" {
 				fmt.Printf("   ⚠️ AI вернул неизвестный офис '%s' для тикета %d → 50/50\n", raw, idx)
 			}
 		}
 
+		// confidence — число или строка; нет в ответе → 1.0 (старые промпты)
+		confidence := 1.0
+		switch v := item["confidence"].(type) {
+		case float64:
+			confidence = v
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				confidence = f
+			}
+		}
+		confidence = math.Max(0, math.Min(1, confidence))
+
+		language := getString(item, "language")
+		altLanguage := strings.ToUpper(strings.TrimSpace(getString(item, "alt_language")))
+		if !knownLanguages[altLanguage] || altLanguage == language {
+			altLanguage = ""
+		}
+
+		// Для аудита — только объект этого тикета, а не ответ на весь батч
+		rawAI, _ := json.Marshal(item)
+
 		results[idx] = AIResult{
 			Type:          getString(item, "type"),
 			Sentiment:     getString(item, "sentiment"),
-			Language:      getString(item, "language"),
+			Language:      language,
+			AltLanguage:   altLanguage,
 			Priority:      priority,
 			Summary:       getString(item, "summary"),
 			NearestOffice: nearestOffice,
-			Source:        "Gemini",
+			Source:        e.analyzer.Name(),
+			RawAI:         string(rawAI),
+			PromptVersion: PromptVersion,
+			Confidence:    confidence,
 		}
 	}
 
+	e.fixSummaryLanguages(ctx, tickets, results, apiKey)
+	limitSummaries(results)
+	if *maskProfanity {
+		maskSummaries(results)
+	}
+
 	fmt.Printf("✅ AI батч завершён: получено %d/%d результатов\n", len(results), len(tickets))
 	return results, nil
 }
 
-// analyzeBatchWithRetry — повторная попытка при ошибке с паузой
-func analyzeBatchWithRetry(tickets []TicketInput, apiKey string, maxRetries int) (map[int]AIResult, error) {
+// sleepCtx — пауза, прерываемая отменой контекста. false — контекст отменён.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// errAIBlocked — Gemini отказался отвечать по соображениям безопасности.
+// Повтор того же батча даст тот же результат, поэтому сразу уходим в fallback.
+var errAIBlocked = errors.New("ответ заблокирован фильтрами AI")
+
+// isBlockFinishReason — finishReason, означающий блокировку, а не обычное завершение
+func isBlockFinishReason(reason string) bool {
+	switch reason {
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "OTHER":
+		return true
+	}
+	return false
+}
+
+// salvageJSONArray — толерантный разбор обрезанного JSON-массива объектов.
+// Идёт назад по закрывающим '}' (начиная с последней), отрезает хвост,
+// закрывает массив и пробует распарсить. '}' внутри строк (в summary)
+// дают невалидный префикс и просто пропускаются. nil — спасать нечего.
+func salvageJSONArray(raw string) []map[string]any {
+	start := strings.Index(raw, "[")
+	if start < 0 {
+		return nil
+	}
+	body := raw[start:]
+	for end := strings.LastIndex(body, "}"); end > 0; end = strings.LastIndex(body[:end], "}") {
+		var items []map[string]any
+		if err := json.Unmarshal([]byte(body[:end+1]+"]"), &items); err == nil {
+			return items
+		}
+	}
+	return nil
+}
+
+// rateLimitError — HTTP 429 от Gemini; RetryAfter — из заголовка Retry-After (0 — не указан)
+type rateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limit 429 — Retry-After %v", e.RetryAfter)
+	}
+	return "rate limit 429"
+}
+
+// parseRetryAfter — заголовок Retry-After: число секунд или HTTP-дата
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+const (
+	backoffBase          = 5 * time.Second  // первая пауза после ошибки
+	backoffCap           = 80 * time.Second // потолок экспоненты
+	rateLimitDefaultWait = 60 * time.Second // 429 без Retry-After
+)
+
+// jitter — случайная добавка [0, d): разводит параллельные запуски во времени
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// backoffDelay — экспоненциальная пауза перед повтором: 5s, 10s, 20s ... ≤ 80s, + до 50% jitter
+func backoffDelay(attempt int) time.Duration {
+	d := backoffBase
+	for i := 1; i < attempt && d < backoffCap; i++ {
+		d *= 2
+	}
+	if d > backoffCap {
+		d = backoffCap
+	}
+	return d + jitter(d/2)
+}
+
+// analyzeBatchWithRetry — повторная попытка при ошибке с паузой.
+// 429 → ключ уходит на паузу, запрос сразу повторяется со следующим ключом пула;
+// ждать (Retry-After + jitter) приходится, только когда на паузе все ключи.
+// Остальные ошибки → экспоненциальный backoff с jitter.
+// Разомкнутый aiBreaker — сразу errBreakerOpen, без HTTP-запроса.
+func (e *Engine) analyzeBatchWithRetry(ctx context.Context, tickets []TicketInput, keys *apiKeyPool, maxRetries int) (map[int]AIResult, error) {
 	var lastErr error
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		results, err := analyzeBatch(tickets, apiKey)
+	for attempt := 1; attempt <= maxRetries; {
+		if !aiBreaker.Allow() {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w (последняя ошибка: %v)", errBreakerOpen, lastErr)
+			}
+			return nil, errBreakerOpen
+		}
+		key, idx, wait, ok := keys.Acquire()
+		if !ok {
+			aiBreaker.Release()
+			if attempt >= maxRetries {
+				break
+			}
+			wait += jitter(wait / 6)
+			fmt.Printf("⏳ Rate limit на всех ключах (%d). Ожидание %v (попытка %d/%d)...\n",
+				keys.Len(), wait.Round(time.Second), attempt, maxRetries)
+			if !sleepCtx(ctx, wait) {
+				return nil, ctx.Err()
+			}
+			attempt++
+			continue
+		}
+
+		results, err := e.analyzeBatch(ctx, tickets, key)
 		if err == nil {
+			keys.MarkOK(idx)
+			aiBreaker.Success()
 			return results, nil
 		}
+		// Отмена всего запуска не повторяем; таймаут отдельного запроса (AI_TIMEOUT) —
+		// обычная повторяемая ошибка
+		if ctx.Err() != nil {
+			aiBreaker.Release()
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, errAIBlocked) {
+			aiBreaker.Release()
+			return nil, err
+		}
 		lastErr = err
-		if strings.Contains(err.Error(), "rate limit") {
-			fmt.Printf("⏳ Rate limit. Ожидание 65 секунд (попытка %d/%d)...\n", attempt, maxRetries)
-			time.Sleep(65 * time.Second)
-		} else {
-			fmt.Printf("⚠️ Ошибка AI (попытка %d/%d): %v\n", attempt, maxRetries, err)
-			time.Sleep(5 * time.Second)
+
+		var rl *rateLimitError
+		if errors.As(err, &rl) {
+			aiBreaker.Release()
+			keys.MarkRateLimited(idx, rl.RetryAfter)
+			continue // следующий ключ или ожидание — решит Acquire
+		}
+		aiBreaker.Failure()
+
+		if attempt == maxRetries {
+			break
 		}
+		pause := backoffDelay(attempt)
+		fmt.Printf("⚠️ Ошибка AI (попытка %d/%d): %v → повтор через %v\n", attempt, maxRetries, err, pause.Round(100*time.Millisecond))
+		if !sleepCtx(ctx, pause) {
+			return nil, ctx.Err()
+		}
+		attempt++
 	}
 	return nil, lastErr
 }
 
 // analyzeAllInChunks — разбивает тикеты на чанки по chunkSize и обрабатывает их последовательно.
 // Между чанками делает паузу pauseSec секунд чтобы не упираться в TPM rate limit.
-func analyzeAllInChunks(tickets []TicketInput, apiKey string, chunkSize, pauseSec int) (map[int]AIResult, error) {
+// При отмене контекста возвращает уже полученные результаты и ctx.Err().
+func (e *Engine) analyzeAllInChunks(ctx context.Context, tickets []TicketInput, keys *apiKeyPool, chunkSize, pauseSec int) (map[int]AIResult, error) {
 	allResults := make(map[int]AIResult)
 
 	for start := 0; start < len(tickets); start += chunkSize {
+		if ctx.Err() != nil {
+			return allResults, ctx.Err()
+		}
 		end := start + chunkSize
 		if end > len(tickets) {
 			end = len(tickets)
@@ -763,7 +1332,17 @@ func analyzeAllInChunks(tickets []TicketInput, apiKey string, chunkSize, pauseSe
 
 		fmt.Printf("📦 Чанк %d–%d из %d тикетов...\n", start+1, end, len(tickets))
 
-		results, err := analyzeBatchWithRetry(chunk, apiKey, 3)
+		results, err := e.analyzeBatchWithRetry(ctx, chunk, keys, aiMaxRetries)
+		if ctx.Err() != nil {
+			return allResults, ctx.Err()
+		}
+		if errors.Is(err, errBreakerOpen) {
+			fmt.Printf("🔌 Чанк %d–%d: breaker разомкнут → Keyword Fallback без запроса\n", start+1, end)
+			for _, t := range chunk {
+				allResults[t.Index] = fallbackAnalyze(t)
+			}
+			continue // без паузы: запросов к AI не было
+		}
 		if err != nil {
 			// Fallback для всего чанка
 			fmt.Printf("⚠️ Чанк %d–%d упал: %v → Keyword Fallback\n", start+1, end, err)
@@ -779,7 +1358,9 @@ func analyzeAllInChunks(tickets []TicketInput, apiKey string, chunkSize, pauseSe
 		// Пауза между чанками (кроме последнего)
 		if end < len(tickets) {
 			fmt.Printf("⏸  Пауза %d сек перед следующим чанком...\n", pauseSec)
-			time.Sleep(time.Duration(pauseSec) * time.Second)
+			if !sleepCtx(ctx, time.Duration(pauseSec)*time.Second) {
+				return allResults, ctx.Err()
+			}
 		}
 	}
 
@@ -791,38 +1372,30 @@ func analyzeAllInChunks(tickets []TicketInput, apiKey string, chunkSize, pauseSe
 // ═══════════════════════════════════════════════════════════
 
 // findBestManager — выбирает менеджера из пула по каскаду фильтров + Round Robin
-func findBestManager(pool []*Manager, segment string, ai AIResult, officeKey string) *Manager {
+func (e *Engine) findBestManager(pool []*Manager, segment string, ai AIResult, officeKey string) *Manager {
 	var filtered []*Manager
 
 	for _, m := range pool {
-		// ── Фильтр 1: VIP/Priority сегмент → нужен навык VIP (строго по ТЗ: только сегмент)
-		if needsVIP(segment) {
-			hasVIP := false
-			for _, s := range m.Skills {
-				if strings.TrimSpace(s) == "VIP" {
-					hasVIP = true
-					break
-				}
-			}
-			if !hasVIP {
-				continue
-			}
+		// ── Фильтр 1: навык сегмента (VIP/Priority → VIP; строго по ТЗ: только сегмент)
+		if skill := requiredSkill(segment); skill != "" && !m.hasSkill(skill) {
+			continue
 		}
 
-		// ── Фильтр 2: Смена данных → ТОЛЬКО Главный специалист
-		if ai.Type == "Смена данных" {
-			if !strings.Contains(m.Role, "Главный") {
-				continue
-			}
+		// ── Фильтр 2: должность не ниже требуемой для типа
+		// (Смена данных → Главный, Претензия → Ведущий и выше)
+		if roleRank(m.Role) < requiredRole(ai.Type) {
+			continue
 		}
 
 		// ── Фильтр 3: Язык обращения KZ или ENG → менеджер должен владеть языком
-		if ai.Language == "ENG" || ai.Language == "KZ" {
+		// (для смешанного обращения — любым из двух)
+		if langs := requiredLanguages(ai); len(langs) > 0 {
 			hasLang := false
 			for _, s := range m.Skills {
-				if strings.TrimSpace(s) == ai.Language {
-					hasLang = true
-					break
+				for _, lang := range langs {
+					if strings.TrimSpace(s) == lang {
+						hasLang = true
+					}
 				}
 			}
 			if !hasLang {
@@ -837,25 +1410,29 @@ func findBestManager(pool []*Manager, segment string, ai AIResult, officeKey str
 		return nil
 	}
 
-	// ── Балансировка: Least Connections + Round Robin между топ-2
-	sort.Slice(filtered, func(i, j int) bool {
-		return filtered[i].Workload < filtered[j].Workload
+	// ── Балансировка: Least Connections + Round Robin между топ-2.
+	// При равной нагрузке — по имени: выбор не зависит от порядка сортировки.
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if filtered[i].Workload != filtered[j].Workload {
+			return filtered[i].Workload < filtered[j].Workload
+		}
+		return filtered[i].Name < filtered[j].Name
 	})
 	candidates := filtered
 	if len(filtered) > 1 {
 		candidates = filtered[:2] // топ-2 наименее загруженных
 	}
 
-	winner := candidates[RRCounters[officeKey]%len(candidates)]
-	RRCounters[officeKey]++
+	winner := candidates[e.rrCounters[officeKey]%len(candidates)]
+	e.rrCounters[officeKey]++
 	winner.Workload++ // увеличиваем нагрузку для следующей итерации
 	return winner
 }
 
 // routeTicket — полный каскад роутинга согласно ТЗ
 // Геокодирование уже выполнено: ai.NearestOffice содержит финальный офис, ai.GeoMethod — метод.
-// Возвращает: менеджер, назначенный офис, флаг эскалации в ГО
-func routeTicket(t TicketInput, ai AIResult) (*Manager, string, bool) {
+// Возвращает: менеджер, назначенный офис, флаг эскалации в ГО, флаг офиса без VIP-менеджеров
+func (e *Engine) routeTicket(t TicketInput, ai AIResult) (*Manager, string, bool, bool) {
 	isKazakhstan := t.Country == "" ||
 		strings.Contains(strings.ToLower(t.Country), "казахстан") ||
 		strings.EqualFold(t.Country, "kz") ||
@@ -865,16 +1442,13 @@ func routeTicket(t TicketInput, ai AIResult) (*Manager, string, bool) {
 	targetOffice := ai.NearestOffice
 
 	if targetOffice == "" || !isKazakhstan || ai.GeoMethod == "foreign" {
-		// Клиент из-за рубежа или адрес не определён → 50/50 Астана/Алматы
-		if foreignSplitCtr%2 == 0 {
-			targetOffice = "Астана"
-		} else {
-			targetOffice = "Алматы"
-		}
-		foreignSplitCtr++
+		// Клиент из-за рубежа или адрес не определён → 50/50 Астана/Алматы (HQ_SPLIT_WEIGHTS)
+		targetOffice = e.pickSplitHQ()
 
 		if !isKazakhstan || ai.GeoMethod == "foreign" {
 			fmt.Printf("   🌍 Иностранный клиент '%s' → %s (50/50)\n", t.Country, targetOffice)
+		} else if ai.GeoMethod == "too_far" {
+			fmt.Printf("   📏 Ближайший офис дальше %.0f км '%s' → %s (50/50)\n", MaxOfficeDistanceKm, t.RawCity, targetOffice)
 		} else {
 			fmt.Printf("   🌍 Адрес не определён '%s' → %s (50/50)\n", t.RawCity, targetOffice)
 		}
@@ -883,51 +1457,88 @@ func routeTicket(t TicketInput, ai AIResult) (*Manager, string, bool) {
 		case "nominatim":
 			fmt.Printf("   📍 Nominatim+Haversine: '%s' → офис '%s' (%.4f, %.4f)\n",
 				t.RawCity, targetOffice, ai.GeoLat, ai.GeoLon)
+		case "city-memo":
+			fmt.Printf("   🏙  Память города: '%s' → офис '%s'\n", t.RawCity, targetOffice)
 		case "llm":
 			fmt.Printf("   🤖 LLM-геолокация: '%s' → офис '%s'\n", t.RawCity, targetOffice)
+		case "alias":
+			fmt.Printf("   🏷  Алиас города: '%s' → офис '%s'\n", t.RawCity, targetOffice)
+		case "oblast-centroid":
+			fmt.Printf("   🗺  Центр области: '%s, %s' → офис '%s'\n", t.Oblast, t.RawCity, targetOffice)
 		}
 	}
 
 	// ── Шаг 2: Поиск менеджера в целевом офисе ───────────────
-	if pool, ok := ManagersMap[targetOffice]; ok {
-		if winner := findBestManager(pool, t.Segment, ai, targetOffice); winner != nil {
-			return winner, targetOffice, false
+	vipGap := false // эскалация из-за офиса без VIP-менеджеров, а не обычная
+	if pool, ok := e.managers[targetOffice]; ok {
+		if winner := e.findBestManager(pool, t.Segment, ai, targetOffice); winner != nil {
+			return winner, targetOffice, false, false
 		}
 		noMatchReason := buildNoMatchReason(t.Segment, ai)
+		if requiredSkill(t.Segment) == "VIP" && e.vipGapOffices[targetOffice] {
+			vipGap = true
+			noMatchReason = "в офисе нет VIP-менеджеров"
+		}
 		fmt.Printf("   🔼 В '%s' нет подходящего менеджера (%s) → эскалация в ГО\n", targetOffice, noMatchReason)
 	} else {
 		fmt.Printf("   🔼 Офис '%s' не найден → эскалация в ГО\n", targetOffice)
 	}
 
 	// ── Шаг 3: Эскалация в ГО (Астана или Алматы) ────────────
-	for _, hq := range HQ_CITIES {
+	for _, hq := range e.hqCities {
 		if hq == targetOffice {
 			continue
 		}
-		if pool, ok := ManagersMap[hq]; ok {
-			if winner := findBestManager(pool, t.Segment, ai, hq); winner != nil {
+		if pool, ok := e.managers[hq]; ok {
+			if winner := e.findBestManager(pool, t.Segment, ai, hq); winner != nil {
 				fmt.Printf("   🔼 Эскалировано в ГО → %s (%s)\n", hq, winner.Name)
-				return winner, hq, true
+				if vipGap {
+					e.vipGapEscalations[targetOffice]++
+					e.vipGapTickets = append(e.vipGapTickets, vipGapTicket{t.GUID, t.Segment, ai.Priority, targetOffice, hq})
+				}
+				return winner, hq, true, vipGap
 			}
 		}
 	}
 
 	// ── Шаг 4: Менеджер не найден ────────────────────────────
+	if vipGap {
+		e.vipGapTickets = append(e.vipGapTickets, vipGapTicket{t.GUID, t.Segment, ai.Priority, targetOffice, "—"})
+	}
 	fmt.Printf("   ❌ Менеджер не найден ни в одном офисе\n")
-	return nil, "—", false
+	return nil, "—", false, vipGap
+}
+
+// knownLanguages — допустимые значения language / alt_language
+var knownLanguages = map[string]bool{"RU": true, "KZ": true, "ENG": true}
+
+// requiredLanguages — языки, хотя бы одним из которых должен владеть менеджер.
+// Пусто — ограничения нет: русский знают все, поэтому RU в основном или
+// втором языке снимает фильтр.
+func requiredLanguages(ai AIResult) []string {
+	var langs []string
+	for _, lang := range []string{ai.Language, ai.AltLanguage} {
+		switch lang {
+		case "KZ", "ENG":
+			langs = append(langs, lang)
+		case "RU":
+			return nil
+		}
+	}
+	return langs
 }
 
 // buildNoMatchReason — формирует читаемую причину отсутствия подходящего менеджера
 func buildNoMatchReason(segment string, ai AIResult) string {
 	var reasons []string
-	if needsVIP(segment) {
-		reasons = append(reasons, "нужен VIP (сегмент)")
+	if skill := requiredSkill(segment); skill != "" {
+		reasons = append(reasons, "нужен "+skill+" (сегмент)")
 	}
-	if ai.Type == "Смена данных" {
-		reasons = append(reasons, "нужен Главный специалист")
+	if role := roleRequirement(requiredRole(ai.Type)); role != "" {
+		reasons = append(reasons, "нужен "+role)
 	}
-	if ai.Language == "ENG" || ai.Language == "KZ" {
-		reasons = append(reasons, "нужен "+ai.Language)
+	if langs := requiredLanguages(ai); len(langs) > 0 {
+		reasons = append(reasons, "нужен "+strings.Join(langs, " или "))
 	}
 	if len(reasons) == 0 {
 		return "все менеджеры перегружены"
@@ -941,27 +1552,149 @@ func buildRoutingReason(segment string, ai AIResult, geoMethod string) string {
 	switch geoMethod {
 	case "nominatim":
 		parts = append(parts, "Geo:Nominatim+Haversine")
+	case "city-memo":
+		parts = append(parts, "Geo:Память города")
 	case "llm":
 		parts = append(parts, "Geo:LLM")
+	case "alias":
+		parts = append(parts, "Geo:Алиас города")
+	case "oblast-centroid":
+		parts = append(parts, "Geo:Центр области")
 	case "50/50", "foreign", "unknown":
 		parts = append(parts, "Geo:50/50")
+	case "too_far":
+		parts = append(parts, "Geo:50/50 (офис дальше лимита)")
 	}
-	if needsVIP(segment) {
-		parts = append(parts, "VIP-сегмент")
+	if skill := requiredSkill(segment); skill != "" {
+		parts = append(parts, skill+"-сегмент")
 	}
 	if isHighPriority(ai.Priority) {
 		parts = append(parts, "Высокий приоритет")
 	}
-	if ai.Type == "Смена данных" {
-		parts = append(parts, "Главный специалист")
+	if role := roleRequirement(requiredRole(ai.Type)); role != "" {
+		parts = append(parts, role)
 	}
-	if ai.Language == "KZ" || ai.Language == "ENG" {
-		parts = append(parts, "Язык:"+ai.Language)
+	if langs := requiredLanguages(ai); len(langs) > 0 {
+		parts = append(parts, "Язык:"+strings.Join(langs, "/"))
 	}
 	parts = append(parts, "Round Robin")
 	return strings.Join(parts, " → ")
 }
 
+// buildRoutingResult — роутинг одного тикета с готовым AI-результатом.
+// Спам не назначается; остальное — через routeTicket. Безопасна для
+// конкурентного вызова: состояние Round Robin и нагрузка под e.mu.
+func (e *Engine) buildRoutingResult(t TicketInput, ai AIResult) RoutingResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var routingResult RoutingResult
+
+	// Ручное назначение (data/overrides.csv) важнее алгоритма, в т.ч. для спама
+	manual, manualOffice, isManual := e.applyOverride(t, ai)
+
+	// ── СПАМ: сохраняем для аналитики, менеджер не назначается ──
+	if ai.Type == "Спам" && !isManual {
+		fmt.Printf("   🚫 Спам — менеджер не назначается\n")
+		routingResult = RoutingResult{
+			GUID:           t.GUID,
+			CityOriginal:   t.RawCity,
+			Attachment:     t.Attachment,
+			Segment:        t.Segment,
+			Type:           ai.Type,
+			Sentiment:      ai.Sentiment,
+			Language:       ai.Language,
+			Priority:       ai.Priority,
+			Summary:        ai.Summary,
+			ManagerName:    "—",
+			ManagerRole:    "—",
+			AssignedOffice: "—",
+			RoutingReason:  "Спам — менеджер не назначается",
+			GeoMethod:      ai.GeoMethod,
+			GeoPrecision:   ai.GeoPrecision,
+			Source:         ai.Source,
+			IsEscalated:    false,
+			GeoLat:         ai.GeoLat,
+			GeoLon:         ai.GeoLon,
+			LinkDomains:    ai.LinkDomains,
+		}
+	} else {
+		winner, assignedOffice, isEscalated, vipGap := manual, manualOffice, false, false
+		if !isManual {
+			winner, assignedOffice, isEscalated, vipGap = e.routeTicket(t, ai)
+		}
+		managerName, managerRole := "Не найден", "—"
+		routingReason := buildNoMatchReason(t.Segment, ai)
+		if winner != nil {
+			managerName = winner.Name
+			managerRole = winner.Role
+			routingReason = buildRoutingReason(t.Segment, ai, ai.GeoMethod)
+			if isManual {
+				routingReason = manualAssignmentReason
+			}
+			fmt.Printf("   🎯 %s (%s) → офис %s\n", managerName, managerRole, assignedOffice)
+		} else {
+			fmt.Printf("   ❌ Менеджер не найден\n")
+		}
+
+		// Эскалация
+		displayOffice := assignedOffice
+		if isEscalated {
+			displayOffice = assignedOffice
+		}
+		routingResult = RoutingResult{
+			GUID:           t.GUID,
+			CityOriginal:   t.RawCity,
+			Attachment:     t.Attachment,
+			Segment:        t.Segment,
+			Type:           ai.Type,
+			Sentiment:      ai.Sentiment,
+			Language:       ai.Language,
+			Priority:       ai.Priority,
+			Summary:        ai.Summary,
+			ManagerName:    managerName,
+			ManagerRole:    managerRole,
+			AssignedOffice: displayOffice,
+			RoutingReason:  routingReason,
+			GeoMethod:      ai.GeoMethod,
+			GeoPrecision:   ai.GeoPrecision,
+			Source:         ai.Source,
+			IsEscalated:    isEscalated,
+			GeoLat:         ai.GeoLat,
+			GeoLon:         ai.GeoLon,
+			LinkDomains:    ai.LinkDomains,
+			VIPGap:         vipGap,
+		}
+		// Расстояние — только до ближайшего офиса, куда тикет и ушёл. Эскалация в ГО,
+		// 50/50 (too_far, адрес не найден, иностранец) и ручное назначение в другой
+		// офис выбраны не по близости — расстояние не показательно
+		if !isEscalated && ai.NearestOffice != "" && displayOffice == ai.NearestOffice && ai.GeoMethod != "foreign" {
+			routingResult.DistanceKm = e.distanceToOffice(ai.GeoLat, ai.GeoLon, displayOffice)
+		}
+	}
+
+	if routingResult.Type != "Спам" {
+		routingResult.AltOffices = e.formatAltOffices(ai.GeoLat, ai.GeoLon)
+	}
+	if isLLMSource(ai.Source) {
+		routingResult.Confidence = ai.Confidence
+	}
+	routingResult.Tenant = e.tenant
+	routingResult.TypeSource = ai.TypeSource
+	routingResult.PriorityCategory = priorityCategory(routingResult.Priority)
+	routingResult.AttachmentType = attachmentType(t.Attachment)
+	routingResult.SpamDomain = ai.SpamDomain
+	routingResult.DueAt = formatDueAt(dueAt(t, routingResult))
+	if est := e.estimateResponse(routingResult); est > 0 {
+		routingResult.ResponseMin = int(est / time.Minute)
+		fmt.Printf("   ⏱️  Ожидаемый ответ: ≈%s\n", formatResponseTime(est))
+	}
+
+	recordRoutingMetrics(routingResult)
+	alerts.Add(routingResult)
+	return routingResult
+}
+
 // ═══════════════════════════════════════════════════════════
 //  ОСНОВНАЯ ОБРАБОТКА ТИКЕТОВ
 // ═══════════════════════════════════════════════════════════
@@ -970,24 +1703,32 @@ func buildRoutingReason(segment string, ai AIResult, geoMethod string) string {
 //  ПАРАЛЛЕЛЬНОЕ ГЕОКОДИРОВАНИЕ — кэш + rate limiter
 // ═══════════════════════════════════════════════════════════
 
-// geocodeAllParallel геокодирует все тикеты параллельно.
-// Соблюдает ограничение Nominatim (1 req/sec) через тикер.
-// Одинаковые адреса обслуживаются из кэша без повторных запросов.
-func geocodeAllParallel(tickets []TicketInput, aiResults map[int]AIResult) {
-	cache := make(map[string]struct {
-		office, method string
-		lat, lon       float64
-	})
+// geocodeAllParallel геокодирует все тикеты параллельно через e.geocoder.
+// Ограничение Nominatim соблюдает nominatimLimiter внутри geocodeAddress
+// (NOMINATIM_RATE, замедляется на 429/503); алиасы и иностранцы не ждут слота.
+// Одинаковые адреса обслуживаются из кэша без повторных запросов, с -city-memo —
+// и тикеты того же города с другой улицей (офис первого тикета города).
+// При отмене контекста ожидающие горутины выходят, HTTP-запросы прерываются.
+func (e *Engine) geocodeAllParallel(ctx context.Context, tickets []TicketInput, aiResults map[int]AIResult) {
+	type geoCacheEntry struct {
+		office, method, oblast, precision string
+		lat, lon                          float64
+	}
+	cache := make(map[string]geoCacheEntry)
+	var memo *cityMemo
+	if *cityMemoMode {
+		memo = newCityMemo()
+	}
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	// Nominatim: не более 1 запроса в секунду
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
-
-	fmt.Printf("🌐 Геокодирование %d тикетов (rate limit 1 req/sec, с кэшем)...\n", len(tickets))
+	fmt.Printf("🌐 Геокодирование %d тикетов (интервал Nominatim %v, с кэшем)...\n", len(tickets), nominatimLimiter.base)
+	progress := newProgress("Геокодирование", len(tickets))
 
 	for i := range tickets {
+		if ctx.Err() != nil {
+			break
+		}
 		t := tickets[i]
 		ai := aiResults[t.Index]
 		cacheKey := t.Country + "|" + t.Oblast + "|" + t.RawCity + "|" + t.Street + "|" + t.House
@@ -996,423 +1737,1118 @@ func geocodeAllParallel(tickets []TicketInput, aiResults map[int]AIResult) {
 		if hit, ok := cache[cacheKey]; ok {
 			// Адрес уже геокодирован — берём из кэша
 			ai.GeoLat, ai.GeoLon, ai.GeoMethod = hit.lat, hit.lon, hit.method
-			if hit.office != "" {
+			ai.DerivedOblast, ai.GeoPrecision = hit.oblast, hit.precision
+			if hit.office != "" || hit.method == "too_far" {
 				ai.NearestOffice = hit.office
 			}
 			aiResults[t.Index] = ai
 			mu.Unlock()
 			fmt.Printf("   💾 Кэш: '%s' → '%s'\n", t.RawCity, hit.office)
+			timings.AddGeocode(t.GUID, 0)
+			progress.Inc()
 			continue
 		}
 		mu.Unlock()
+		memoEntry, memoLeader := memo.claim(t)
 
 		wg.Add(1)
 		go func(ticket TicketInput, llmOffice, key string, idx int) {
 			defer wg.Done()
-			<-ticker.C // ждём свой слот (1 req/sec)
-			office, lat, lon, method := resolveOfficeForTicket(ticket, llmOffice)
+			// Город уже геокодирован другим тикетом — его офис без запроса
+			if !memoLeader && memoEntry.wait(ctx) {
+				mu.Lock()
+				a := aiResults[idx]
+				a.GeoLat, a.GeoLon, a.GeoMethod = memoEntry.lat, memoEntry.lon, "city-memo"
+				a.DerivedOblast, a.GeoPrecision = memoEntry.oblast, geoPrecisionCity
+				a.NearestOffice = memoEntry.office
+				aiResults[idx] = a
+				mu.Unlock()
+				memo.hit()
+				timings.AddGeocode(ticket.GUID, 0)
+				progress.Inc()
+				return
+			}
+			start := time.Now()
+			office, lat, lon, method, oblast, precision := e.resolveOfficeForTicket(ctx, ticket, llmOffice)
+			timings.AddGeocode(ticket.GUID, time.Since(start))
+			if memoEntry != nil && memoLeader {
+				memoEntry.finish(office, lat, lon, method, oblast)
+			}
+			if ctx.Err() != nil {
+				return
+			}
 
 			mu.Lock()
-			cache[key] = struct {
-				office, method string
-				lat, lon       float64
-			}{office, method, lat, lon}
+			cache[key] = geoCacheEntry{office, method, oblast, precision, lat, lon}
 			a := aiResults[idx]
 			a.GeoLat, a.GeoLon, a.GeoMethod = lat, lon, method
-			if office != "" {
+			a.DerivedOblast, a.GeoPrecision = oblast, precision
+			if office != "" || method == "too_far" {
 				a.NearestOffice = office
 			}
 			aiResults[idx] = a
 			mu.Unlock()
+			progress.Inc()
 		}(t, ai.NearestOffice, cacheKey, t.Index)
 	}
 	wg.Wait()
+	if ctx.Err() != nil {
+		fmt.Println("🛑 Геокодирование прервано")
+		return
+	}
+	if memo != nil {
+		fmt.Printf("✅ Геокодирование завершено (из памяти города: %d тикетов)\n", memo.Hits())
+		return
+	}
 	fmt.Println("✅ Геокодирование завершено")
 }
 
-func processAllTickets(fp, apiKey string) {
-	file, err := os.Open(fp)
-	if err != nil {
-		log.Fatalf("❌ Не удалось открыть %s: %v", fp, err)
+// utf8BOM — метка порядка байтов в начале results.csv (для Excel)
+const utf8BOM = "\ufeff"
+
+// resultsCSVHeader — колонки results.csv (совместимы с app.py и load_results.py)
+var resultsCSVHeader = []string{
+	"GUID",
+	"Сегмент",
+	"Тип",
+	"Тональность",
+	"Язык",
+	"Приоритет",
+	"Рекомендации менеджеру",
+	"Вложения",
+	"Назначенный Менеджер",
+	"Должность",
+	"Офис Назначения",
+	"Эскалирован",
+	"Город_оригинал",
+	"Причина_роутинга",
+	"AI_Источник",
+	"Метод_гео",
+	"Расстояние_км",
+	"Альтернативные_офисы",
+	"Домены_ссылок",
+	"Источник_типа",
+	"Точность_гео",
+	"Категория_приоритета",
+	"Тип_вложения",
+	"Домен_спама",
+	"Срок_SLA",
+	"Ожидаемый_ответ_мин",
+}
+
+// resultCSVRow — строка results.csv в порядке resultsCSVHeader
+func resultCSVRow(r RoutingResult) []string {
+	escalatedStr := "Нет"
+	if r.IsEscalated {
+		escalatedStr = "Да"
+	}
+	attachOutput := r.Attachment
+	if strings.TrimSpace(attachOutput) == "" {
+		attachOutput = "—"
+	}
+	distance := ""
+	if r.DistanceKm > 0 {
+		distance = strconv.FormatFloat(r.DistanceKm, 'f', 1, 64)
+	}
+	responseMin := ""
+	if r.ResponseMin > 0 {
+		responseMin = strconv.Itoa(r.ResponseMin)
+	}
+	return []string{
+		r.GUID,
+		r.Segment,
+		r.Type,
+		r.Sentiment,
+		r.Language,
+		r.Priority,
+		r.Summary,
+		attachOutput,
+		r.ManagerName,
+		r.ManagerRole,
+		r.AssignedOffice,
+		escalatedStr,
+		r.CityOriginal,
+		r.RoutingReason,
+		r.Source,
+		r.GeoMethod,
+		distance,
+		r.AltOffices,
+		r.LinkDomains,
+		r.TypeSource,
+		r.GeoPrecision,
+		r.PriorityCategory,
+		r.AttachmentType,
+		r.SpamDomain,
+		r.DueAt,
+		responseMin,
 	}
-	defer file.Close()
+}
+
+// priorityNum — числовой приоритет для сортировки; нечисловой → 0
+func priorityNum(p string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(p))
+	return n
+}
 
-	records, err := csv.NewReader(file).ReadAll()
+// sortResultsForOutput — по офису, затем по убыванию приоритета,
+// при равном приоритете эскалированные раньше; стабильно относительно входа
+func sortResultsForOutput(results []RoutingResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.AssignedOffice != b.AssignedOffice {
+			return a.AssignedOffice < b.AssignedOffice
+		}
+		if pa, pb := priorityNum(a.Priority), priorityNum(b.Priority); pa != pb {
+			return pa > pb
+		}
+		return a.IsEscalated && !b.IsEscalated
+	})
+}
+
+// processAllTickets — полный цикл: чтение → AI → геокодирование → роутинг → запись.
+// Возвращает результаты роутинга новых тикетов (для экспортёров).
+// При отмене ctx уже записанные строки CSV и начатые сохранения в БД дописываются.
+// Ошибка — входной файл или results.csv недоступны; завершать ли процесс, решает main.
+func (e *Engine) processAllTickets(ctx context.Context, fp string, keys *apiKeyPool) ([]RoutingResult, error) {
+	timings = newRunTimings() // хронометраж — по каждому проходу (-watch)
+	aiUsage.Reset()
+	records, err := readTable(fp)
 	if err != nil {
-		log.Fatalf("❌ Ошибка чтения tickets: %v", err)
+		return nil, fmt.Errorf("чтение tickets %s: %v", fp, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s пуст — нет даже заголовка", fp)
+	}
+	// Колонки ищутся по заголовку: перестановка колонок не ломает роутинг
+	cols, err := mapTicketColumns(records[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fp, err)
 	}
 
 	// ── Читаем уже обработанные GUIDы (инкрементальная обработка) ──
 	processedGUIDs := make(map[string]bool)
+	processedKey := func(t TicketInput) string { return t.GUID } // results.csv: тенанта в файле нет
 	needHeader := true
-	outPath := "data/results.csv"
+	outPath := *resultsOut
+	toStdout := outPath == "-" // -out -: CSV в stdout, файла результатов нет
 
 	// Проверяем существование и содержимое файла
-	if info, err := os.Stat(outPath); err == nil && info.Size() > 0 {
+	if info, err := os.Stat(outPath); !toStdout && err == nil && info.Size() > 0 {
 		// Файл существует и не пуст – заголовок уже есть, писать его повторно не нужно
 		needHeader = false
-		existing, err := os.Open(outPath)
+	}
+	// Источник «уже обработано»: routing_results (-dedup-db, если БД доступна) или сам файл -out
+	switch {
+	case !*dedupGUIDs:
+	case *dedupDB && e.db != nil:
+		guids, err := loadProcessedGUIDs(ctx, e.db)
 		if err == nil {
-			rows, _ := csv.NewReader(existing).ReadAll()
-			existing.Close()
-			if len(rows) > 1 {
-				for _, row := range rows[1:] {
-					if len(row) > 0 {
-						processedGUIDs[strings.TrimSpace(row[0])] = true
-					}
+			processedGUIDs = guids
+			processedKey = func(t TicketInput) string { return tenantKey(t.Tenant, t.GUID) }
+			fmt.Printf("📂 Уже обработано (routing_results): %d тикетов, обработаем только новые\n", len(processedGUIDs))
+			break
+		}
+		log.Printf("⚠️ routing_results не прочитана, дедупликация по %s: %v", outPath, err)
+		fallthrough
+	case !needHeader:
+		if needHeader {
+			break // сюда же проваливается -dedup-db без БД, а файла -out ещё нет
+		}
+		// Нечитаемый файл — стоп: иначе все тикеты ушли бы в AI заново и задвоились в -out
+		rows, err := readResultRows(outPath)
+		if err != nil {
+			return nil, fmt.Errorf("чтение обработанных GUID из %s: %v", outPath, err)
+		}
+		if len(rows) > 1 {
+			for _, row := range rows[1:] {
+				if len(row) > 0 && row[0] != totalsGUID {
+					processedGUIDs[row[0]] = true
 				}
-				fmt.Printf("📂 Уже обработано: %d тикетов, обработаем только новые\n", len(processedGUIDs))
 			}
+			fmt.Printf("📂 Уже обработано: %d тикетов, обработаем только новые\n", len(processedGUIDs))
+		}
+	}
+
+	// ── Хэши содержимого из БД: обработанный тикет с изменённым текстом обрабатывается заново ──
+	var storedHashes map[string]string
+	if e.db != nil && len(processedGUIDs) > 0 {
+		if storedHashes, err = loadTicketHashes(ctx, e.db); err != nil {
+			log.Printf("⚠️ content_hash не прочитаны, изменённые тикеты не переобрабатываются: %v", err)
 		}
 	}
+	changedGUIDs := make(map[string]bool)
+
+	// ── Отчёты о проблемных тикетах и расхождениях AI (пишутся при любом выходе) ──
+	rejected := &rejectReport{}
+	disagreements := &disagreementReport{}
+	defer func() {
+		if err := rejected.Write(rejectedPath); err != nil {
+			log.Printf("⚠️ %s не записан: %v", rejectedPath, err)
+		}
+		if err := disagreements.Write(disagreementsPath); err != nil {
+			log.Printf("⚠️ %s не записан: %v", disagreementsPath, err)
+		}
+	}()
 
 	// ── Собираем необработанные тикеты ───────────────────────────
 	var tickets []TicketInput
+	firstRow := make(map[string]int) // tenantKey → номер строки первого вхождения в файле
+	var duplicates []string
 	for i, row := range records {
-		if i == 0 || len(row) < 9 {
+		if i == 0 {
+			continue
+		}
+		guid := cols.Get(row, "guid")
+		if guid == "" {
 			continue
 		}
-		guid := strings.TrimSpace(strings.TrimPrefix(row[0], "\uFEFF"))
-		if processedGUIDs[guid] {
+		// Повтор GUID тенанта внутри файла: в БД второй тикет потерялся бы на ON CONFLICT DO NOTHING.
+		// Тот же GUID у другого тенанта — другой тикет
+		key := tenantKey(cols.Get(row, "tenant"), guid)
+		if first, dup := firstRow[key]; dup {
+			duplicates = append(duplicates, guid)
+			rejected.Add(guid, rejectDuplicateGUID, fmt.Sprintf("строка %d повторяет GUID строки %d", i+1, first))
 			continue
 		}
-		text := strings.TrimSpace(row[3])
-		attach := strings.TrimSpace(row[4])
-		if text == "" && attach == "" {
+		firstRow[key] = i + 1
+		ticket := cols.Ticket(row)
+		if ticket.Text == "" && ticket.Attachment == "" {
 			fmt.Printf("⚠️ Пропускаем GUID %s: нет текста и вложения\n", guid[:min(8, len(guid))])
+			rejected.Add(guid, rejectEmptyContent, "нет текста и вложения")
 			continue
 		}
+		ticket.Index = len(tickets)
+		if _, ok := e.forTenant(ticket.Tenant); !ok {
+			rejected.Add(guid, rejectUnknownTenant, fmt.Sprintf("тенант '%s' нет в tenants.csv", ticket.Tenant))
+			continue
+		}
+		if processedGUIDs[processedKey(ticket)] {
+			if h, ok := storedHashes[tenantKey(ticket.Tenant, guid)]; !ok || h == ticketContentHash(ticket) {
+				continue
+			}
+			changedGUIDs[guid] = true
+		}
+		if err := fillAge(&ticket); err != nil {
+			rejected.Add(guid, rejectInvalidBirthdate, err.Error())
+		}
+		if err := fillCreatedAt(&ticket); err != nil {
+			rejected.Add(guid, rejectInvalidCreatedAt, err.Error())
+		}
+		tickets = append(tickets, ticket)
+	}
 
-		house := ""
-		if len(row) > 10 {
-			house = strings.TrimSpace(row[10])
-		}
-
-		tickets = append(tickets, TicketInput{
-			Index:      len(tickets),
-			GUID:       guid,
-			Gender:     strings.TrimSpace(row[1]),
-			Birthdate:  strings.TrimSpace(row[2]),
-			Text:       text,
-			Attachment: attach,
-			Segment:    strings.TrimSpace(row[5]),
-			Country:    strings.TrimSpace(row[6]),
-			Oblast:     strings.TrimSpace(row[7]),
-			RawCity:    strings.TrimSpace(row[8]),
-			Street:     strings.TrimSpace(row[9]),
-			House:      house,
-		})
+	if len(duplicates) > 0 {
+		fmt.Printf("⚠️  Дубликаты GUID в %s: %d строк пропущено (оставлено первое вхождение): %v\n",
+			fp, len(duplicates), duplicates)
 	}
 
 	if len(tickets) == 0 {
 		fmt.Println("✅ Все тикеты уже обработаны. Нечего делать.")
-		return
+		return nil, nil
 	}
 	fmt.Printf("\n🚀 Новых тикетов для обработки: %d\n", len(tickets))
-
-	// ── ФИЧА: Обнаружение дублирующихся GUID в текущем батче ──────
-	guidCount := make(map[string][]int) // GUID → список индексов
-	for _, t := range tickets {
-		guidCount[t.GUID] = append(guidCount[t.GUID], t.Index)
-	}
-	for guid, indices := range guidCount {
-		if len(indices) > 1 {
-			shortGUID := guid
-			if len(guid) > 8 {
-				shortGUID = guid[:8]
+	if *priorityOrder {
+		rankByPriority(tickets)
+	}
+	applyOCR(ctx, tickets)
+	if len(changedGUIDs) > 0 {
+		fmt.Printf("♻️  Из них с изменённым содержимым: %d\n", len(changedGUIDs))
+		if !needHeader { // с -dedup-db файла результатов может и не быть
+			if err := dropResultRows(outPath, changedGUIDs); err != nil {
+				return nil, fmt.Errorf("%s: удаление устаревших строк: %v", outPath, err)
 			}
-			fmt.Printf("⚠️  ДУБЛИКАТ: клиент %s прислал %d обращений в одном батче (индексы: %v) — возможен бот или технический сбой\n",
-				shortGUID, len(indices), indices)
 		}
 	}
 
 	// ── Открываем выходной файл ───────────────────────────────────
-	os.MkdirAll("data", 0755)
-	outFile, err := os.OpenFile(outPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatalf("❌ Не удалось открыть results.csv: %v", err)
+	out := resultsStdout
+	if !toStdout {
+		if dir := filepath.Dir(outPath); dir != "." {
+			os.MkdirAll(dir, 0755)
+		}
+		outFile, err := os.OpenFile(outPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("открытие %s: %v", outPath, err)
+		}
+		defer outFile.Close()
+		out = outFile
 	}
-	defer outFile.Close()
+	encoded := encodeResults(out)
+	defer encoded.Close()
 
-	writer := csv.NewWriter(outFile)
+	writer := newCSVWriter(encoded)
 	defer writer.Flush()
 
 	// ── Заголовок CSV ────────────────────────────────────────────
+	// BOM только в новом файле: по нему Excel узнаёт UTF-8 (иначе кириллица — кракозябры).
+	// При дозаписи BOM уже стоит в начале файла, второй в середине не нужен;
+	// в stdout (конвейер) и в cp1251 (-out-encoding) BOM не пишется.
 	if needHeader {
-		writer.Write([]string{
-			"GUID",
-			"Сегмент",
-			"Тип",
-			"Тональность",
-			"Язык",
-			"Приоритет",
-			"Рекомендации менеджеру",
-			"Вложения",
-			"Назначенный Менеджер",
-			"Должность",
-			"Офис Назначения",
-			"Эскалирован",
-			"Город_оригинал",
-			"Причина_роутинга",
-			"AI_Источник",
-			"Метод_гео",
-		})
+		if !toStdout {
+			io.WriteString(out, resultsBOM())
+		}
+		writer.Write(resultsCSVHeader)
 		writer.Flush()
 	}
 
-	// ── AI АНАЛИЗ — чанками по 10 тикетов (избегаем TPM rate limit) ──
-	aiResults, _ := analyzeAllInChunks(tickets, apiKey, 10, 3)
+	// ── Окна (-window N): AI → геокодирование → роутинг → запись → БД по N тикетов,
+	// затем следующее окно. Без -window весь файл — одно окно.
+	window := len(tickets)
+	windowed := *windowSize > 0 && *windowSize < len(tickets)
+	if windowed {
+		window = *windowSize
+	}
 
-	// Fallback для тикетов, которые AI пропустил
-	for _, t := range tickets {
-		if _, ok := aiResults[t.Index]; !ok {
-			fmt.Printf("   ⚠️ AI пропустил тикет %d (GUID %s) → Keyword Fallback\n",
-				t.Index, t.GUID[:min(8, len(t.GUID))])
-			aiResults[t.Index] = fallbackAnalyze(t)
-		}
+	// Тикеты из чекпоинта прошлого (упавшего) запуска в AI не отправляются
+	checkpoint := loadAICheckpoint(aiCheckpointPath, func(tenant, guid string) bool {
+		return processedGUIDs[processedKey(TicketInput{Tenant: tenant, GUID: guid})]
+	})
+
+	// allResults копится только без окон (для -sorted, рабочих списков, GeoJSON);
+	// итоговая статистика считается по мере записи
+	var allResults []RoutingResult
+	stats := newSummaryStats()
+	written := 0
+
+	// Пакетная запись в БД вместо трёх INSERT на тикет
+	var batcher *dbBatcher
+	if e.db != nil && *dbBatchSize > 0 {
+		batcher = newDBBatcher(ctx, e.db, *dbBatchSize)
 	}
+	progress := newProgress("Роутинг", len(tickets))
 
-	// ── Бизнес-правило: VIP/Priority → принудительный приоритет 10 ──
-	for _, t := range tickets {
-		if needsVIP(t.Segment) {
-			if r, ok := aiResults[t.Index]; ok && r.Priority != "10" {
-				fmt.Printf("   👑 %s | Сегмент %s → приоритет 10 (было %s)\n",
-					t.GUID[:min(8, len(t.GUID))], t.Segment, r.Priority)
-				r.Priority = "10"
+	completed := true
+	for start := 0; start < len(tickets); start += window {
+		win := tickets[start:min(start+window, len(tickets))]
+		if windowed {
+			fmt.Printf("\n🪟 Окно %d–%d из %d тикетов\n", start+1, start+len(win), len(tickets))
+		}
+
+		// ── AI АНАЛИЗ — чанками по 10 тикетов (избегаем TPM rate limit) ──
+		var needAI []TicketInput
+		for _, t := range win {
+			if _, ok := checkpoint.Get(t); !ok {
+				needAI = append(needAI, t)
+			}
+		}
+		aiInput, dupOf := needAI, map[int]int(nil)
+		if *dedupContent {
+			aiInput, dupOf = dedupByContent(needAI)
+		}
+		aiStart := time.Now()
+		aiResults, err := e.analyzeByTenant(ctx, aiInput, keys, 10, 3)
+		timings.AddAI(time.Since(aiStart))
+		copyDuplicateResults(aiResults, dupOf)
+		for _, t := range needAI {
+			if r, ok := aiResults[t.Index]; ok {
+				checkpoint.Put(t, r)
+			}
+		}
+		if cpErr := saveAICheckpoint(aiCheckpointPath, checkpoint); cpErr != nil {
+			log.Printf("⚠️ Чекпоинт AI не записан: %v", cpErr)
+		}
+		if err != nil {
+			fmt.Printf("🛑 Остановлено во время AI-анализа: %v\n", err)
+			completed = false
+			break
+		}
+		for _, t := range win {
+			if r, ok := checkpoint.Get(t); ok {
 				aiResults[t.Index] = r
 			}
 		}
-	}
 
-	// ── ФАЗА 1: Параллельное геокодирование (кэш + 1 req/sec) ───────
-	geocodeAllParallel(tickets, aiResults)
+		// Fallback для тикетов, которые AI пропустил
+		for _, t := range win {
+			if r, ok := aiResults[t.Index]; !ok {
+				fmt.Printf("   ⚠️ AI пропустил тикет %d (GUID %s) → Keyword Fallback\n",
+					t.Index, t.GUID[:min(8, len(t.GUID))])
+				aiResults[t.Index] = fallbackAnalyze(t)
+				rejected.Add(t.GUID, rejectAISkipped, "нет в ответе AI")
+			} else if r.Source == "Fallback" {
+				rejected.Add(t.GUID, rejectAIFailed, "чанк не получил ответ AI")
+			}
+		}
 
-	// ── ФАЗА 2: Роутинг + запись ─────────────────────────────────────
-	fmt.Println("\n📋 Роутинг тикетов...")
-	fmt.Println(strings.Repeat("─", 70))
+		// ── Бизнес-правила: ссылки рассылок → Спам; VIP/Priority → приоритет 10; клиенты 65+ → выше ──
+		for _, t := range win {
+			if r, ok := aiResults[t.Index]; ok {
+				aiResults[t.Index] = applyPriorityRules(t, applyLinkSpam(t, r))
+			}
+		}
 
-	var allResults []RoutingResult
+		// ── ФАЗА 1: Параллельное геокодирование (кэш + 1 req/sec) ───────
+		geoStart := time.Now()
+		e.geocodeByTenant(ctx, win, aiResults)
+		timings.AddGeocodeWall(time.Since(geoStart))
+		if ctx.Err() != nil {
+			fmt.Printf("🛑 Остановлено до роутинга — записано %d из %d тикетов\n", written, len(tickets))
+			completed = false
+			break
+		}
 
-	for _, t := range tickets {
-		ai := aiResults[t.Index]
-		shortGUID := t.GUID
-		if len(t.GUID) > 8 {
-			shortGUID = t.GUID[:8]
-		}
-
-		fmt.Printf("\n[%d/%d] %s | %s | %s | приор.%s | офис:'%s' [%s]\n",
-			t.Index+1, len(tickets), shortGUID, t.RawCity, ai.Type, ai.Priority,
-			ai.NearestOffice, ai.GeoMethod)
-
-		var routingResult RoutingResult
-
-		// ── СПАМ: сохраняем для аналитики, менеджер не назначается ──
-		if ai.Type == "Спам" {
-			fmt.Printf("   🚫 Спам — менеджер не назначается\n")
-			routingResult = RoutingResult{
-				GUID:           t.GUID,
-				CityOriginal:   t.RawCity,
-				Segment:        t.Segment,
-				Type:           ai.Type,
-				Sentiment:      ai.Sentiment,
-				Language:       ai.Language,
-				Priority:       ai.Priority,
-				Summary:        ai.Summary,
-				ManagerName:    "—",
-				ManagerRole:    "—",
-				AssignedOffice: "—",
-				RoutingReason:  "Спам — менеджер не назначается",
-				GeoMethod:      ai.GeoMethod,
-				Source:         ai.Source,
-				IsEscalated:    false,
+		// ── ФАЗА 2: Роутинг + запись ─────────────────────────────────────
+		fmt.Println("\n📋 Роутинг тикетов...")
+		fmt.Println(strings.Repeat("─", 70))
+
+		// Воркеры (-route-workers) только роутят; запись в CSV и БД — здесь, в порядке входа
+		route := func(t TicketInput) routedTicket {
+			ai := aiResults[t.Index]
+			shortGUID := t.GUID
+			if len(t.GUID) > 8 {
+				shortGUID = t.GUID[:8]
 			}
-		} else {
-			winner, assignedOffice, isEscalated := routeTicket(t, ai)
-			managerName, managerRole := "Не найден", "—"
-			routingReason := buildNoMatchReason(t.Segment, ai)
-			if winner != nil {
-				managerName = winner.Name
-				managerRole = winner.Role
-				routingReason = buildRoutingReason(t.Segment, ai, ai.GeoMethod)
-				fmt.Printf("   🎯 %s (%s) → офис %s\n", managerName, managerRole, assignedOffice)
+
+			fmt.Printf("\n[%d/%d] %s | %s | %s | приор.%s | офис:'%s' [%s]\n",
+				t.Index+1, len(tickets), shortGUID, t.RawCity, ai.Type, ai.Priority,
+				ai.NearestOffice, ai.GeoMethod)
+
+			routeStart := time.Now()
+			fbType, disagree := crossCheckAI(t, ai)
+			aiType := ai.Type
+			ai = resolveType(t, ai, fbType, disagree) // aiResults только читается: воркеры параллельны
+
+			routingResult := e.engineFor(t).buildRoutingResult(t, ai)
+			if routingResult.ManagerName == "Не найден" {
+				rejected.Add(t.GUID, rejectManagerNotFound, routingResult.RoutingReason)
+			}
+			if disagree {
+				routingResult.NeedsReview = true
+				disagreements.Add(routingResult, aiType, fbType)
+				fmt.Printf("   🔍 AI: '%s', ключевые слова: '%s' → на проверку\n", aiType, fbType)
+			}
+			routingResult.ReviewReason = reviewReason(routingResult)
+			switch ai.GeoMethod {
+			case "unknown":
+				rejected.Add(t.GUID, rejectGeocodeFailed, fmt.Sprintf("адрес '%s, %s' не найден → 50/50", t.Oblast, t.RawCity))
+			case "llm":
+				rejected.Add(t.GUID, rejectGeocodeFailed, fmt.Sprintf("адрес '%s, %s' не найден → офис по LLM", t.Oblast, t.RawCity))
+			case "oblast-centroid":
+				rejected.Add(t.GUID, rejectGeocodeFailed, fmt.Sprintf("адрес '%s, %s' не найден → офис области", t.Oblast, t.RawCity))
+			}
+
+			timings.AddRouting(t.GUID, time.Since(routeStart))
+			return routedTicket{T: t, AI: ai, R: routingResult}
+		}
+		emit := func(rt routedTicket) {
+			stats.Add(rt.R)
+			if !windowed {
+				allResults = append(allResults, rt.R)
+			}
+
+			// ── CSV write (последовательно — порядок важен) ───────────────
+			// С -sorted строки копятся в allResults и пишутся после цикла
+			if !*sortedOutput {
+				writer.Write(resultCSVRow(rt.R))
+				writer.Flush()
+			}
+
+			// ── БД (асинхронно — CSV не ждёт) ──────────────────────────────
+			if batcher != nil {
+				batcher.Add(rt.T, rt.AI, rt.R)
 			} else {
-				fmt.Printf("   ❌ Менеджер не найден\n")
+				saveAllAsync(ctx, e.db, rt.T, rt.AI, rt.R)
 			}
+			progress.Inc()
+		}
+		phaseStart := time.Now()
+		written += routeTicketsOrdered(ctx, win, *routeWorkers, route, emit)
+		timings.AddRoutingWall(time.Since(phaseStart))
+		if ctx.Err() != nil {
+			fmt.Printf("\n🛑 Остановлено: записано %d из %d тикетов\n", written, len(tickets))
+			completed = false
+			break
+		}
 
-			// Эскалация
-			displayOffice := assignedOffice
-			if isEscalated {
-				displayOffice = assignedOffice
+		// Окно записано — его ответы AI в чекпоинте больше не нужны (память не растёт)
+		if windowed {
+			for _, t := range win {
+				checkpoint.Delete(t)
 			}
-			routingResult = RoutingResult{
-				GUID:           t.GUID,
-				CityOriginal:   t.RawCity,
-				Segment:        t.Segment,
-				Type:           ai.Type,
-				Sentiment:      ai.Sentiment,
-				Language:       ai.Language,
-				Priority:       ai.Priority,
-				Summary:        ai.Summary,
-				ManagerName:    managerName,
-				ManagerRole:    managerRole,
-				AssignedOffice: displayOffice,
-				RoutingReason:  routingReason,
-				GeoMethod:      ai.GeoMethod,
-				Source:         ai.Source,
-				IsEscalated:    isEscalated,
+			if cpErr := saveAICheckpoint(aiCheckpointPath, checkpoint); cpErr != nil {
+				log.Printf("⚠️ Чекпоинт AI не записан: %v", cpErr)
 			}
 		}
+	}
+	if batcher != nil {
+		batcher.Close()
+	}
+	// Все тикеты запуска в results.csv — чекпоинт больше не нужен
+	if completed {
+		os.Remove(aiCheckpointPath)
+	}
 
-		allResults = append(allResults, routingResult)
-
-		// ── CSV write (последовательно — порядок важен) ───────────────
-		escalatedStr := "Нет"
-		if routingResult.IsEscalated {
-			escalatedStr = "Да"
-		}
-
-		// --- ПРОВЕРЯЕМ ВЛОЖЕНИЕ ДЛЯ ТЕКУЩЕГО ТИКЕТА ---
-		attachOutput := t.Attachment
-		if strings.TrimSpace(attachOutput) == "" {
-			attachOutput = "—"
-		}
-		// -------------------------------------------------
-
-		writer.Write([]string{
-			routingResult.GUID,
-			routingResult.Segment,
-			routingResult.Type,
-			routingResult.Sentiment,
-			routingResult.Language,
-			routingResult.Priority,
-			routingResult.Summary,
-			attachOutput,
-			routingResult.ManagerName,
-			routingResult.ManagerRole,
-			routingResult.AssignedOffice,
-			escalatedStr,
-			routingResult.CityOriginal,
-			routingResult.RoutingReason,
-			routingResult.Source,
-			routingResult.GeoMethod,
-		})
+	// ── -sorted: офис → приоритет по убыванию → эскалированные первыми ──
+	if *sortedOutput {
+		sorted := append([]RoutingResult(nil), allResults...)
+		sortResultsForOutput(sorted)
+		for _, r := range sorted {
+			writer.Write(resultCSVRow(r))
+		}
 		writer.Flush()
 	}
 
+	// ── -totals: строки ИТОГО в конце файла (пересчёт по всему results.csv) ──
+	if *totalsRows && toStdout {
+		log.Printf("⚠️ -totals пропущен: строки ИТОГО дописываются только в файл, а не в stdout")
+	} else if *totalsRows {
+		if err := rewriteTotals(outPath); err != nil {
+			log.Printf("⚠️ Строки %s не записаны: %v", totalsGUID, err)
+		}
+	}
+
+	// Дожидаемся фоновых сохранений в БД
+	dbWg.Wait()
+	if written == 0 {
+		return nil, nil
+	}
+
 	// ── Итоговая статистика ───────────────────────────────────────
-	printSummary(allResults)
-	fmt.Printf("\n✅ Готово! Обработано %d тикетов → %s\n", len(tickets), outPath)
+	e.printSummary(stats)
+	if e.db != nil {
+		if err := saveRunStats(ctx, e.db, stats); err != nil {
+			log.Printf("⚠️ Статистика запуска не записана в run_stats: %v", err)
+		}
+	}
+	timings.Print(e.db != nil)
+	aiUsage.Print()
+	if *timingsPath != "" {
+		if err := timings.Write(*timingsPath); err != nil {
+			log.Printf("⚠️ Хронометраж не записан: %v", err)
+		}
+	}
+	fmt.Printf("\n✅ Готово! Обработано %d тикетов → %s\n", written, outPath)
+	return allResults, nil
 }
 
 // ═══════════════════════════════════════════════════════════
 //  ИТОГОВАЯ СТАТИСТИКА
 // ═══════════════════════════════════════════════════════════
 
-func printSummary(results []RoutingResult) {
-	fmt.Println("\n" + strings.Repeat("═", 70))
-	fmt.Println("📊 ИТОГОВАЯ СТАТИСТИКА")
-	fmt.Println(strings.Repeat("═", 70))
+// summaryStats — агрегаты по результатам (консоль, строки ИТОГО в CSV)
+type summaryStats struct {
+	Total      int
+	Spam       int
+	Escalated  int
+	NoManager  int
+	Types      map[string]int
+	Sentiments map[string]int
+	Offices    map[string]int
+	// Priorities — тикетов по приоритету 1–10 (индекс — приоритет; 0 — нечисловой)
+	Priorities [11]int
+	// ResponseMin, ResponseN — сумма и число оценок времени ответа (среднее в итогах)
+	ResponseMin int
+	ResponseN   int
+	// VIPGapEscalated, VIPGapNoManager — из Escalated и NoManager: VIP-тикеты
+	// из офисов без VIP-менеджеров (RoutingResult.VIPGap)
+	VIPGapEscalated int
+	VIPGapNoManager int
+}
 
-	typeCounts := make(map[string]int)
-	sentimentCounts := make(map[string]int)
-	officeCounts := make(map[string]int)
-	noManager := 0
-	spam := 0
-	escalated := 0
+func newSummaryStats() *summaryStats {
+	return &summaryStats{
+		Types:      make(map[string]int),
+		Sentiments: make(map[string]int),
+		Offices:    make(map[string]int),
+	}
+}
 
-	for _, r := range results {
-		typeCounts[r.Type]++
-		sentimentCounts[r.Sentiment]++
-		officeCounts[r.AssignedOffice]++
-		if r.ManagerName == "Не найден" {
-			noManager++
-		}
-		if r.Type == "Спам" {
-			spam++
-		}
+// Add — учесть один результат (статистика копится по мере записи, см. -window)
+func (st *summaryStats) Add(r RoutingResult) {
+	st.Total++
+	st.Types[r.Type]++
+	st.Sentiments[r.Sentiment]++
+	st.Offices[r.AssignedOffice]++
+	if p := priorityNum(r.Priority); p >= 1 && p <= 10 {
+		st.Priorities[p]++
+	} else {
+		st.Priorities[0]++
+	}
+	if r.ManagerName == "Не найден" {
+		st.NoManager++
+	}
+	if r.Type == "Спам" {
+		st.Spam++
+	}
+	if r.IsEscalated {
+		st.Escalated++
+	}
+	if r.VIPGap {
 		if r.IsEscalated {
-			escalated++
+			st.VIPGapEscalated++
+		} else {
+			st.VIPGapNoManager++
 		}
 	}
+	if r.ResponseMin > 0 {
+		st.ResponseMin += r.ResponseMin
+		st.ResponseN++
+	}
+}
 
-	fmt.Printf("  Всего обработано: %d\n", len(results))
-	fmt.Printf("  Спам:             %d\n", spam)
-	fmt.Printf("  Эскалировано в ГО:%d\n", escalated)
-	fmt.Printf("  Без менеджера:    %d\n", noManager)
+func computeSummary(results []RoutingResult) summaryStats {
+	st := newSummaryStats()
+	for _, r := range results {
+		st.Add(r)
+	}
+	return *st
+}
+
+func (e *Engine) printSummary(st *summaryStats) {
+	fmt.Println("\n" + strings.Repeat("═", 70))
+	fmt.Println("📊 ИТОГОВАЯ СТАТИСТИКА")
+	fmt.Println(strings.Repeat("═", 70))
+
+	fmt.Printf("  Всего обработано: %d\n", st.Total)
+	fmt.Printf("  Спам:             %d\n", st.Spam)
+	fmt.Printf("  Эскалировано в ГО:%d\n", st.Escalated)
+	if st.VIPGapEscalated > 0 {
+		fmt.Printf("    из них VIP без покрытия: %d (в офисе нет VIP-менеджеров), обычных: %d\n",
+			st.VIPGapEscalated, st.Escalated-st.VIPGapEscalated)
+	}
+	fmt.Printf("  Без менеджера:    %d\n", st.NoManager)
+	if st.VIPGapNoManager > 0 {
+		fmt.Printf("    из них VIP из офиса без VIP-менеджеров (в ГО тоже никого): %d\n", st.VIPGapNoManager)
+	}
+	if st.ResponseN > 0 {
+		avg := time.Duration(st.ResponseMin/st.ResponseN) * time.Minute
+		fmt.Printf("  Ожидаемый ответ:  ≈%s в среднем\n", formatResponseTime(avg))
+	}
 
 	fmt.Println("\n  Типы обращений:")
-	for t, c := range typeCounts {
+	for t, c := range st.Types {
 		fmt.Printf("    %-40s %d\n", t, c)
 	}
 
+	printPriorityHistogram(st.Priorities)
+
 	fmt.Println("\n  Тональность:")
-	for s, c := range sentimentCounts {
+	for s, c := range st.Sentiments {
 		fmt.Printf("    %-20s %d\n", s, c)
 	}
 
 	fmt.Println("\n  Офисы назначения:")
-	for o, c := range officeCounts {
+	for o, c := range st.Offices {
 		fmt.Printf("    %-30s %d\n", o, c)
 	}
+
+	for _, te := range e.allTenants() {
+		te.printVIPGapEscalations(len(e.tenants) > 0)
+	}
+	for _, te := range e.allTenants() {
+		te.printLoadBalance(len(e.tenants) > 0)
+	}
+}
+
+// priorityHistogramWidth — длина самой длинной полосы гистограммы приоритетов
+const priorityHistogramWidth = 40
+
+// printPriorityHistogram — тикетов по приоритету 10→1 с полосами (по максимуму)
+func printPriorityHistogram(counts [11]int) {
+	peak := 0
+	for _, c := range counts {
+		peak = max(peak, c)
+	}
+	if peak == 0 {
+		return
+	}
+	fmt.Println("\n  Приоритеты:")
+	for p := 10; p >= 1; p-- {
+		bar := strings.Repeat("█", counts[p]*priorityHistogramWidth/peak)
+		if bar == "" && counts[p] > 0 {
+			bar = "▏"
+		}
+		fmt.Printf("    %2d │ %-*s %d\n", p, priorityHistogramWidth, bar, counts[p])
+	}
+	if counts[0] > 0 {
+		fmt.Printf("    без приоритета: %d\n", counts[0])
+	}
 }
 
+// printVIPGapEscalations — VIP-тикеты из офисов без VIP: эскалированные в ГО
+// (по офисам) и поштучно, включая не нашедших менеджера и в ГО («→ —»);
+// withTenant — подписать тенанта (их несколько)
+func (e *Engine) printVIPGapEscalations(withTenant bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.vipGapTickets) > 0 {
+		title := "VIP-тикеты из офисов без VIP-менеджеров"
+		if withTenant {
+			title += " (тенант " + e.tenant + ")"
+		}
+		fmt.Println("\n  " + title + ":")
+		for o, c := range e.vipGapEscalations {
+			fmt.Printf("    %-30s %d\n", o, c)
+		}
+		for i, g := range e.vipGapTickets {
+			if i == vipGapListLimit {
+				fmt.Printf("    ... и ещё %d\n", len(e.vipGapTickets)-i)
+				break
+			}
+			fmt.Printf("    • %s | %s, приор.%s | %s → %s\n", g.GUID[:min(8, len(g.GUID))], g.Segment, g.Priority, g.Office, g.HQ)
+		}
+	}
+}
+
+// vipGapListLimit — столько VIP-эскалаций из-за покрытия перечисляется поштучно
+const vipGapListLimit = 20
+
 // ═══════════════════════════════════════════════════════════
 //  MAIN
 // ═══════════════════════════════════════════════════════════
 
+var (
+	geojsonPath   = flag.String("geojson", "", "путь для GeoJSON-экспорта тикетов (например data/tickets.geojson)")
+	serveAddr     = flag.String("serve", "", "режим HTTP API, например :8080 (GET /results, POST /route, POST /review, POST /reassign, /metrics)")
+	sortedOutput  = flag.Bool("sorted", false, "писать results.csv после роутинга, отсортированным: офис → приоритет ↓ → эскалация")
+	dbBatchSize   = flag.Int("db-batch", 0, "сохранять в БД пачками по N тикетов (multi-row INSERT); 0 — поштучно")
+	totalsRows    = flag.Bool("totals", false, "дописать в конец results.csv строки ИТОГО (всего, спам, эскалации, по типам и тональности)")
+	csvDelim      = flag.String("csv-delim", "", "разделитель CSV: , ; или tab; пусто — определить по заголовку (вывод — запятая)")
+	metricsAddr   = flag.String("metrics", "", "адрес /metrics Prometheus на время пакетного запуска, например :9090")
+	strictVIP     = flag.Bool("strict-vip", false, "не запускаться, если в каком-либо офисе нет менеджера с навыком VIP")
+	timingsPath   = flag.String("timings", "", "записать время геокодирования и роутинга по тикетам в CSV, например data/timings.csv")
+	stdinInput    = flag.Bool("stdin", false, "читать тикеты (CSV) из стандартного ввода вместо tickets.csv")
+	resultsOut    = flag.String("out", "data/results.csv", "файл результатов; - — в stdout (служебный вывод уходит в stderr)")
+	outEncoding   = flag.String("out-encoding", "utf-8", "кодировка файла результатов: utf-8 (с BOM) или cp1251 (без BOM; казахские буквы → русские)")
+	dedupGUIDs    = flag.Bool("dedup", true, "пропускать GUID, уже записанные в файл -out (с -out - неприменимо)")
+	windowSize    = flag.Int("window", 0, "обрабатывать тикеты окнами по N (AI → геокод → роутинг → запись), не держа все результаты в памяти; 0 — весь файл сразу")
+	routeWorkers  = flag.Int("route-workers", 1, "параллельных воркеров роутинга (CSV всё равно пишется в порядке входа)")
+	dedupDB       = flag.Bool("dedup-db", false, "брать уже обработанные GUID из routing_results, а не из файла -out (если БД доступна)")
+	watchInterval = flag.Duration("watch", 0, "после первого прохода проверять tickets на новые GUID с этим интервалом, например 5m (0 — один проход)")
+	kafkaBrokers  = flag.String("kafka-brokers", "", "брокеры Kafka через запятую: режим потребителя вместо tickets.csv")
+	kafkaTopic    = flag.String("kafka-topic", "tickets", "топик с тикетами (JSON TicketInput)")
+	kafkaOutTopic = flag.String("kafka-out-topic", "", "топик для RoutingResult (JSON); пусто — только БД")
+	kafkaGroup    = flag.String("kafka-group", "fire-engine", "группа потребителей Kafka (offset'ы)")
+	configPath    = flag.String("config", "", "YAML-конфигурация (по умолчанию config.yaml, если есть); флаги и окружение важнее")
+	checkOnly     = flag.Bool("check", false, "только проверить конфигурацию (ключи AI, входные файлы, БД), напечатать отчёт и выйти")
+	pprofAddr     = flag.String("pprof", "", "адрес net/http/pprof для профилирования, например :6060 (по умолчанию выключен)")
+	logFormat     = flag.String("log-format", "text", "формат служебного вывода: text | json (json — без строк прогресса для людей)")
+	ensembleMode  = flag.Bool("ensemble", false, "тип обращения голосованием AI и Keyword Fallback: при расхождении побеждает Мошенничество/Претензия")
+	regeoMode     = flag.Bool("regeo", false, "повторно геокодировать и перероутить тикеты с Метод_гео=unknown (из БД или -out) без повторного AI-анализа")
+	dedupContent  = flag.Bool("dedup-content", false, "тикеты с одинаковым нормализованным текстом анализировать одним запросом к AI (рассылки под разными GUID)")
+	maskProfanity = flag.Bool("mask-profanity", false, "маскировать ненормативную лексику в summary (список — встроенный + data/profanity.txt)")
+	prioCategory  = flag.Bool("priority-category", false, "добавить категорию приоритета Low/Medium/High/Critical (PRIORITY_CATEGORY_BOUNDS) в CSV, JSON и БД")
+	ocrMode       = flag.Bool("ocr", false, "распознавать текст вложений (tesseract, PDF — pdftotext), если текста обращения нет; иначе анализ по имени файла")
+	priorityOrder = flag.Bool("priority-order", false, "обрабатывать тикеты по убыванию предварительного приоритета (сегмент + ключевые слова): срочные раньше попадают в CSV и БД")
+	mailWorklists = flag.Bool("mail-worklists", false, "отправить менеджерам их рабочие списки по почте (SMTP_HOST, колонка Email в managers.csv)")
+	cityMemoMode  = flag.Bool("city-memo", false, "тикеты одного города (страна, область, населённый пункт) — в офис первого геокодированного, без запроса на каждую улицу")
+	prefilterMode = flag.Bool("spam-prefilter", false, "признавать спамом до AI тикеты с рекламным отправителем или большинством (2+) рекламных ссылок; жалобы на мошенничество — всегда в AI")
+)
+
+// redirectStdout — -out -: stdout занят CSV, всё остальное (fmt.Printf и логи) — в stderr
+func redirectStdout() {
+	if *resultsOut == "-" && os.Stdout != os.Stderr {
+		resultsStdout = os.Stdout
+		os.Stdout = os.Stderr
+	}
+}
+
 func main() {
+	flag.Parse()
+
+	// Ctrl-C / SIGTERM → отмена контекста; повторный сигнал завершает процесс сразу
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		fmt.Println("\n🛑 Получен сигнал остановки — дописываем результаты...")
+		stop()
+	}()
+
+	redirectStdout() // -out - в командной строке: сообщения конфигурации — уже в stderr
+
 	// Загрузка .env
 	if err := godotenv.Load(); err != nil {
 		log.Println("⚠️ .env не найден, используются переменные окружения")
 	}
 
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("❌ GEMINI_API_KEY не установлен! Добавьте в .env или переменные окружения.")
+	// config.yaml — ниже флагов и окружения: заполняет только незаданное. Читается
+	// до всего, что зависит от флагов (-out -, -pprof, проверки совместимости)
+	cfgPath, cfgExplicit := defaultConfigPath, false
+	if *configPath != "" {
+		cfgPath, cfgExplicit = *configPath, true
+	}
+	cfg, err := loadFileConfig(cfgPath, cfgExplicit)
+	if err != nil {
+		log.Fatalf("❌ Конфигурация: %v", err)
+	}
+
+	if *stdinInput && *watchInterval > 0 {
+		log.Fatal("❌ -watch несовместим с -stdin: стандартный ввод читается один раз")
+	}
+	if *windowSize > 0 && *sortedOutput {
+		log.Fatal("❌ -sorted несовместим с -window: для сортировки нужны все результаты сразу")
+	}
+	if *windowSize > 0 && *geojsonPath != "" {
+		log.Printf("⚠️ -geojson и рабочие списки не строятся с -window: результаты окон не держатся в памяти")
+	}
+
+	if *pprofAddr != "" {
+		startPprofServer(*pprofAddr)
+	}
+
+	runID = newRunID()
+	fmt.Printf("🆔 Запуск %s\n", runID)
+
+	aiTimeout = getEnvDuration("AI_TIMEOUT", aiTimeout)
+	aiModel = getEnv("AI_MODEL", aiModel)
+	if n, err := strconv.Atoi(getEnv("AI_MAX_RETRIES", "")); err == nil && n > 0 {
+		aiMaxRetries = n
+	}
+	if n, err := strconv.Atoi(getEnv("AI_BREAKER_THRESHOLD", "")); err == nil && n > 0 {
+		aiBreaker.threshold = n
+	}
+	aiBreaker.cooldown = getEnvDuration("AI_BREAKER_COOLDOWN", aiBreaker.cooldown)
+	if v, err := strconv.ParseFloat(getEnv("AI_MIN_CONFIDENCE", ""), 64); err == nil && v >= 0 && v <= 1 {
+		aiMinConfidence = v
+	}
+	loadReviewConfig()
+	loadSummaryLimits()
+	loadAICost()
+	loadSpamDomains()
+	loadHQSplitWeights()
+	loadAlertConfig()
+	loadStoredPIIConfig()
+	loadOCRConfig()
+	defer alerts.Flush() // хвост пачки алертов — до выхода
+	if v, ok := os.LookupEnv("SENIOR_PRIORITY_TYPES"); ok {
+		seniorPriorityTypes = make(map[string]bool)
+		for _, typ := range strings.Split(v, ",") {
+			if typ = strings.TrimSpace(typ); typ != "" {
+				seniorPriorityTypes[typ] = true
+			}
+		}
+	}
+	configureNominatim()
+	if err := loadPriorityCategoryBounds(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if err := loadOutputEncoding(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if err := loadSLAConfig(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if err := loadResponseConfig(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if err := configureGeocoder(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if err := configureAnalyzer(); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	if km, err := strconv.ParseFloat(getEnv("MAX_OFFICE_DISTANCE_KM", ""), 64); err == nil && km > 0 {
+		MaxOfficeDistanceKm = km
+	}
+
+	keys := newAPIKeyPool(os.Getenv("GEMINI_API_KEYS"), os.Getenv("GEMINI_API_KEY"))
+	if !usesAPIKeys(analyzer) {
+		keys = newAPIKeyPool("", analyzer.Name()) // локальной модели ключ не нужен — один слот для Acquire
 	}
 
 	fmt.Println("🔥 FIRE — Freedom Intelligent Routing Engine v0.1.0")
 	fmt.Println("   ✅ Батч AI-анализ: 1 запрос на все тикеты")
 	fmt.Println("   ✅ AI-геолокация: LLM определяет офис (опечатки, транслитерация)")
-	fmt.Println("   ✅ Каскад фильтров: VIP → Смена данных → Язык → Round Robin")
+	fmt.Println("   ✅ Каскад фильтров: VIP → Должность по типу → Язык → Round Robin")
 	fmt.Println("   ✅ Спам: аналитика без назначения")
 	fmt.Println("   ✅ Иностранные клиенты: 50/50 Астана/Алматы")
 	fmt.Println("   ✅ CSV: колонки совместимы с app.py")
 	fmt.Println()
 
 	// Определяем путь к файлам
-	ticketsPath := findFile("data/tickets.csv", "tickets.csv")
-	officesPath := findFile("data/business_units.csv", "business_units.csv")
-	managersPath := findFile("data/managers.csv", "managers.csv")
+	// CSV в приоритете; .xlsx от бизнес-подразделений читается без ручной конвертации
+	paths := appPaths{
+		Tickets:        findFile("data/tickets.csv", "tickets.csv", "data/tickets.xlsx", "tickets.xlsx"),
+		Offices:        findFile("data/business_units.csv", "business_units.csv", "data/business_units.xlsx", "business_units.xlsx"),
+		Managers:       findFile("data/managers.csv", "managers.csv", "data/managers.xlsx", "managers.xlsx"),
+		CityAliases:    findFile("data/city_aliases.csv", "city_aliases.csv"),
+		PriorityMatrix: findFile("data/priority_matrix.csv", "priority_matrix.csv"),
+		SegmentRules:   findFile("data/segment_rules.csv", "segment_rules.csv"),
+		RoleRules:      findFile("data/role_rules.csv", "role_rules.csv"),
+		TicketColumns:  findFile("data/ticket_columns.csv", "ticket_columns.csv"),
+		Tenants:        findFile("data/tenants.csv", "tenants.csv"),
+		Overrides:      findFile("data/overrides.csv", "overrides.csv"),
+		Profanity:      findFile("data/profanity.txt", "profanity.txt"),
+	}
+	cfg.applyPaths(&paths)
+	if *stdinInput {
+		paths.Tickets = stdinPath
+	}
+
+	// Проверка конфигурации: с -check — только отчёт и выход
+	configOK := validateConfig(ctx, keys, paths, *serveAddr == "" && *kafkaBrokers == "")
+	if *checkOnly {
+		if !configOK {
+			os.Exit(1)
+		}
+		return
+	}
+	if !configOK {
+		log.Fatal("❌ Конфигурация неполная — см. отчёт выше")
+	}
 
 	// Загружаем данные
-	loadOffices(officesPath)
-	loadManagers(managersPath)
+	engine := NewEngine(geocoder, analyzer, nil)
+	if err := engine.LoadOffices(paths.Offices); err != nil {
+		log.Fatalf("❌ Офисы: %v", err)
+	}
+	if err := engine.LoadManagers(paths.Managers); err != nil {
+		log.Fatalf("❌ Менеджеры: %v", err)
+	}
+	engine.LoadCityAliases(paths.CityAliases)
+	if err := engine.LoadTenants(paths.Tenants, paths.CityAliases); err != nil {
+		log.Fatalf("❌ Тенанты: %v", err)
+	}
+	engine.LoadHQSplit(hqSplitPath)
+	loadPriorityMatrix(paths.PriorityMatrix)
+	loadSegmentRules(paths.SegmentRules)
+	loadRoleRules(paths.RoleRules)
+	loadOverrides(paths.Overrides)
+	loadTicketColumnAliases(paths.TicketColumns)
+	if *maskProfanity {
+		loadProfanityWords(paths.Profanity)
+	}
+
+	// Диагностика VIP-покрытия (по каждому тенанту)
+	var vipGaps []string
+	for _, te := range engine.allTenants() {
+		for _, office := range te.CheckVIPCoverage() {
+			vipGaps = append(vipGaps, tenantOffice(te.tenant, office))
+		}
+	}
+	if len(vipGaps) > 0 && *strictVIP {
+		log.Fatalf("❌ -strict-vip: нет менеджеров с навыком VIP в офисах: %s", strings.Join(vipGaps, ", "))
+	}
+
+	// PostgreSQL (опционально)
+	conn, err := initDB()
+	if err != nil {
+		log.Printf("⚠️ БД недоступна, работаем только с CSV: %v", err)
+	}
+	if conn != nil {
+		defer conn.Close()
+	}
+	engine.SetDB(conn)
+
+	// Режим HTTP API — пакетная обработка не запускается
+	if *serveAddr != "" {
+		err := runServer(ctx, engine, *serveAddr, keys)
+		engine.SaveHQSplit(hqSplitPath)
+		if err != nil {
+			log.Fatalf("❌ HTTP-сервер: %v", err)
+		}
+		return
+	}
+
+	// Режим Kafka — тикеты из топика вместо tickets.csv
+	if *kafkaBrokers != "" {
+		if *metricsAddr != "" {
+			startMetricsServer(*metricsAddr)
+		}
+		err := runKafkaConsumer(ctx, engine, *kafkaBrokers, *kafkaTopic, *kafkaOutTopic, *kafkaGroup, keys)
+		engine.SaveHQSplit(hqSplitPath)
+		if err != nil {
+			log.Fatalf("❌ Kafka: %v", err)
+		}
+		dbWg.Wait()
+		return
+	}
+
+	// -regeo — только тикеты прошлых запусков с неудачным геокодированием
+	if *regeoMode {
+		err := engine.runRegeo(ctx, paths.Tickets, *resultsOut)
+		engine.SaveHQSplit(hqSplitPath)
+		if err != nil {
+			log.Fatalf("❌ -regeo: %v", err)
+		}
+		dbWg.Wait()
+		return
+	}
 
-	// Диагностика VIP-покрытия
-	fmt.Println("\n--- VIP-покрытие по офисам ---")
-	for _, city := range knownOffices {
-		mgrs := ManagersMap[city]
+	if *metricsAddr != "" {
+		startMetricsServer(*metricsAddr)
+	}
+
+	// Основная обработка
+	err = engine.runBatch(ctx, paths.Tickets, keys)
+	engine.SaveHQSplit(hqSplitPath)
+	if err != nil {
+		log.Fatalf("❌ Обработка тикетов: %v", err)
+	}
+
+	// -watch: тот же инкрементальный проход по расписанию. Менеджеры, офисы
+	// и соединение с БД остаются загруженными; новые — GUID не из results.csv.
+	if *watchInterval > 0 {
+		fmt.Printf("\n👀 Наблюдение за %s: проверка каждые %v (Ctrl-C — выход)\n", paths.Tickets, *watchInterval)
+		for sleepCtx(ctx, *watchInterval) {
+			if err := engine.runBatch(ctx, paths.Tickets, keys); err != nil {
+				log.Printf("⚠️ Цикл наблюдения: %v — повтор через %v", err, *watchInterval)
+			}
+			engine.SaveHQSplit(hqSplitPath)
+		}
+	}
+}
+
+// runBatch — один пакетный проход: новые тикеты → results.csv (+ БД) и экспортёры
+func (e *Engine) runBatch(ctx context.Context, ticketsPath string, keys *apiKeyPool) error {
+	allResults, err := e.processAllTickets(ctx, ticketsPath, keys)
+	if err != nil {
+		return err
+	}
+	if len(allResults) == 0 { // в т.ч. -window: результаты окон не копятся
+		return nil
+	}
+	if keys.Len() > 1 {
+		fmt.Printf("🔑 429 по ключам Gemini: %s\n", keys.Report())
+	}
+
+	// Рабочие списки менеджеров (только если в этом запуске что-то обработано) —
+	// в свой подкаталог прохода, списки прошлых проходов не трогаются
+	if dir, err := worklistRunDir(worklistsDir, time.Now()); err != nil {
+		log.Printf("⚠️ Рабочие списки не записаны: %v", err)
+	} else if err := exportWorklists(dir, allResults); err != nil {
+		log.Printf("⚠️ Рабочие списки не записаны: %v", err)
+	} else if *mailWorklists {
+		e.sendWorklists(loadSMTPConfig(), dir, allResults)
+	}
+
+	// Экспорт GeoJSON для карты
+	if *geojsonPath != "" {
+		if err := exportGeoJSON(*geojsonPath, allResults); err != nil {
+			log.Printf("⚠️ GeoJSON не записан: %v", err)
+		}
+	}
+	return nil
+}
+
+// CheckVIPCoverage — печатает VIP-покрытие по офисам и запоминает офисы без
+// VIP-менеджеров в vipGapOffices. Возвращает эти офисы (для -strict-vip).
+func (e *Engine) CheckVIPCoverage() []string {
+	var gaps []string
+	if e.tenant == defaultTenant {
+		fmt.Println("\n--- VIP-покрытие по офисам ---")
+	} else {
+		fmt.Printf("\n--- VIP-покрытие по офисам (тенант %s) ---\n", e.tenant)
+	}
+	for _, city := range e.offices {
+		mgrs := e.managers[city]
 		vipCount := 0
 		for _, m := range mgrs {
-			for _, s := range m.Skills {
-				if strings.TrimSpace(s) == "VIP" {
-					vipCount++
-					break
-				}
+			if m.hasSkill("VIP") {
+				vipCount++
 			}
 		}
 		flag := "✅"
 		if vipCount == 0 {
 			flag = "⚠️  НЕТ VIP!"
+			e.vipGapOffices[city] = true
+			gaps = append(gaps, city)
 		}
 		fmt.Printf("  %s %-20s %d менеджеров, %d с VIP\n", flag, city, len(mgrs), vipCount)
 	}
 	fmt.Println()
-
-	// Основная обработка
-	processAllTickets(ticketsPath, apiKey)
-
+	return gaps
 }
 
 // findFile — ищет файл в нескольких вариантах пути
@@ -1432,3 +2868,10 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  МЕТРИКИ PROMETHEUS — те же разрезы, что и в printSummary
+// ═══════════════════════════════════════════════════════════
+
+var (
+	metricTicketsProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "fire_tickets_processed_total",
+		Help: "Тикетов прошло роутинг.",
+	})
+	metricAISource = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fire_ai_source_total",
+		Help: "Источник AI-анализа тикета (Gemini | Fallback).",
+	}, []string{"source"})
+	metricEscalations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "fire_escalations_total",
+		Help: "Тикетов эскалировано в ГО.",
+	})
+	metricGeoMethod = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fire_geocode_method_total",
+		Help: "Метод определения офиса (nominatim | alias | llm | too_far | foreign | unknown).",
+	}, []string{"method"})
+	metricNominatimRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fire_nominatim_requests_total",
+		Help: "HTTP-запросов к Nominatim по исходу (ok | empty | throttled | error).",
+	}, []string{"outcome"})
+	metricGeminiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fire_gemini_requests_total",
+		Help: "HTTP-запросов к Gemini по коду ответа.",
+	}, []string{"status"})
+	metricGeminiLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "fire_gemini_request_duration_seconds",
+		Help:    "Длительность запроса батча к Gemini.",
+		Buckets: []float64{1, 2.5, 5, 10, 20, 40, 80, 160},
+	})
+)
+
+// recordRoutingMetrics — учёт одного обработанного тикета
+func recordRoutingMetrics(r RoutingResult) {
+	metricTicketsProcessed.Inc()
+	metricAISource.WithLabelValues(r.Source).Inc()
+	metricGeoMethod.WithLabelValues(r.GeoMethod).Inc()
+	if r.IsEscalated {
+		metricEscalations.Inc()
+	}
+}
+
+// startMetricsServer — отдельный listener /metrics для пакетного запуска
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("⚠️ /metrics: %v", err)
+		}
+	}()
+	fmt.Printf("📈 Метрики Prometheus: http://%s/metrics\n", addr)
+}
+
+// startPprofServer — net/http/pprof на отдельном адресе (флаг -pprof), чтобы
+// снимать heap/CPU-профили во время больших запусков. По умолчанию выключен.
+func startPprofServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("⚠️ pprof: %v", err)
+		}
+	}()
+	fmt.Printf("🩺 pprof: http://%s/debug/pprof/\n", addr)
+}
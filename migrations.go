@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  МИГРАЦИИ СХЕМЫ — пронумерованные шаги, учёт в schema_migrations
+// ═══════════════════════════════════════════════════════════
+
+// migration — шаг схемы. Применённые версии записываются в schema_migrations
+// и повторно не выполняются; новые изменения — новым шагом в конце списка,
+// уже выпущенные шаги не меняются. Операторы — IF NOT EXISTS: БД, созданные
+// до schema_migrations, проходят шаги 1–2 без ошибок.
+type migration struct {
+	Version int
+	Name    string
+	Stmts   []string
+}
+
+var migrations = []migration{
+	{1, "таблицы tickets, ai_analysis, routing_results, review_queue", []string{
+		`CREATE TABLE IF NOT EXISTS tickets (
+				guid        TEXT PRIMARY KEY,
+				gender      TEXT,
+				birthdate   TEXT,
+				description TEXT,
+				attachment  TEXT,
+				segment     TEXT,
+				country     TEXT,
+				oblast      TEXT,
+				city        TEXT,
+				street      TEXT,
+				house       TEXT,
+				created_at  TIMESTAMPTZ DEFAULT NOW()
+			)`,
+		`CREATE TABLE IF NOT EXISTS ai_analysis (
+				guid           TEXT PRIMARY KEY REFERENCES tickets(guid) ON DELETE CASCADE,
+				type           TEXT,
+				sentiment      TEXT,
+				language       TEXT,
+				priority       INT,
+				summary        TEXT,
+				nearest_office TEXT,
+				geo_lat        DOUBLE PRECISION,
+				geo_lon        DOUBLE PRECISION,
+				geo_method     TEXT,
+				source         TEXT,
+				analyzed_at    TIMESTAMPTZ DEFAULT NOW()
+			)`,
+		`CREATE TABLE IF NOT EXISTS routing_results (
+				guid            TEXT PRIMARY KEY REFERENCES tickets(guid) ON DELETE CASCADE,
+				manager_name    TEXT,
+				manager_role    TEXT,
+				assigned_office TEXT,
+				routing_reason  TEXT,
+				is_escalated    BOOLEAN DEFAULT FALSE,
+				routed_at       TIMESTAMPTZ DEFAULT NOW()
+			)`,
+		`CREATE TABLE IF NOT EXISTS review_queue (
+				guid        TEXT PRIMARY KEY REFERENCES tickets(guid) ON DELETE CASCADE,
+				reason      TEXT,
+				status      TEXT NOT NULL DEFAULT 'pending',
+				reviewer    TEXT,
+				enqueued_at TIMESTAMPTZ DEFAULT NOW(),
+				reviewed_at TIMESTAMPTZ
+			)`,
+	}},
+	{2, "колонки, хэши содержимого, v_full_results, индексы", []string{
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS raw_ai TEXT`,
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS prompt_version TEXT`,
+		`ALTER TABLE routing_results ADD COLUMN IF NOT EXISTS distance_km DOUBLE PRECISION`,
+		`ALTER TABLE tickets ADD COLUMN IF NOT EXISTS age INT`,
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS link_domains TEXT`,
+		`ALTER TABLE routing_results ADD COLUMN IF NOT EXISTS alt_offices TEXT`,
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS derived_oblast TEXT`,
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS confidence DOUBLE PRECISION`,
+		`ALTER TABLE tickets ADD COLUMN IF NOT EXISTS content_hash TEXT`,
+		`ALTER TABLE tickets ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ DEFAULT NOW()`,
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ DEFAULT NOW()`,
+		`ALTER TABLE routing_results ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ DEFAULT NOW()`,
+		// Тенант: строки до появления колонки принадлежат default
+		`ALTER TABLE tickets ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE routing_results ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE review_queue ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS type_source TEXT`,
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS geo_precision TEXT`,
+		// Хэш строк, записанных до появления content_hash: та же формула, что в ticketContentHash
+		`UPDATE tickets SET content_hash = md5(concat_ws(E'\x1f', gender, birthdate, description,
+				attachment, segment, country, oblast, city, street, house))
+			WHERE content_hash IS NULL`,
+		// Индексы под фильтры GET /results и дашборда (офис, приоритет, тип, эскалация)
+		`CREATE INDEX IF NOT EXISTS idx_routing_results_assigned_office ON routing_results (assigned_office)`,
+		`CREATE INDEX IF NOT EXISTS idx_routing_results_is_escalated ON routing_results (is_escalated)`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_analysis_priority ON ai_analysis (priority)`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_analysis_type ON ai_analysis (type)`,
+		// Приоритет 1–10. ADD CONSTRAINT не знает IF NOT EXISTS; NOT VALID — старые
+		// строки не проверяются, ограничение действует для новых и обновлённых
+		`DO $$ BEGIN
+				IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'ai_analysis_priority_range') THEN
+					ALTER TABLE ai_analysis ADD CONSTRAINT ai_analysis_priority_range
+						CHECK (priority BETWEEN 1 AND 10) NOT VALID;
+				END IF;
+			END $$`,
+		`CREATE OR REPLACE VIEW v_full_results AS
+			SELECT t.guid, t.segment, t.city,
+			       a.type, a.sentiment, a.language, a.priority, a.summary,
+			       a.geo_lat, a.geo_lon, a.geo_method, a.source,
+			       r.manager_name, r.manager_role, r.assigned_office,
+			       r.routing_reason, r.is_escalated, r.routed_at, r.distance_km, r.alt_offices,
+			       COALESCE(NULLIF(t.oblast, ''), a.derived_oblast) AS oblast,
+			       t.tenant_id, a.type_source, a.geo_precision
+			FROM tickets t
+			JOIN ai_analysis a     ON a.guid = t.guid
+			JOIN routing_results r ON r.guid = t.guid`,
+	}},
+	{3, "tickets_raw — исходный текст при MASK_STORED_PII + RETAIN_RAW", []string{
+		`CREATE TABLE IF NOT EXISTS tickets_raw (
+			guid        TEXT PRIMARY KEY REFERENCES tickets(guid) ON DELETE CASCADE,
+			description TEXT NOT NULL,
+			tenant_id   TEXT NOT NULL DEFAULT 'default',
+			saved_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		// Исходные ПДн: читать может только владелец схемы, не роли приложения/дашбордов
+		`REVOKE ALL ON tickets_raw FROM PUBLIC`,
+	}},
+	{4, "ai_analysis.priority_category в v_full_results", []string{
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS priority_category TEXT`,
+		`CREATE OR REPLACE VIEW v_full_results AS
+			SELECT t.guid, t.segment, t.city,
+			       a.type, a.sentiment, a.language, a.priority, a.summary,
+			       a.geo_lat, a.geo_lon, a.geo_method, a.source,
+			       r.manager_name, r.manager_role, r.assigned_office,
+			       r.routing_reason, r.is_escalated, r.routed_at, r.distance_km, r.alt_offices,
+			       COALESCE(NULLIF(t.oblast, ''), a.derived_oblast) AS oblast,
+			       t.tenant_id, a.type_source, a.geo_precision, a.priority_category
+			FROM tickets t
+			JOIN ai_analysis a     ON a.guid = t.guid
+			JOIN routing_results r ON r.guid = t.guid`,
+	}},
+	{5, "ai_analysis.spam_domain — спам по доменам без AI", []string{
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS spam_domain TEXT`,
+		`CREATE OR REPLACE VIEW v_full_results AS
+			SELECT t.guid, t.segment, t.city,
+			       a.type, a.sentiment, a.language, a.priority, a.summary,
+			       a.geo_lat, a.geo_lon, a.geo_method, a.source,
+			       r.manager_name, r.manager_role, r.assigned_office,
+			       r.routing_reason, r.is_escalated, r.routed_at, r.distance_km, r.alt_offices,
+			       COALESCE(NULLIF(t.oblast, ''), a.derived_oblast) AS oblast,
+			       t.tenant_id, a.type_source, a.geo_precision, a.priority_category, a.spam_domain
+			FROM tickets t
+			JOIN ai_analysis a     ON a.guid = t.guid
+			JOIN routing_results r ON r.guid = t.guid`,
+	}},
+	{6, "routing_results.due_at — срок ответа по SLA", []string{
+		`ALTER TABLE routing_results ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ`,
+		`CREATE INDEX IF NOT EXISTS idx_routing_results_due_at ON routing_results (due_at)`,
+		`CREATE OR REPLACE VIEW v_full_results AS
+			SELECT t.guid, t.segment, t.city,
+			       a.type, a.sentiment, a.language, a.priority, a.summary,
+			       a.geo_lat, a.geo_lon, a.geo_method, a.source,
+			       r.manager_name, r.manager_role, r.assigned_office,
+			       r.routing_reason, r.is_escalated, r.routed_at, r.distance_km, r.alt_offices,
+			       COALESCE(NULLIF(t.oblast, ''), a.derived_oblast) AS oblast,
+			       t.tenant_id, a.type_source, a.geo_precision, a.priority_category, a.spam_domain,
+			       r.due_at
+			FROM tickets t
+			JOIN ai_analysis a     ON a.guid = t.guid
+			JOIN routing_results r ON r.guid = t.guid`,
+	}},
+	{7, "run_stats — итоги каждого запуска", []string{
+		`CREATE TABLE IF NOT EXISTS run_stats (
+			id           BIGSERIAL PRIMARY KEY,
+			run_id       TEXT NOT NULL,
+			created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			total        INT NOT NULL,
+			spam         INT NOT NULL,
+			escalated    INT NOT NULL,
+			no_manager   INT NOT NULL,
+			by_type      JSONB NOT NULL DEFAULT '{}',
+			by_sentiment JSONB NOT NULL DEFAULT '{}',
+			by_office    JSONB NOT NULL DEFAULT '{}'
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_run_stats_run_id ON run_stats (run_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_run_stats_created_at ON run_stats (created_at)`,
+	}},
+	{8, "run_id в tickets, ai_analysis, routing_results", []string{
+		`ALTER TABLE tickets ADD COLUMN IF NOT EXISTS run_id TEXT`,
+		`ALTER TABLE ai_analysis ADD COLUMN IF NOT EXISTS run_id TEXT`,
+		`ALTER TABLE routing_results ADD COLUMN IF NOT EXISTS run_id TEXT`,
+		`CREATE INDEX IF NOT EXISTS idx_tickets_run_id ON tickets (run_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_analysis_run_id ON ai_analysis (run_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_routing_results_run_id ON routing_results (run_id)`,
+		`CREATE OR REPLACE VIEW v_full_results AS
+			SELECT t.guid, t.segment, t.city,
+			       a.type, a.sentiment, a.language, a.priority, a.summary,
+			       a.geo_lat, a.geo_lon, a.geo_method, a.source,
+			       r.manager_name, r.manager_role, r.assigned_office,
+			       r.routing_reason, r.is_escalated, r.routed_at, r.distance_km, r.alt_offices,
+			       COALESCE(NULLIF(t.oblast, ''), a.derived_oblast) AS oblast,
+			       t.tenant_id, a.type_source, a.geo_precision, a.priority_category, a.spam_domain,
+			       r.due_at, r.run_id
+			FROM tickets t
+			JOIN ai_analysis a     ON a.guid = t.guid
+			JOIN routing_results r ON r.guid = t.guid`,
+	}},
+	{9, "run_stats.by_priority — гистограмма приоритетов", []string{
+		`ALTER TABLE run_stats ADD COLUMN IF NOT EXISTS by_priority JSONB NOT NULL DEFAULT '{}'`,
+	}},
+	{10, "ключ (tenant_id, guid) — один GUID в разных тенантах", []string{
+		// Дочерние строки берут тенант тикета: до этого шага upsert переписывал его везде
+		`UPDATE ai_analysis a SET tenant_id = t.tenant_id FROM tickets t
+			WHERE a.guid = t.guid AND a.tenant_id <> t.tenant_id`,
+		`UPDATE routing_results r SET tenant_id = t.tenant_id FROM tickets t
+			WHERE r.guid = t.guid AND r.tenant_id <> t.tenant_id`,
+		`UPDATE review_queue q SET tenant_id = t.tenant_id FROM tickets t
+			WHERE q.guid = t.guid AND q.tenant_id <> t.tenant_id`,
+		`UPDATE tickets_raw raw SET tenant_id = t.tenant_id FROM tickets t
+			WHERE raw.guid = t.guid AND raw.tenant_id <> t.tenant_id`,
+		// Сначала внешние ключи на tickets(guid), затем первичные ключи по guid
+		`ALTER TABLE ai_analysis DROP CONSTRAINT IF EXISTS ai_analysis_guid_fkey`,
+		`ALTER TABLE routing_results DROP CONSTRAINT IF EXISTS routing_results_guid_fkey`,
+		`ALTER TABLE review_queue DROP CONSTRAINT IF EXISTS review_queue_guid_fkey`,
+		`ALTER TABLE tickets_raw DROP CONSTRAINT IF EXISTS tickets_raw_guid_fkey`,
+		`ALTER TABLE ai_analysis DROP CONSTRAINT IF EXISTS ai_analysis_pkey`,
+		`ALTER TABLE routing_results DROP CONSTRAINT IF EXISTS routing_results_pkey`,
+		`ALTER TABLE review_queue DROP CONSTRAINT IF EXISTS review_queue_pkey`,
+		`ALTER TABLE tickets_raw DROP CONSTRAINT IF EXISTS tickets_raw_pkey`,
+		`ALTER TABLE tickets DROP CONSTRAINT IF EXISTS tickets_pkey`,
+		`ALTER TABLE tickets ADD PRIMARY KEY (tenant_id, guid)`,
+		`ALTER TABLE ai_analysis ADD PRIMARY KEY (tenant_id, guid)`,
+		`ALTER TABLE routing_results ADD PRIMARY KEY (tenant_id, guid)`,
+		`ALTER TABLE review_queue ADD PRIMARY KEY (tenant_id, guid)`,
+		`ALTER TABLE tickets_raw ADD PRIMARY KEY (tenant_id, guid)`,
+		`ALTER TABLE ai_analysis ADD CONSTRAINT ai_analysis_ticket_fkey FOREIGN KEY (tenant_id, guid)
+			REFERENCES tickets (tenant_id, guid) ON DELETE CASCADE`,
+		`ALTER TABLE routing_results ADD CONSTRAINT routing_results_ticket_fkey FOREIGN KEY (tenant_id, guid)
+			REFERENCES tickets (tenant_id, guid) ON DELETE CASCADE`,
+		`ALTER TABLE review_queue ADD CONSTRAINT review_queue_ticket_fkey FOREIGN KEY (tenant_id, guid)
+			REFERENCES tickets (tenant_id, guid) ON DELETE CASCADE`,
+		`ALTER TABLE tickets_raw ADD CONSTRAINT tickets_raw_ticket_fkey FOREIGN KEY (tenant_id, guid)
+			REFERENCES tickets (tenant_id, guid) ON DELETE CASCADE`,
+		`CREATE OR REPLACE VIEW v_full_results AS
+			SELECT t.guid, t.segment, t.city,
+			       a.type, a.sentiment, a.language, a.priority, a.summary,
+			       a.geo_lat, a.geo_lon, a.geo_method, a.source,
+			       r.manager_name, r.manager_role, r.assigned_office,
+			       r.routing_reason, r.is_escalated, r.routed_at, r.distance_km, r.alt_offices,
+			       COALESCE(NULLIF(t.oblast, ''), a.derived_oblast) AS oblast,
+			       t.tenant_id, a.type_source, a.geo_precision, a.priority_category, a.spam_domain,
+			       r.due_at, r.run_id
+			FROM tickets t
+			JOIN ai_analysis a     ON a.tenant_id = t.tenant_id AND a.guid = t.guid
+			JOIN routing_results r ON r.tenant_id = t.tenant_id AND r.guid = t.guid`,
+	}},
+}
+
+// migrationLockID — pg_advisory_xact_lock: несколько процессов, стартующих
+// одновременно (пакет, -serve, Kafka), не применяют один шаг дважды
+const migrationLockID = 0x46495245 // "FIRE"
+
+// migrateSchema — применяет недостающие шаги по порядку, каждый в своей
+// транзакции вместе с записью в schema_migrations; печатает версию схемы
+func migrateSchema(ctx context.Context, conn *sql.DB) error {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return fmt.Errorf("schema_migrations: %v", err)
+	}
+	for _, m := range migrations {
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return fmt.Errorf("миграция %d (%s): %v", m.Version, m.Name, err)
+		}
+	}
+	version, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🗄  Схема БД: версия %d\n", version)
+	return nil
+}
+
+// applyMigration — шаг m, если он ещё не записан в schema_migrations
+func applyMigration(ctx context.Context, conn *sql.DB, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op после Commit
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+	var applied bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`,
+		m.Version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+	for _, s := range m.Stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+		m.Version, m.Name); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Printf("🗄  Применена миграция %d: %s\n", m.Version, m.Name)
+	return nil
+}
+
+// schemaVersion — последняя применённая миграция (0 — ни одной)
+func schemaVersion(ctx context.Context, conn *sql.DB) (int, error) {
+	var v int
+	err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+	return v, err
+}
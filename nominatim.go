@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  АДАПТИВНЫЙ RATE LIMIT NOMINATIM — 429/503 замедляют, успехи ускоряют
+// ═══════════════════════════════════════════════════════════
+
+const (
+	nominatimSlowdownCap  = 8  // интервал растёт максимум до base × 8
+	nominatimSpeedupAfter = 20 // столько успехов подряд — интервал уменьшается вдвое
+	nominatimMaxAttempts  = 3  // попыток на адрес при 429/503
+)
+
+// adaptiveLimiter — общий для всех горутин интервал между запросами к Nominatim.
+// 429/503 удваивают интервал и сдвигают следующий слот; серия успехов
+// возвращает его к базовому (NOMINATIM_RATE).
+type adaptiveLimiter struct {
+	mu        sync.Mutex
+	base      time.Duration
+	cur       time.Duration
+	next      time.Time
+	successes int
+}
+
+func newAdaptiveLimiter(base time.Duration) *adaptiveLimiter {
+	return &adaptiveLimiter{base: base, cur: base}
+}
+
+// nominatimLimiter — публичный Nominatim разрешает 1 req/sec (NOMINATIM_RATE)
+var nominatimLimiter = newAdaptiveLimiter(time.Second)
+
+// SetBase — новый базовый интервал (из окружения, до начала запросов)
+func (l *adaptiveLimiter) SetBase(base time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.base, l.cur = base, base
+}
+
+// Wait — занимает ближайший свободный слот и ждёт его; false — ctx отменён
+func (l *adaptiveLimiter) Wait(ctx context.Context) bool {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.cur)
+	l.mu.Unlock()
+	return sleepCtx(ctx, time.Until(slot))
+}
+
+// Throttled — сервер ответил 429/503: интервал ×2 (до cap), следующий слот — не раньше чем через него
+func (l *adaptiveLimiter) Throttled() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.successes = 0
+	if l.cur < l.base*nominatimSlowdownCap {
+		l.cur *= 2
+		fmt.Printf("🐢 Nominatim перегружен → интервал между запросами %v\n", l.cur)
+	}
+	if pause := time.Now().Add(l.cur); l.next.Before(pause) {
+		l.next = pause
+	}
+}
+
+// Success — после серии успехов интервал уменьшается вдвое, но не ниже базового
+func (l *adaptiveLimiter) Success() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.successes++
+	if l.cur > l.base && l.successes >= nominatimSpeedupAfter {
+		l.cur /= 2
+		if l.cur < l.base {
+			l.cur = l.base
+		}
+		l.successes = 0
+		fmt.Printf("🐇 Nominatim стабилен → интервал %v\n", l.cur)
+	}
+}
+
+// ═══════════════════════════════════════════════════════════
+//  АДРЕС NOMINATIM — публичный или свой (NOMINATIM_URL)
+// ═══════════════════════════════════════════════════════════
+
+const (
+	publicNominatimURL = "https://nominatim.openstreetmap.org"
+	// privateNominatimRate — интервал по умолчанию для своего инстанса:
+	// usage policy публичного сервера на него не распространяется
+	privateNominatimRate = 100 * time.Millisecond
+)
+
+var nominatimBaseURL = publicNominatimURL
+
+// configureNominatim — NOMINATIM_URL и NOMINATIM_RATE. Для своего инстанса без
+// явного NOMINATIM_RATE лимитер ослабляется до privateNominatimRate.
+func configureNominatim() {
+	base := strings.TrimRight(getEnv("NOMINATIM_URL", publicNominatimURL), "/")
+	nominatimBaseURL = base
+	rate := nominatimLimiter.base
+	if base != publicNominatimURL {
+		rate = privateNominatimRate
+	}
+	nominatimLimiter.SetBase(getEnvDuration("NOMINATIM_RATE", rate))
+	if base != publicNominatimURL {
+		fmt.Printf("🌐 Nominatim: %s (интервал %v)\n", base, nominatimLimiter.base)
+	}
+}
+
+// nominatimSearchURL — /search с ограничением по Казахстану
+func nominatimSearchURL(query string) string {
+	q := url.Values{}
+	q.Set("q", query)
+	q.Set("format", "json")
+	q.Set("limit", "1")
+	q.Set("countrycodes", "kz")
+	q.Set("addressdetails", "1") // address.state — область для обогащения
+	q.Set("accept-language", "ru")
+	return nominatimBaseURL + "/search?" + q.Encode()
+}
+
+// nominatimPrecision — точность совпадения по ответу Nominatim: place_rank
+// (26–27 улица, 28–30 дом/здание, 13–25 населённый пункт и его части, 5–12
+// область/район); без place_rank — по class/type. "" — страна или неизвестно.
+func nominatimPrecision(class, typ string, placeRank int) string {
+	switch {
+	case placeRank >= 28:
+		return geoPrecisionHouse
+	case placeRank >= 26:
+		return geoPrecisionStreet
+	case placeRank >= 13:
+		return geoPrecisionCity
+	case placeRank >= 5:
+		return geoPrecisionRegion
+	case placeRank > 0:
+		return ""
+	}
+	switch {
+	case class == "building" || typ == "house" || typ == "building":
+		return geoPrecisionHouse
+	case class == "highway":
+		return geoPrecisionStreet
+	case class == "place":
+		return geoPrecisionCity
+	case class == "boundary":
+		return geoPrecisionRegion
+	}
+	return ""
+}
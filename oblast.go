@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ОБЛАСТЬ → ОФИС — центр области, если город не геокодируется
+// ═══════════════════════════════════════════════════════════
+
+// oblastRule — область (и города республиканского значения) с примерным
+// центром и офисом по умолчанию. Stems — начала слов, по которым область
+// узнаётся в поле «Область» («Жамбылская обл.», «ВКО / область Абай», «Mangystau obl.»).
+// Office "" или офиса нет у движка — ближайший офис к центру.
+type oblastRule struct {
+	Name   string
+	Stems  []string
+	Center GeoPoint
+	Office string
+}
+
+// oblastRules — проверяются по порядку; первое совпадение выигрывает
+var oblastRules = []oblastRule{
+	{"г. Астана", []string{"астана", "нур-султан", "astana"}, GeoPoint{51.1801, 71.4598}, "Астана"},
+	{"г. Алматы", []string{"алматы", "almaty"}, GeoPoint{43.2220, 76.8512}, "Алматы"},
+	{"г. Шымкент", []string{"шымкент", "shymkent"}, GeoPoint{42.3417, 69.5901}, "Шымкент"},
+	{"Абайская", []string{"абай", "семейск", "семипалат"}, GeoPoint{48.6, 79.6}, "Семей"},
+	{"Акмолинская", []string{"акмол", "акмола", "akmola"}, GeoPoint{51.9, 69.6}, "Кокшетау"},
+	{"Актюбинская", []string{"актюб", "актобе", "aktobe"}, GeoPoint{48.8, 58.5}, "Актобе"},
+	{"Алматинская", []string{"алматин"}, GeoPoint{44.5, 77.5}, "Алматы"},
+	{"Атырауская", []string{"атырау", "atyrau"}, GeoPoint{47.4, 52.0}, "Атырау"},
+	{"Восточно-Казахстанская", []string{"восточно-казах", "вко"}, GeoPoint{49.4, 83.6}, "Усть-Каменогорск"},
+	{"Жамбылская", []string{"жамбыл", "zhambyl"}, GeoPoint{44.2, 72.1}, "Тараз"},
+	{"Жетысуская", []string{"жетісу", "жетысу"}, GeoPoint{45.0, 79.0}, ""},
+	{"Западно-Казахстанская", []string{"западно-казах", "зко"}, GeoPoint{50.4, 51.6}, "Уральск"},
+	{"Карагандинская", []string{"караганд", "karaganda"}, GeoPoint{48.4, 73.6}, ""},
+	{"Костанайская", []string{"костанай", "кустанай", "kostanay"}, GeoPoint{51.6, 63.6}, "Костанай"},
+	{"Кызылординская", []string{"кызылорд", "қызылорд", "kyzylorda"}, GeoPoint{45.0, 64.0}, "Кызылорда"},
+	{"Мангистауская", []string{"мангист", "маңғыст", "mangystau", "mangistau"}, GeoPoint{44.0, 53.5}, "Актау"},
+	{"Павлодарская", []string{"павлодар", "pavlodar"}, GeoPoint{52.2, 76.6}, "Павлодар"},
+	{"Северо-Казахстанская", []string{"северо-казах", "ско"}, GeoPoint{54.2, 69.6}, "Петропавловск"},
+	{"Туркестанская", []string{"туркестан", "юко", "южно-казах"}, GeoPoint{43.4, 68.6}, "Шымкент"},
+	{"Улытауская", []string{"улытау", "ұлытау"}, GeoPoint{47.9, 67.2}, ""},
+}
+
+// matchOblast — правило для значения поля «Область»; ok=false — не распознано.
+// Значение может перечислять несколько вариантов через «/» — берётся первый узнанный.
+func matchOblast(oblast string) (oblastRule, bool) {
+	for _, part := range strings.Split(strings.ToLower(oblast), "/") {
+		words := strings.FieldsFunc(part, func(r rune) bool {
+			return !unicode.IsLetter(r) && r != '-'
+		})
+		for _, w := range words {
+			for _, rule := range oblastRules {
+				for _, stem := range rule.Stems {
+					if strings.HasPrefix(w, stem) {
+						return rule, true
+					}
+				}
+			}
+		}
+	}
+	return oblastRule{}, false
+}
+
+// resolveOblastOffice — офис по области тикета: офис области, если он есть у
+// движка, иначе ближайший к центру области. ok=false — область не распознана.
+func (e *Engine) resolveOblastOffice(oblast string) (office string, center GeoPoint, ok bool) {
+	rule, ok := matchOblast(oblast)
+	if !ok {
+		return "", GeoPoint{}, false
+	}
+	if rule.Office != "" {
+		office = e.normalizeOfficeName(rule.Office)
+	}
+	if office == "" {
+		office, _ = e.findNearestOfficeByCoords(rule.Center.Lat, rule.Center.Lon)
+	}
+	if office == "" {
+		return "", GeoPoint{}, false
+	}
+	fmt.Printf("   🗺  Центр области '%s' (%s) → офис '%s'\n", oblast, rule.Name, office)
+	return office, rule.Center, true
+}
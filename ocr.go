@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  OCR ВЛОЖЕНИЙ — -ocr: текст из картинки/PDF, если текста обращения нет
+// ═══════════════════════════════════════════════════════════
+
+// Настройки OCR (OCR_ATTACHMENTS_DIR, OCR_LANGS, OCR_TIMEOUT, OCR_URL_HOSTS,
+// OCR_REMOTE_INPUT). Без OCR_URL_HOSTS вложения по URL не скачиваются; тикеты
+// из -serve и Kafka распознаются только при OCR_REMOTE_INPUT=true — имя
+// вложения в них задаёт внешний клиент.
+var (
+	ocrAttachmentsDir = "data/attachments"
+	ocrLangs          = "rus+kaz+eng"
+	ocrTimeout        = 30 * time.Second
+	ocrURLHosts       = map[string]bool{}
+	ocrRemoteInput    = false
+)
+
+const (
+	// ocrMaxText — столько символов распознанного текста уходит в промпт
+	ocrMaxText = 2000
+	// ocrMaxDownload — предел размера вложения по URL
+	ocrMaxDownload = 20 << 20
+)
+
+// ocrImageExts — расширения, которые распознаются tesseract
+var ocrImageExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".tif": true, ".tiff": true,
+	".bmp": true, ".gif": true, ".webp": true,
+}
+
+// loadOCRConfig — настройки OCR из окружения
+func loadOCRConfig() {
+	ocrAttachmentsDir = getEnv("OCR_ATTACHMENTS_DIR", ocrAttachmentsDir)
+	ocrLangs = getEnv("OCR_LANGS", ocrLangs)
+	ocrTimeout = getEnvDuration("OCR_TIMEOUT", ocrTimeout)
+	for _, h := range strings.Split(getEnv("OCR_URL_HOSTS", ""), ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			ocrURLHosts[h] = true
+		}
+	}
+	if v, err := strconv.ParseBool(getEnv("OCR_REMOTE_INPUT", "")); err == nil {
+		ocrRemoteInput = v
+	}
+}
+
+// ocrHostAllowed — хост URL вложения из OCR_URL_HOSTS (или его поддомен)
+func ocrHostAllowed(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for h := range ocrURLHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRemoteOCR — applyOCR для тикетов -serve и Kafka: только при OCR_REMOTE_INPUT
+func applyRemoteOCR(ctx context.Context, tickets []TicketInput) {
+	if ocrRemoteInput {
+		applyOCR(ctx, tickets)
+	}
+}
+
+// applyOCR — для тикетов без текста с вложением заполняет OCRText. Одно
+// вложение распознаётся один раз; ошибка OCR только печатается — такой тикет
+// анализируется по имени файла, как без -ocr.
+func applyOCR(ctx context.Context, tickets []TicketInput) {
+	if !*ocrMode {
+		return
+	}
+	cache := make(map[string]string)
+	recognized, failed := 0, 0
+	for i := range tickets {
+		t := &tickets[i]
+		if strings.TrimSpace(t.Text) != "" || strings.TrimSpace(t.Attachment) == "" {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		text, ok := cache[t.Attachment]
+		if !ok {
+			var err error
+			if text, err = ocrAttachment(ctx, t.Attachment); err != nil {
+				fmt.Printf("   ⚠️ OCR %s (%s): %v → анализ по имени файла\n", t.GUID[:min(8, len(t.GUID))], t.Attachment, err)
+			}
+			cache[t.Attachment] = text
+		}
+		if text == "" {
+			failed++
+			continue
+		}
+		t.OCRText = text
+		recognized++
+	}
+	if recognized+failed > 0 {
+		fmt.Printf("🔎 OCR вложений: распознано %d, без текста %d\n", recognized, failed)
+	}
+}
+
+// ocrAttachment — текст вложения: имя файла в OCR_ATTACHMENTS_DIR (каталоги из
+// пути тикета отбрасываются) или http(s)-URL с хоста из OCR_URL_HOSTS.
+// Картинки — tesseract, PDF — текстовый слой pdftotext.
+func ocrAttachment(ctx context.Context, attachment string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, ocrTimeout)
+	defer cancel()
+
+	path := strings.TrimSpace(attachment)
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		tmp, err := downloadAttachment(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(tmp)
+		path = tmp
+	} else {
+		name := filepath.Base(filepath.Clean("/" + path))
+		if name == "/" || name == "." {
+			return "", fmt.Errorf("пустое имя файла")
+		}
+		path = filepath.Join(ocrAttachmentsDir, name)
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			return "", fmt.Errorf("файла %s нет в %s", name, ocrAttachmentsDir)
+		}
+	}
+
+	var cmd *exec.Cmd
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ocrImageExts[ext]:
+		cmd = exec.CommandContext(ctx, "tesseract", path, "stdout", "-l", ocrLangs)
+	case ext == ".pdf":
+		cmd = exec.CommandContext(ctx, "pdftotext", "-layout", path, "-")
+	default:
+		return "", fmt.Errorf("формат %q не распознаётся", ext)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %v (%s)", filepath.Base(cmd.Path), err, firstLine(msg))
+		}
+		return "", fmt.Errorf("%s: %v", filepath.Base(cmd.Path), err)
+	}
+	text := strings.Join(strings.Fields(string(out)), " ")
+	if r := []rune(text); len(r) > ocrMaxText {
+		text = string(r[:ocrMaxText]) + "..."
+	}
+	return text, nil
+}
+
+// ocrHTTPClient — загрузка вложений: редирект тоже только на хост из OCR_URL_HOSTS
+var ocrHTTPClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return fmt.Errorf("слишком много редиректов")
+		}
+		if !ocrHostAllowed(req.URL) {
+			return fmt.Errorf("редирект на хост %s не разрешён (OCR_URL_HOSTS)", req.URL.Hostname())
+		}
+		return nil
+	},
+}
+
+// downloadAttachment — вложение по URL во временный файл с тем же расширением
+func downloadAttachment(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(ocrURLHosts) == 0 {
+		return "", fmt.Errorf("загрузка по URL выключена (OCR_URL_HOSTS не задан)")
+	}
+	if !ocrHostAllowed(req.URL) {
+		return "", fmt.Errorf("хост %s не разрешён (OCR_URL_HOSTS)", req.URL.Hostname())
+	}
+	resp, err := ocrHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("загрузка: HTTP %d", resp.StatusCode)
+	}
+	ext := filepath.Ext(strings.SplitN(url, "?", 2)[0])
+	f, err := os.CreateTemp("", "fire-ocr-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(resp.Body, ocrMaxDownload+1))
+	if err == nil && n > ocrMaxDownload {
+		err = fmt.Errorf("вложение больше %d МБ", ocrMaxDownload>>20)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// firstLine — первая строка сообщения (stderr внешней утилиты)
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  РУЧНЫЕ НАЗНАЧЕНИЯ — data/overrides.csv (GUID → менеджер / офис)
+// ═══════════════════════════════════════════════════════════
+
+// manualAssignmentReason — RoutingReason тикета с ручным назначением
+const manualAssignmentReason = "ручное назначение"
+
+// assignmentOverride — строка overrides.csv. Менеджер без офиса ищется во
+// всех офисах тенанта; офис без менеджера — менеджер выбирается каскадом
+// фильтров внутри этого офиса.
+type assignmentOverride struct {
+	Manager string
+	Office  string
+}
+
+// assignmentOverrides — tenantKey → ручное назначение (loadOverrides)
+var assignmentOverrides = map[string]assignmentOverride{}
+
+// loadOverrides — CSV (GUID,Менеджер,Офис[,Тенант]); без тенанта — default.
+// Файл необязателен; существование менеджера и офиса проверяется при роутинге —
+// у тенантов разные справочники.
+func loadOverrides(fp string) {
+	file, err := os.Open(fp)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	records, err := readCSV(file)
+	if err != nil {
+		fmt.Printf("⚠️ Ошибка чтения %s: %v\n", fp, err)
+		return
+	}
+	for i, row := range records {
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+		guid := strings.TrimSpace(row[0])
+		ov := assignmentOverride{Manager: strings.TrimSpace(row[1])}
+		if len(row) > 2 {
+			ov.Office = strings.TrimSpace(row[2])
+		}
+		tenant := ""
+		if len(row) > 3 {
+			tenant = row[3]
+		}
+		key := tenantKey(tenant, guid)
+		if guid == "" || ov == (assignmentOverride{}) {
+			fmt.Printf("⚠️ %s: пропущена строка %d (%v) — нужны GUID и менеджер или офис\n", fp, i+1, row)
+			continue
+		}
+		if _, dup := assignmentOverrides[key]; dup {
+			fmt.Printf("⚠️ %s: GUID %s (тенант %s) указан повторно — действует строка %d\n", fp, guid, tenantID(tenant), i+1)
+		}
+		assignmentOverrides[key] = ov
+	}
+	if len(assignmentOverrides) > 0 {
+		fmt.Printf("✅ Ручных назначений из %s: %d\n", fp, len(assignmentOverrides))
+	}
+}
+
+// applyOverride — ручное назначение тикета вместо routeTicket (под e.mu).
+// ok=false — назначения нет или оно не проходит проверку (предупреждение
+// печатается, тикет роутится как обычно).
+func (e *Engine) applyOverride(t TicketInput, ai AIResult) (*Manager, string, bool) {
+	ov, found := assignmentOverrides[tenantKey(t.Tenant, t.GUID)]
+	if !found {
+		return nil, "", false
+	}
+	office := ""
+	if ov.Office != "" {
+		if office = e.normalizeOfficeName(ov.Office); office == "" {
+			fmt.Printf("   ⚠️ Ручное назначение: офиса '%s' нет среди офисов тенанта %s → обычный роутинг\n", ov.Office, e.tenant)
+			return nil, "", false
+		}
+	}
+
+	if ov.Manager == "" {
+		winner := e.findBestManager(e.managers[office], t.Segment, ai, office)
+		if winner == nil {
+			fmt.Printf("   ⚠️ Ручное назначение: в офисе '%s' нет подходящего менеджера → обычный роутинг\n", office)
+			return nil, "", false
+		}
+		fmt.Printf("   ✋ Ручное назначение: офис '%s' → %s\n", office, winner.Name)
+		return winner, office, true
+	}
+
+	if m, o := e.lookupManager(ov.Manager, office); m != nil {
+		m.Workload++
+		fmt.Printf("   ✋ Ручное назначение: %s → офис '%s'\n", m.Name, o)
+		return m, o, true
+	}
+	where := "тенанта " + e.tenant
+	if office != "" {
+		where = "офиса '" + office + "'"
+	}
+	fmt.Printf("   ⚠️ Ручное назначение: менеджера '%s' нет среди менеджеров %s → обычный роутинг\n", ov.Manager, where)
+	return nil, "", false
+}
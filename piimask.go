@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ПДн В БД — MASK_STORED_PII: ИИН, счета и карты в tickets.description
+// ═══════════════════════════════════════════════════════════
+
+// MASK_STORED_PII — маскировать ИИН/IBAN/номера карт в tickets.description;
+// RETAIN_RAW — исходный текст в tickets_raw (доступ только владельцу схемы).
+// По умолчанию выключено: description хранится как есть.
+var (
+	maskStoredPII bool
+	retainRawPII  bool
+)
+
+// loadStoredPIIConfig — MASK_STORED_PII, RETAIN_RAW из окружения
+func loadStoredPIIConfig() {
+	if v, err := strconv.ParseBool(getEnv("MASK_STORED_PII", "")); err == nil {
+		maskStoredPII = v
+	}
+	if v, err := strconv.ParseBool(getEnv("RETAIN_RAW", "")); err == nil {
+		retainRawPII = v
+	}
+	if maskStoredPII {
+		raw := "исходный текст не хранится"
+		if retainRawPII {
+			raw = "исходный текст — в tickets_raw"
+		}
+		fmt.Printf("🔒 MASK_STORED_PII: ИИН, счета и карты в tickets.description маскируются (%s)\n", raw)
+	}
+}
+
+var (
+	// piiIINRe — 12 цифр подряд (ИИН/БИН), проверяется контрольный разряд
+	piiIINRe = regexp.MustCompile(`\b\d{12}\b`)
+	// piiIBANRe — казахстанский IBAN (KZ + 18 знаков), допускаются пробелы по 4
+	piiIBANRe = regexp.MustCompile(`(?i)\bKZ\d{2}(?:\s?[0-9A-Z]{4}){4}\b`)
+	// piiCardRe — 16 цифр группами по 4 (пробел/дефис) или подряд, проверяется Луном
+	piiCardRe = regexp.MustCompile(`\b\d{4}(?:[ -]?\d{4}){3}\b`)
+)
+
+// maskPII — ИИН → [ИИН], IBAN → [СЧЁТ], номер карты → [КАРТА]. Повторный
+// вызов ничего не меняет.
+func maskPII(text string) string {
+	text = piiIBANRe.ReplaceAllString(text, "[СЧЁТ]")
+	text = piiCardRe.ReplaceAllStringFunc(text, func(s string) string {
+		if luhnValid(digitsOnly(s)) {
+			return "[КАРТА]"
+		}
+		return s
+	})
+	return piiIINRe.ReplaceAllStringFunc(text, func(s string) string {
+		if iinValid(s) {
+			return "[ИИН]"
+		}
+		return s
+	})
+}
+
+// digitsOnly — только цифры строки
+func digitsOnly(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// luhnValid — контрольная сумма номера карты
+func luhnValid(digits string) bool {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return len(digits) > 0 && sum%10 == 0
+}
+
+// iinValid — контрольный разряд ИИН/БИН: веса 1..11, при остатке 10 — веса 3..11,1,2
+func iinValid(iin string) bool {
+	check := func(shift int) int {
+		sum := 0
+		for i := 0; i < 11; i++ {
+			sum += int(iin[i]-'0') * ((i+shift)%11 + 1)
+		}
+		return sum % 11
+	}
+	c := check(0)
+	if c == 10 {
+		if c = check(2); c == 10 {
+			return false
+		}
+	}
+	return c == int(iin[11]-'0')
+}
+
+// storedDescription — текст для tickets.description с учётом MASK_STORED_PII.
+// content_hash по-прежнему считается от исходного текста: изменения во
+// входном файле находятся так же, как без маскирования.
+func storedDescription(t TicketInput) string {
+	if !maskStoredPII {
+		return t.Text
+	}
+	return maskPII(t.Text)
+}
+
+// failureTicket — тикет для data/db_failures.jsonl: при MASK_STORED_PII текст и
+// вложение маскируются, исходные — только с RETAIN_RAW (как tickets_raw в БД)
+func failureTicket(t TicketInput) TicketInput {
+	if maskStoredPII && !retainRawPII {
+		t.Text = maskPII(t.Text)
+		t.Attachment = maskPII(t.Attachment)
+	}
+	return t
+}
+
+// saveRawDescription — исходный текст в tickets_raw (RETAIN_RAW), только если
+// маскирование что-то изменило
+func saveRawDescription(ctx context.Context, ex dbExecer, t TicketInput) error {
+	if !maskStoredPII || !retainRawPII || storedDescription(t) == t.Text {
+		return nil
+	}
+	_, err := ex.ExecContext(ctx, `
+		INSERT INTO tickets_raw (guid, description, tenant_id) VALUES ($1,$2,$3)
+		ON CONFLICT (tenant_id, guid) DO UPDATE SET description = EXCLUDED.description,
+			saved_at = NOW()`,
+		t.GUID, t.Text, tenantID(t.Tenant))
+	return err
+}
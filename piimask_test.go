@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+// TestFailureTicket — в db_failures.jsonl ПДн как в БД: маска без RETAIN_RAW
+func TestFailureTicket(t *testing.T) {
+	mask, raw := maskStoredPII, retainRawPII
+	t.Cleanup(func() { maskStoredPII, retainRawPII = mask, raw })
+
+	in := TicketInput{GUID: "g", Text: "Счёт KZ86 125K ZT50 0410 0100, ИИН 900101300017", Attachment: "900101300017.pdf"}
+	for _, c := range []struct {
+		name             string
+		mask, raw        bool
+		text, attachment string
+	}{
+		{"без маскирования", false, false, in.Text, in.Attachment},
+		{"маскирование", true, false, "Счёт [СЧЁТ], ИИН [ИИН]", "[ИИН].pdf"},
+		{"маскирование с RETAIN_RAW", true, true, in.Text, in.Attachment},
+	} {
+		maskStoredPII, retainRawPII = c.mask, c.raw
+		got := failureTicket(in)
+		if got.Text != c.text || got.Attachment != c.attachment {
+			t.Errorf("%s: %q, %q; ожидалось %q, %q", c.name, got.Text, got.Attachment, c.text, c.attachment)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ПРИОРИТЕТНЫЙ ПОРЯДОК — -priority-order: срочные тикеты раньше
+// ═══════════════════════════════════════════════════════════
+
+// estimatePriority — предварительный приоритет без AI: Keyword Fallback и
+// правило сегмента (VIP/Priority → 10). Только для порядка обработки —
+// итоговый приоритет по-прежнему ставят AI и бизнес-правила.
+func estimatePriority(t TicketInput) int {
+	if rule := segmentRuleFor(t.Segment); rule.Priority > 0 {
+		return rule.Priority
+	}
+	return priorityNum(fallbackAnalyze(t).Priority)
+}
+
+// rankByPriority — тикеты по убыванию предварительного приоритета (при
+// равенстве — порядок файла); Index перенумеровывается по новому порядку.
+// С -window первые окна — самые срочные: их результаты в CSV и БД
+// появляются до окончания всего запуска.
+func rankByPriority(tickets []TicketInput) {
+	est := make(map[string]int, len(tickets))
+	for _, t := range tickets {
+		est[t.GUID] = estimatePriority(t)
+	}
+	sort.SliceStable(tickets, func(i, j int) bool {
+		return est[tickets[i].GUID] > est[tickets[j].GUID]
+	})
+	urgent := 0
+	for i := range tickets {
+		tickets[i].Index = i
+		if est[tickets[i].GUID] >= 9 {
+			urgent++
+		}
+	}
+	fmt.Printf("🔝 -priority-order: тикеты по убыванию предварительного приоритета (≥9: %d из %d — первыми)\n",
+		urgent, len(tickets))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  МАТРИЦА ПРИОРИТЕТОВ — (Тип, Тональность) → базовый приоритет
+// ═══════════════════════════════════════════════════════════
+
+// anySentiment — строка матрицы для любой тональности типа
+const anySentiment = "*"
+
+// PriorityMatrix — тип → тональность → приоритет 1–10. Точное совпадение
+// тональности важнее строки "*"; тип не из матрицы сохраняет приоритет AI.
+type PriorityMatrix map[string]map[string]int
+
+// priorityMatrix — правила из промпта (ПРАВИЛА ПРИОРИТЕТА); переопределяется
+// data/priority_matrix.csv. Нижняя граница приоритета AI (модель может только
+// поднять); применяется до правила VIP/Priority → 10 (applyPriorityRules).
+// В промпте приоритет от тональности не зависит: 7 у жалобы — за явное
+// давление, которого матрица не видит, поэтому строк по тональности нет.
+// Граница — младший уровень типа в промпте (консультация общая — 3, а не
+// стандартные 5), иначе матрица поднимала бы ответы модели по правилам.
+var priorityMatrix = PriorityMatrix{
+	"Мошеннические действия": {anySentiment: 9},
+	"Претензия":              {anySentiment: 8},
+	"Жалоба":                 {anySentiment: 6},
+	"Неработоспособность приложения": {anySentiment: 6},
+	"Смена данных":                   {anySentiment: 6},
+	"Консультация":                   {anySentiment: 3},
+	"Спам":                           {anySentiment: 1},
+}
+
+// Lookup — приоритет для пары; ok=false, если тип не описан в матрице
+func (m PriorityMatrix) Lookup(typ, sentiment string) (int, bool) {
+	row, ok := m[typ]
+	if !ok {
+		return 0, false
+	}
+	if p, ok := row[sentiment]; ok {
+		return p, true
+	}
+	p, ok := row[anySentiment]
+	return p, ok
+}
+
+// loadPriorityMatrix — матрица из CSV (Тип,Тональность,Приоритет; "*" — любая
+// тональность). Файл необязателен; если он есть, он полностью заменяет
+// встроенную матрицу, строки с приоритетом вне 1–10 пропускаются.
+func loadPriorityMatrix(fp string) {
+	file, err := os.Open(fp)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	records, err := readCSV(file)
+	if err != nil {
+		fmt.Printf("⚠️ Ошибка чтения %s: %v\n", fp, err)
+		return
+	}
+	matrix := make(PriorityMatrix)
+	rules := 0
+	for i, row := range records {
+		if i == 0 || len(row) < 3 {
+			continue
+		}
+		typ, sentiment := strings.TrimSpace(row[0]), strings.TrimSpace(row[1])
+		p, err := strconv.Atoi(strings.TrimSpace(row[2]))
+		if typ == "" || err != nil || p < 1 || p > 10 {
+			fmt.Printf("⚠️ %s: пропущена строка %d (%v)\n", fp, i+1, row)
+			continue
+		}
+		if sentiment == "" {
+			sentiment = anySentiment
+		}
+		if matrix[typ] == nil {
+			matrix[typ] = make(map[string]int)
+		}
+		matrix[typ][sentiment] = p
+		rules++
+	}
+	priorityMatrix = matrix
+	fmt.Printf("✅ Матрица приоритетов из %s: %d правил для %d типов\n", fp, rules, len(matrix))
+}
+
+// applyPriorityMatrix — приоритет не ниже матрицы по типу и тональности:
+// max(AI, матрица); нечисловой приоритет заменяется значением матрицы
+func applyPriorityMatrix(r AIResult) AIResult {
+	p, ok := priorityMatrix.Lookup(r.Type, r.Sentiment)
+	if !ok {
+		return r
+	}
+	if cur, err := strconv.Atoi(strings.TrimSpace(r.Priority)); err != nil || cur < p {
+		r.Priority = strconv.Itoa(p)
+	}
+	return r
+}
+
+// applyPriorityRules — приоритет по правилам: матрица (нижняя граница), сегмент,
+// клиенты 65+. Считается всегда от ModelPriority, поэтому повторный вызов
+// (resolveType сменил тип) не поднимает приоритет дважды.
+func applyPriorityRules(t TicketInput, r AIResult) AIResult {
+	if r.ModelPriority == "" {
+		r.ModelPriority = r.Priority
+	}
+	r.Priority = r.ModelPriority
+	return applySeniorPriority(t, applySegmentPriority(t, applyPriorityMatrix(r)))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+// TestDefaultPriorityMatrixKeepsPromptPriorities — приоритеты по правилам
+// промпта (ПРАВИЛА ПРИОРИТЕТА) встроенная матрица не меняет ни при какой
+// тональности: она лишь нижняя граница для значений ниже правил
+func TestDefaultPriorityMatrixKeepsPromptPriorities(t *testing.T) {
+	prompt := []struct {
+		typ        string
+		priorities []int
+	}{
+		{"Претензия", []int{10, 8}},
+		{"Мошеннические действия", []int{9}},
+		{"Жалоба", []int{7, 6}},
+		{"Неработоспособность приложения", []int{6}},
+		{"Смена данных", []int{6}},
+		{"Консультация", []int{5, 3}},
+		{"Спам", []int{1}},
+	}
+	for _, c := range prompt {
+		for _, p := range c.priorities {
+			for _, sentiment := range []string{"Негативный", "Нейтральный", "Позитивный"} {
+				want := strconv.Itoa(p)
+				got := applyPriorityMatrix(AIResult{Type: c.typ, Sentiment: sentiment, Priority: want})
+				if got.Priority != want {
+					t.Errorf("%s/%s: приоритет AI %s → %s", c.typ, sentiment, want, got.Priority)
+				}
+			}
+		}
+	}
+}
+
+// TestApplyPriorityMatrixFloor — ниже матрицы поднимается, нечисловой
+// заменяется, тип вне матрицы не трогается
+func TestApplyPriorityMatrixFloor(t *testing.T) {
+	for _, c := range []struct {
+		typ, sentiment, priority, want string
+	}{
+		{"Мошеннические действия", "Нейтральный", "4", "9"},
+		{"Жалоба", "Негативный", "3", "6"},
+		{"Консультация", "Позитивный", "1", "3"},
+		{"Претензия", "Негативный", "высокий", "8"},
+		{"Неизвестный тип", "Негативный", "2", "2"},
+	} {
+		got := applyPriorityMatrix(AIResult{Type: c.typ, Sentiment: c.sentiment, Priority: c.priority})
+		if got.Priority != c.want {
+			t.Errorf("%s/%s, приоритет %s: %s, ожидалось %s", c.typ, c.sentiment, c.priority, got.Priority, c.want)
+		}
+	}
+}
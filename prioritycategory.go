@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  КАТЕГОРИЯ ПРИОРИТЕТА — -priority-category: Low/Medium/High/Critical
+// ═══════════════════════════════════════════════════════════
+
+// priorityCategories — категории по возрастанию; priorityCategoryBounds —
+// верхняя граница каждой, кроме последней (PRIORITY_CATEGORY_BOUNDS, по
+// умолчанию 1–3 Low, 4–6 Medium, 7–8 High, 9–10 Critical)
+var (
+	priorityCategories     = []string{"Low", "Medium", "High", "Critical"}
+	priorityCategoryBounds = []int{3, 6, 8}
+)
+
+// loadPriorityCategoryBounds — PRIORITY_CATEGORY_BOUNDS="3,6,8": три
+// возрастающие границы из 1–9; некорректное значение — границы по умолчанию
+func loadPriorityCategoryBounds() error {
+	v := getEnv("PRIORITY_CATEGORY_BOUNDS", "")
+	if v == "" {
+		return nil
+	}
+	parts := strings.Split(v, ",")
+	if len(parts) != len(priorityCategories)-1 {
+		return fmt.Errorf("PRIORITY_CATEGORY_BOUNDS=%q: нужно %d границы через запятую", v, len(priorityCategories)-1)
+	}
+	bounds := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 1 || n > 9 || (i > 0 && n <= bounds[i-1]) {
+			return fmt.Errorf("PRIORITY_CATEGORY_BOUNDS=%q: границы — возрастающие числа 1–9", v)
+		}
+		bounds[i] = n
+	}
+	priorityCategoryBounds = bounds
+	return nil
+}
+
+// priorityCategory — категория числового приоритета; "" — без
+// -priority-category или приоритет не число
+func priorityCategory(priority string) string {
+	if !*prioCategory {
+		return ""
+	}
+	n := priorityNum(priority)
+	if n <= 0 {
+		return ""
+	}
+	for i, bound := range priorityCategoryBounds {
+		if n <= bound {
+			return priorityCategories[i]
+		}
+	}
+	return priorityCategories[len(priorityCategories)-1]
+}
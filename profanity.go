@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  НЕНОРМАТИВНАЯ ЛЕКСИКА — маскирование в summary (-mask-profanity)
+// ═══════════════════════════════════════════════════════════
+
+// profanityWords — встроенный список RU/KZ/ENG. «*» в конце — основа (любое
+// окончание), без «*» — слово целиком. Сравнение без регистра, «ё» = «е».
+// Дополняется data/profanity.txt (по слову в строке, # — комментарий).
+var profanityWords = []string{
+	"бля*", "хуй*", "хуе*", "хуя*", "пизд*", "еба*", "ебл*", "ебу*", "ебн*", "выеб*", "уеб*",
+	"сука", "суки", "сучар*", "мудак*", "мудил*", "гандон*", "пидор*", "пидар*",
+	"қотақ*", "сігей*", "сігіп*",
+	"fuck*", "motherfuck*", "shit*", "bitch*", "asshole*", "dickhead*", "cunt*",
+}
+
+// profanityExact / profanityStems — разобранный profanityWords
+var (
+	profanityExact = map[string]bool{}
+	profanityStems []string
+)
+
+// loadProfanityWords — встроенный список + файл path (если есть)
+func loadProfanityWords(path string) {
+	words := append([]string(nil), profanityWords...)
+	added := 0
+	if file, err := os.Open(path); err == nil {
+		sc := bufio.NewScanner(file)
+		for sc.Scan() {
+			if w := strings.TrimSpace(sc.Text()); w != "" && !strings.HasPrefix(w, "#") {
+				words = append(words, w)
+				added++
+			}
+		}
+		file.Close()
+	}
+	for _, w := range words {
+		w = normalizeProfanity(w)
+		if stem, ok := strings.CutSuffix(w, "*"); ok {
+			profanityStems = append(profanityStems, stem)
+		} else {
+			profanityExact[w] = true
+		}
+	}
+	fmt.Printf("🙊 Маскирование лексики в summary: %d слов и основ (из %s: %d)\n",
+		len(profanityExact)+len(profanityStems), path, added)
+}
+
+func normalizeProfanity(w string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(w)), "ё", "е")
+}
+
+// isProfanity — слово из списка (целиком или по основе)
+func isProfanity(word string) bool {
+	w := normalizeProfanity(word)
+	if profanityExact[w] {
+		return true
+	}
+	for _, stem := range profanityStems {
+		if strings.HasPrefix(w, stem) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskProfaneWords — слова из списка → первая буква и звёздочки («с***»).
+// Пунктуация и пробелы не трогаются. n — сколько слов замаскировано.
+func maskProfaneWords(s string) (string, int) {
+	runes := []rune(s)
+	n := 0
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && unicode.IsLetter(runes[j]) {
+			j++
+		}
+		if isProfanity(string(runes[i:j])) {
+			for k := i + 1; k < j; k++ {
+				runes[k] = '*'
+			}
+			n++
+		}
+		i = j
+	}
+	return string(runes), n
+}
+
+// maskSummaries — маскирует summary батча (исходный текст тикета не меняется)
+func maskSummaries(results map[int]AIResult) {
+	masked := 0
+	for idx, r := range results {
+		if s, n := maskProfaneWords(r.Summary); n > 0 {
+			r.Summary = s
+			results[idx] = r
+			masked++
+		}
+	}
+	if masked > 0 {
+		fmt.Printf("   🙊 Summary с замаскированной лексикой: %d\n", masked)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ПРОГРЕСС — «X/Y готово, осталось ~Z» для длинных фаз
+// ═══════════════════════════════════════════════════════════
+
+// progressInterval — не чаще одной строки прогресса за этот интервал
+const progressInterval = 10 * time.Second
+
+// progressReporter — счётчик готовых тикетов фазы с ETA по наблюдаемой
+// скорости (на геокодировании её задаёт лимит Nominatim). Безопасен для
+// вызова из горутин. В режиме -log-format json молчит.
+type progressReporter struct {
+	mu       sync.Mutex
+	label    string
+	total    int
+	done     int
+	start    time.Time
+	lastShow time.Time
+}
+
+func newProgress(label string, total int) *progressReporter {
+	now := time.Now()
+	return &progressReporter{label: label, total: total, start: now, lastShow: now}
+}
+
+// Inc — ещё один тикет готов; печатает прогресс раз в progressInterval и в конце
+func (p *progressReporter) Inc() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	now := time.Now()
+	if p.done < p.total && now.Sub(p.lastShow) < progressInterval {
+		return
+	}
+	p.lastShow = now
+	if *logFormat == "json" || p.total == 0 {
+		return
+	}
+	elapsed := now.Sub(p.start)
+	eta := time.Duration(float64(elapsed) / float64(p.done) * float64(p.total-p.done))
+	fmt.Printf("⏳ %s: %d/%d (%.0f%%), прошло %v, осталось ~%v\n", p.label, p.done, p.total,
+		float64(p.done)*100/float64(p.total), elapsed.Round(time.Second), eta.Round(time.Second))
+}
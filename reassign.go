@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ПЕРЕНАЗНАЧЕНИЕ — POST /reassign (тикет или все тикеты менеджера)
+// ═══════════════════════════════════════════════════════════
+
+// reassignRequest — что переносим (guid или from_manager) и куда (manager и/или office)
+type reassignRequest struct {
+	GUID        string `json:"guid"`
+	FromManager string `json:"from_manager"`
+	Manager     string `json:"manager"`
+	Office      string `json:"office"`
+	Tenant      string `json:"tenant_id"`
+}
+
+// reassignment — новое назначение одного тикета
+type reassignment struct {
+	GUID            string  `json:"guid"`
+	ManagerName     string  `json:"manager_name"`
+	ManagerRole     string  `json:"manager_role"`
+	AssignedOffice  string  `json:"assigned_office"`
+	RoutingReason   string  `json:"routing_reason"`
+	DistanceKm      float64 `json:"distance_km,omitempty"`
+	PreviousManager string  `json:"previous_manager"`
+	PreviousOffice  string  `json:"previous_office"`
+}
+
+// reassignFailure — тикет, который не удалось перенести
+type reassignFailure struct {
+	GUID  string `json:"guid"`
+	Error string `json:"error"`
+}
+
+// reassignTicket — поля v_full_results, нужные для выбора менеджера
+type reassignTicket struct {
+	GUID, Segment, Type, Language string
+	Manager, Office               string
+	GeoLat, GeoLon                float64
+}
+
+// handleReassign — POST /reassign: {"guid": "..."} или {"from_manager": "..."}
+// плюс {"manager": "..."} и/или {"office": "..."}. Только офис — менеджер
+// выбирается findBestManager (текущий менеджер тикета исключается).
+// Обновляет routing_results и нагрузку менеджеров, отвечает новыми назначениями.
+func handleReassign(e *Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "только POST")
+			return
+		}
+		if e.db == nil {
+			writeError(w, http.StatusServiceUnavailable, "БД не подключена (DB_HOST/DB_NAME)")
+			return
+		}
+		var req reassignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+			return
+		}
+		req.GUID, req.FromManager = strings.TrimSpace(req.GUID), strings.TrimSpace(req.FromManager)
+		req.Manager, req.Office = strings.TrimSpace(req.Manager), strings.TrimSpace(req.Office)
+		if (req.GUID == "") == (req.FromManager == "") {
+			writeError(w, http.StatusBadRequest, "нужно одно из полей: guid или from_manager")
+			return
+		}
+		if req.Manager == "" && req.Office == "" {
+			writeError(w, http.StatusBadRequest, "нужно поле manager и/или office")
+			return
+		}
+		te, ok := e.forTenant(req.Tenant)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "неизвестный tenant_id: "+req.Tenant)
+			return
+		}
+		office := ""
+		if req.Office != "" {
+			if office = te.normalizeOfficeName(req.Office); office == "" {
+				writeError(w, http.StatusBadRequest, "офис не найден: "+req.Office)
+				return
+			}
+		}
+		var target *Manager
+		if req.Manager != "" {
+			if target, office = te.lookupManager(req.Manager, office); target == nil {
+				writeError(w, http.StatusBadRequest, "менеджер не найден: "+req.Manager)
+				return
+			}
+		}
+
+		tickets, err := te.loadReassignTickets(r.Context(), req.GUID, req.FromManager)
+		if err != nil {
+			log.Printf("⚠️ /reassign: %v", err)
+			writeError(w, http.StatusInternalServerError, "ошибка запроса к БД")
+			return
+		}
+		if len(tickets) == 0 {
+			writeError(w, http.StatusNotFound, "нет тикетов для переназначения")
+			return
+		}
+
+		resp := struct {
+			Reassigned []reassignment    `json:"reassigned"`
+			Failed     []reassignFailure `json:"failed,omitempty"`
+		}{Reassigned: []reassignment{}}
+		for _, t := range tickets {
+			ra, err := te.reassignOne(r.Context(), t, target, office)
+			if err != nil {
+				resp.Failed = append(resp.Failed, reassignFailure{t.GUID, err.Error()})
+				continue
+			}
+			resp.Reassigned = append(resp.Reassigned, ra)
+		}
+		fmt.Printf("🔀 /reassign: перенесено %d, ошибок %d\n", len(resp.Reassigned), len(resp.Failed))
+		status := http.StatusOK
+		if len(resp.Reassigned) == 0 {
+			status = http.StatusConflict
+		}
+		writeJSON(w, status, resp)
+	}
+}
+
+// lookupManager — менеджер по имени в офисе (office="" — в любом офисе движка)
+func (e *Engine) lookupManager(name, office string) (*Manager, string) {
+	offices := e.offices
+	if office != "" {
+		offices = []string{office}
+	}
+	for _, o := range offices {
+		if m := e.findManager(o, name); m != nil {
+			return m, o
+		}
+	}
+	return nil, ""
+}
+
+// loadReassignTickets — тикет по GUID или все тикеты менеджера (в тенанте движка)
+func (e *Engine) loadReassignTickets(ctx context.Context, guid, fromManager string) ([]reassignTicket, error) {
+	cond, arg := "guid = $1", guid
+	if guid == "" {
+		cond, arg = "manager_name = $1", fromManager
+	}
+	rows, err := e.db.QueryContext(ctx, `
+		SELECT guid, COALESCE(segment,''), COALESCE(type,''), COALESCE(language,''),
+		       COALESCE(manager_name,''), COALESCE(assigned_office,''),
+		       COALESCE(geo_lat,0), COALESCE(geo_lon,0)
+		FROM v_full_results
+		WHERE `+cond+` AND tenant_id = $2
+		ORDER BY priority DESC NULLS LAST, guid`, arg, e.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tickets []reassignTicket
+	for rows.Next() {
+		var t reassignTicket
+		if err := rows.Scan(&t.GUID, &t.Segment, &t.Type, &t.Language,
+			&t.Manager, &t.Office, &t.GeoLat, &t.GeoLon); err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, rows.Err()
+}
+
+// reassignOne — выбор менеджера, запись в routing_results и перенос нагрузки.
+// target=nil — findBestManager в office без текущего менеджера тикета.
+func (e *Engine) reassignOne(ctx context.Context, t reassignTicket, target *Manager, office string) (reassignment, error) {
+	e.mu.Lock()
+	winner := target
+	if winner == nil {
+		var pool []*Manager
+		for _, m := range e.managers[office] {
+			if m.Name != t.Manager {
+				pool = append(pool, m)
+			}
+		}
+		winner = e.findBestManager(pool, t.Segment, AIResult{Type: t.Type, Language: t.Language}, office)
+	} else {
+		winner.Workload++
+	}
+	e.mu.Unlock()
+	if winner == nil {
+		return reassignment{}, fmt.Errorf("в офисе '%s' нет подходящего менеджера", office)
+	}
+	if winner.Name == t.Manager && office == t.Office {
+		e.mu.Lock()
+		winner.Workload--
+		e.mu.Unlock()
+		return reassignment{}, fmt.Errorf("тикет уже назначен на %s", winner.Name)
+	}
+
+	ra := reassignment{
+		GUID:            t.GUID,
+		ManagerName:     winner.Name,
+		ManagerRole:     winner.Role,
+		AssignedOffice:  office,
+		RoutingReason:   fmt.Sprintf("переназначение: %s → %s", t.Manager, winner.Name),
+		PreviousManager: t.Manager,
+		PreviousOffice:  t.Office,
+	}
+	if t.GeoLat != 0 || t.GeoLon != 0 {
+		ra.DistanceKm = e.distanceToOffice(t.GeoLat, t.GeoLon, office)
+	}
+	err := withDBRetry(ctx, dbSaveTimeout, func(ctx context.Context) error {
+		_, err := e.db.ExecContext(ctx, `
+			UPDATE routing_results SET manager_name = $2, manager_role = $3, assigned_office = $4,
+			       routing_reason = $5, is_escalated = FALSE, distance_km = $6, run_id = $7, routed_at = NOW()
+			WHERE tenant_id = $8 AND guid = $1`,
+			ra.GUID, ra.ManagerName, ra.ManagerRole, ra.AssignedOffice, ra.RoutingReason, distanceToDB(ra.DistanceKm),
+			nullIfEmpty(runID), e.tenant)
+		return err
+	})
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		winner.Workload-- // назначение не записано — нагрузку не переносим
+		log.Printf("⚠️ /reassign %s: %v", t.GUID, err)
+		return reassignment{}, fmt.Errorf("ошибка БД")
+	}
+	if prev := e.findManager(t.Office, t.Manager); prev != nil && prev.Workload > 0 {
+		prev.Workload--
+	}
+	return ra, nil
+}
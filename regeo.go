@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ПОВТОРНОЕ ГЕОКОДИРОВАНИЕ — -regeo: тикеты с Метод_гео=unknown без AI
+// ═══════════════════════════════════════════════════════════
+
+// runRegeo — тикеты прошлых запусков, у которых геокодирование не удалось
+// (geo_method=unknown → 50/50 по ГО), заново геокодируются и роутятся с
+// сохранённым ответом AI. Источник — БД (tickets + ai_analysis), без БД —
+// строки outPath и исходные поля из ticketsPath. Обновляются только тикеты,
+// для которых офис теперь найден; остальные остаются как были.
+func (e *Engine) runRegeo(ctx context.Context, ticketsPath, outPath string) error {
+	timings = newRunTimings()
+	var tickets []TicketInput
+	var aiResults map[int]AIResult
+	var err error
+	if e.db != nil {
+		tickets, aiResults, err = loadRegeoFromDB(ctx, e.db)
+	} else {
+		tickets, aiResults, err = loadRegeoFromCSV(outPath, ticketsPath)
+	}
+	if err != nil {
+		return err
+	}
+	if len(tickets) == 0 {
+		fmt.Println("✅ -regeo: тикетов с неудачным геокодированием нет")
+		return nil
+	}
+	fmt.Printf("\n🔁 -regeo: %d тикетов с Метод_гео=unknown — геокодирование и роутинг заново, без AI\n", len(tickets))
+
+	for i := range tickets {
+		if err := fillAge(&tickets[i]); err != nil {
+			log.Printf("⚠️ %s: дата рождения: %v — возраст не учитывается", tickets[i].GUID, err)
+		}
+	}
+	e.geocodeByTenant(ctx, tickets, aiResults)
+	if ctx.Err() != nil {
+		fmt.Println("🛑 -regeo прерван до роутинга — ничего не обновлено")
+		return nil
+	}
+
+	fmt.Println("\n📋 Роутинг тикетов с найденным адресом...")
+	fixed := make(map[string]RoutingResult)
+	saved := 0
+	for _, t := range tickets {
+		ai := aiResults[t.Index]
+		if ai.GeoMethod == "unknown" {
+			continue
+		}
+		fmt.Printf("\n%s | %s | офис:'%s' [%s]\n", t.GUID[:min(8, len(t.GUID))], t.RawCity, ai.NearestOffice, ai.GeoMethod)
+		r := e.engineFor(t).buildRoutingResult(t, ai)
+		_, r.NeedsReview = crossCheckAI(t, ai)
+		r.ReviewReason = reviewReason(r)
+		fixed[t.GUID] = r
+
+		if e.db != nil {
+			err := withDBRetry(context.WithoutCancel(ctx), dbSaveTimeout, func(ctx context.Context) error {
+				return saveTicketChainTx(ctx, e.db, t, ai, r)
+			})
+			if err != nil {
+				log.Printf("⚠️ БД %s: %v", t.GUID, err)
+				continue
+			}
+			saved++
+		}
+	}
+
+	// results.csv: строки исправленных тикетов заменяются на месте
+	if len(fixed) > 0 && outPath != "-" {
+		if _, err := os.Stat(outPath); err == nil {
+			err := rewriteResultRows(outPath, func(row []string) []string {
+				if r, ok := fixed[row[0]]; ok {
+					return resultCSVRow(r)
+				}
+				return row
+			})
+			if err != nil {
+				return fmt.Errorf("%s: обновление строк: %v", outPath, err)
+			}
+			if *totalsRows {
+				if err := rewriteTotals(outPath); err != nil {
+					log.Printf("⚠️ Строки %s не записаны: %v", totalsGUID, err)
+				}
+			}
+		}
+	}
+
+	fmt.Printf("\n✅ -regeo: адрес найден для %d из %d тикетов", len(fixed), len(tickets))
+	if e.db != nil {
+		fmt.Printf(", в БД обновлено %d", saved)
+	}
+	fmt.Println()
+	return nil
+}
+
+// loadRegeoFromDB — тикеты с ai_analysis.geo_method='unknown' и их сохранённый AI-результат.
+// content_hash берётся из БД как есть: при MASK_STORED_PII без tickets_raw текст —
+// маскированный, и хэш от него не совпал бы с исходным (тикет считался бы изменённым).
+func loadRegeoFromDB(ctx context.Context, conn *sql.DB) ([]TicketInput, map[int]AIResult, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT t.guid, COALESCE(t.gender, ''), COALESCE(t.birthdate, ''), COALESCE(raw.description, t.description, ''),
+		       COALESCE(t.attachment, ''), COALESCE(t.segment, ''), COALESCE(t.country, ''),
+		       COALESCE(t.oblast, ''), COALESCE(t.city, ''), COALESCE(t.street, ''),
+		       COALESCE(t.house, ''), t.tenant_id, t.created_at, COALESCE(t.content_hash, ''),
+		       COALESCE(a.type, ''), COALESCE(a.sentiment, ''), COALESCE(a.language, ''),
+		       COALESCE(a.priority::text, ''), COALESCE(a.summary, ''), COALESCE(a.nearest_office, ''),
+		       COALESCE(a.source, ''), COALESCE(a.raw_ai, ''), COALESCE(a.prompt_version, ''),
+		       COALESCE(a.link_domains, ''), a.confidence, COALESCE(a.type_source, '')
+		FROM tickets t
+		JOIN ai_analysis a ON a.tenant_id = t.tenant_id AND a.guid = t.guid
+		LEFT JOIN tickets_raw raw ON raw.tenant_id = t.tenant_id AND raw.guid = t.guid -- MASK_STORED_PII: исходный текст, если сохранён
+		WHERE a.geo_method = 'unknown'
+		ORDER BY t.tenant_id, t.guid`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("выборка geo_method=unknown: %v", err)
+	}
+	defer rows.Close()
+
+	var tickets []TicketInput
+	aiResults := make(map[int]AIResult)
+	for rows.Next() {
+		var t TicketInput
+		var ai AIResult
+		var confidence sql.NullFloat64
+		var createdAt sql.NullTime
+		if err := rows.Scan(&t.GUID, &t.Gender, &t.Birthdate, &t.Text, &t.Attachment, &t.Segment,
+			&t.Country, &t.Oblast, &t.RawCity, &t.Street, &t.House, &t.Tenant, &createdAt, &t.StoredHash,
+			&ai.Type, &ai.Sentiment, &ai.Language, &ai.Priority, &ai.Summary, &ai.NearestOffice,
+			&ai.Source, &ai.RawAI, &ai.PromptVersion, &ai.LinkDomains, &confidence, &ai.TypeSource); err != nil {
+			return nil, nil, err
+		}
+		t.CreatedTime = createdAt.Time // срок SLA — от сохранённой даты обращения
+		ai.Confidence = 1.0
+		if confidence.Valid {
+			ai.Confidence = confidence.Float64
+		}
+		t.Index = len(tickets)
+		aiResults[t.Index] = ai
+		tickets = append(tickets, t)
+	}
+	return tickets, aiResults, rows.Err()
+}
+
+// loadRegeoFromCSV — строки results.csv с Метод_гео=unknown; поля адреса и
+// сегмент — из входного файла тикетов. Уверенности модели и офиса по LLM в
+// results.csv нет: уверенность считается 1.0, LLM-геолокация не используется.
+func loadRegeoFromCSV(outPath, ticketsPath string) ([]TicketInput, map[int]AIResult, error) {
+	f, err := os.Open(outPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("без БД -regeo читает %s: %v", outPath, err)
+	}
+	rows, err := readCSV(decodeResults(f))
+	f.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("чтение %s: %v", outPath, err)
+	}
+	failed := make(map[string]RoutingResult)
+	for i, row := range rows {
+		if i == 0 || len(row) == 0 || row[0] == totalsGUID {
+			continue
+		}
+		if r := routingResultFromCSV(row); r.GeoMethod == "unknown" {
+			failed[r.GUID] = r
+		}
+	}
+	if len(failed) == 0 {
+		return nil, nil, nil
+	}
+
+	records, err := readTable(ticketsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("чтение tickets %s: %v", ticketsPath, err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%s пуст — нет даже заголовка", ticketsPath)
+	}
+	cols, err := mapTicketColumns(records[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", ticketsPath, err)
+	}
+
+	var tickets []TicketInput
+	aiResults := make(map[int]AIResult)
+	for _, row := range records[1:] {
+		t := cols.Ticket(row)
+		r, ok := failed[t.GUID]
+		if !ok {
+			continue
+		}
+		delete(failed, t.GUID) // повтор GUID во входном файле — берём первое вхождение
+		t.Index = len(tickets)
+		aiResults[t.Index] = AIResult{
+			Type:        r.Type,
+			Sentiment:   r.Sentiment,
+			Language:    r.Language,
+			Priority:    r.Priority,
+			Summary:     r.Summary,
+			Source:      r.Source,
+			Confidence:  1.0,
+			LinkDomains: r.LinkDomains,
+			TypeSource:  r.TypeSource,
+		}
+		tickets = append(tickets, t)
+	}
+	if len(failed) > 0 {
+		fmt.Printf("⚠️ -regeo: %d GUID из %s нет в %s — пропущены\n", len(failed), outPath, ticketsPath)
+	}
+	return tickets, aiResults, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ОЖИДАЕМОЕ ВРЕМЯ ОТВЕТА — база по приоритету × нагрузка офиса
+// ═══════════════════════════════════════════════════════════
+
+// responseBase — время ответа по приоритету при свободном офисе
+// (RESPONSE_BASE, формат как у SLA_HOURS)
+var responseBase = map[int]time.Duration{
+	10: 30 * time.Minute,
+	9:  time.Hour,
+	8:  2 * time.Hour,
+	7:  3 * time.Hour,
+	6:  4 * time.Hour,
+	5:  8 * time.Hour,
+	4:  12 * time.Hour,
+	3:  24 * time.Hour,
+	2:  36 * time.Hour,
+	1:  48 * time.Hour,
+}
+
+// Параметры модели: RESPONSE_CAPACITY — тикетов в работе на менеджера, при
+// которых время удваивается; RESPONSE_ESCALATED_FACTOR — множитель для
+// эскалированных в ГО (передача между офисами)
+var (
+	responseCapacity        = 10.0
+	responseEscalatedFactor = 1.5
+)
+
+// loadResponseConfig — RESPONSE_BASE, RESPONSE_CAPACITY, RESPONSE_ESCALATED_FACTOR
+func loadResponseConfig() error {
+	if v := getEnv("RESPONSE_BASE", ""); v != "" {
+		table, err := parsePriorityDurations("RESPONSE_BASE", v)
+		if err != nil {
+			return err
+		}
+		responseBase = table
+	}
+	for _, p := range []struct {
+		name string
+		dst  *float64
+	}{
+		{"RESPONSE_CAPACITY", &responseCapacity},
+		{"RESPONSE_ESCALATED_FACTOR", &responseEscalatedFactor},
+	} {
+		if v := getEnv(p.name, ""); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f <= 0 {
+				return fmt.Errorf("%s=%q: ожидается положительное число", p.name, v)
+			}
+			*p.dst = f
+		}
+	}
+	return nil
+}
+
+// officeLoad — суммарная нагрузка менеджеров офиса и их число (вызывать под e.mu)
+func (e *Engine) officeLoad(office string) (load, managers int) {
+	for _, m := range e.managers[office] {
+		load += m.Workload
+	}
+	return load, len(e.managers[office])
+}
+
+// estimateResponse — ожидаемое время ответа: база по приоритету ×
+// (1 + нагрузка офиса / (менеджеров × RESPONSE_CAPACITY)), для эскалированных —
+// ещё × RESPONSE_ESCALATED_FACTOR. 0 — оценки нет (спам, без менеджера,
+// приоритет без базы). Вызывать под e.mu (нагрузка — после назначения).
+func (e *Engine) estimateResponse(r RoutingResult) time.Duration {
+	if r.Type == "Спам" || r.ManagerName == "Не найден" {
+		return 0
+	}
+	base := durationForPriority(responseBase, r.Priority)
+	if base == slaNone {
+		return 0
+	}
+	factor := 1.0
+	if load, n := e.officeLoad(r.AssignedOffice); n > 0 {
+		factor += float64(load) / (float64(n) * responseCapacity)
+	}
+	if r.IsEscalated {
+		factor *= responseEscalatedFactor
+	}
+	return time.Duration(math.Round(float64(base)*factor/float64(time.Minute))) * time.Minute
+}
+
+// formatResponseTime — «2ч 30м» для консоли и итогов
+func formatResponseTime(d time.Duration) string {
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dм", m)
+	case m == 0:
+		return fmt.Sprintf("%dч", h)
+	}
+	return fmt.Sprintf("%dч %dм", h, m)
+}
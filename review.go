@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ОЧЕРЕДЬ РУЧНОЙ ПРОВЕРКИ — review_queue (status: pending → reviewed)
+// ═══════════════════════════════════════════════════════════
+
+// Критерии постановки в очередь (REVIEW_TYPES, REVIEW_MIN_PRIORITY, REVIEW_ON_DISAGREEMENT)
+var (
+	reviewTypes = map[string]bool{
+		"Мошеннические действия": true,
+		"Претензия":              true,
+	}
+	reviewMinPriority    = 0 // 0 — по приоритету не ставим
+	reviewOnDisagreement = true
+)
+
+// loadReviewConfig — критерии очереди проверки из окружения
+func loadReviewConfig() {
+	if v, ok := os.LookupEnv("REVIEW_TYPES"); ok {
+		reviewTypes = make(map[string]bool)
+		for _, typ := range strings.Split(v, ",") {
+			if typ = strings.TrimSpace(typ); typ != "" {
+				reviewTypes[typ] = true
+			}
+		}
+	}
+	if n, err := strconv.Atoi(getEnv("REVIEW_MIN_PRIORITY", "")); err == nil && n >= 0 {
+		reviewMinPriority = n
+	}
+	if v, err := strconv.ParseBool(getEnv("REVIEW_ON_DISAGREEMENT", "")); err == nil {
+		reviewOnDisagreement = v
+	}
+}
+
+// reviewReason — почему тикет нужно проверить вручную; "" — не нужно
+func reviewReason(r RoutingResult) string {
+	var reasons []string
+	if reviewTypes[r.Type] {
+		reasons = append(reasons, "тип: "+r.Type)
+	}
+	if reviewMinPriority > 0 && priorityNum(r.Priority) >= reviewMinPriority {
+		reasons = append(reasons, fmt.Sprintf("приоритет %s ≥ %d", r.Priority, reviewMinPriority))
+	}
+	if ensembleVote(r) {
+		reasons = append(reasons, "ансамбль: расхождение AI и Keyword Fallback, выбран тип высокой важности")
+	} else if reviewOnDisagreement && r.NeedsReview {
+		reasons = append(reasons, "AI и Keyword Fallback расходятся")
+	}
+	if lowConfidence(r.Source, r.Confidence) {
+		reasons = append(reasons, fmt.Sprintf("уверенность AI %.2f < %.2f", r.Confidence, aiMinConfidence))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// enqueueReview — постановка в очередь. Уже проверенный тикет при повторной
+// обработке в pending не возвращается.
+func enqueueReview(ctx context.Context, ex dbExecer, guid, tenant, reason string) error {
+	_, err := ex.ExecContext(ctx, `
+		INSERT INTO review_queue (guid, reason, tenant_id) VALUES ($1,$2,$3)
+		ON CONFLICT (tenant_id, guid) DO UPDATE SET reason = EXCLUDED.reason
+		WHERE review_queue.status = 'pending'`, guid, reason, tenantID(tenant))
+	return err
+}
+
+// errNotPending — тикета нет в очереди или он уже проверен
+var errNotPending = fmt.Errorf("тикет не ожидает проверки")
+
+// markReviewed — pending → reviewed с отметкой, кто и когда проверил
+func (e *Engine) markReviewed(ctx context.Context, tenant, guid, reviewer string) error {
+	if e.db == nil {
+		return fmt.Errorf("БД не подключена")
+	}
+	res, err := e.db.ExecContext(ctx, `
+		UPDATE review_queue SET status = 'reviewed', reviewer = $2, reviewed_at = NOW()
+		WHERE tenant_id = $3 AND guid = $1 AND status = 'pending'`, guid, reviewer, tenantID(tenant))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotPending
+	}
+	return nil
+}
+
+// handleReview — POST /review {"guid": "...", "reviewer": "..."}: отметить тикет проверенным
+func handleReview(e *Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "только POST")
+			return
+		}
+		if e.db == nil {
+			writeError(w, http.StatusServiceUnavailable, "БД не подключена (DB_HOST/DB_NAME)")
+			return
+		}
+		var req struct {
+			GUID     string `json:"guid"`
+			Reviewer string `json:"reviewer"`
+			Tenant   string `json:"tenant_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+			return
+		}
+		req.GUID = strings.TrimSpace(req.GUID)
+		if req.GUID == "" || strings.TrimSpace(req.Reviewer) == "" {
+			writeError(w, http.StatusBadRequest, "поля guid и reviewer обязательны")
+			return
+		}
+		switch err := e.markReviewed(r.Context(), req.Tenant, req.GUID, req.Reviewer); err {
+		case nil:
+			writeJSON(w, http.StatusOK, map[string]string{"guid": req.GUID, "status": "reviewed"})
+		case errNotPending:
+			writeError(w, http.StatusNotFound, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "ошибка БД")
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ДОЛЖНОСТИ — минимальная должность менеджера по типу обращения
+// ═══════════════════════════════════════════════════════════
+
+// Должности по старшинству: Специалист < Ведущий < Главный. roleUnknown —
+// должность из managers.csv не распознана (не проходит ни одно требование).
+const (
+	roleUnknown = iota
+	roleSpecialist
+	roleLead
+	roleChief
+)
+
+// roleNames — название должности по рангу (как в managers.csv)
+var roleNames = [...]string{
+	roleUnknown:    "",
+	roleSpecialist: "Специалист",
+	roleLead:       "Ведущий специалист",
+	roleChief:      "Главный специалист",
+}
+
+// minRoleByType — тип обращения → минимальная должность. Встроенные правила
+// дополняются и переопределяются data/role_rules.csv построчно.
+var minRoleByType = map[string]int{
+	"Претензия":    roleLead,
+	"Смена данных": roleChief,
+}
+
+// roleRank — ранг должности: «Главный специалист», «Ведущий» и т.п.
+// (по ключевому слову, без учёта регистра)
+func roleRank(role string) int {
+	r := strings.ToLower(role)
+	switch {
+	case strings.Contains(r, "главный"):
+		return roleChief
+	case strings.Contains(r, "ведущий"):
+		return roleLead
+	case strings.Contains(r, "специалист"):
+		return roleSpecialist
+	}
+	return roleUnknown
+}
+
+// requiredRole — минимальный ранг для типа обращения; 0 — без требования
+func requiredRole(typ string) int {
+	return minRoleByType[typ]
+}
+
+// roleRequirement — требование для причин роутинга: «Главный специалист»
+// (выше некуда) или «Ведущий специалист и выше»; "" — без требования
+func roleRequirement(rank int) string {
+	switch {
+	case rank <= roleSpecialist:
+		return ""
+	case rank == roleChief:
+		return roleNames[rank]
+	}
+	return roleNames[rank] + " и выше"
+}
+
+// loadRoleRules — правила из CSV (Тип,Мин_должность). Файл необязателен;
+// пустая должность или «Специалист» снимает требование (в т.ч. встроенное).
+func loadRoleRules(fp string) {
+	file, err := os.Open(fp)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	records, err := readCSV(file)
+	if err != nil {
+		fmt.Printf("⚠️ Ошибка чтения %s: %v\n", fp, err)
+		return
+	}
+	added := 0
+	for i, row := range records {
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+		typ, role := strings.TrimSpace(row[0]), strings.TrimSpace(row[1])
+		rank := roleRank(role)
+		if typ == "" || (role != "" && rank == roleUnknown) {
+			fmt.Printf("⚠️ %s: пропущена строка %d (%v)\n", fp, i+1, row)
+			continue
+		}
+		if rank <= roleSpecialist {
+			delete(minRoleByType, typ)
+		} else {
+			minRoleByType[typ] = rank
+		}
+		added++
+	}
+	fmt.Printf("✅ Требований к должности из %s: %d (всего %d)\n", fp, added, len(minRoleByType))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ПАРАЛЛЕЛЬНЫЙ РОУТИНГ — пул воркеров, вывод в порядке входа (-route-workers)
+// ═══════════════════════════════════════════════════════════
+
+// routedTicket — тикет после роутинга: готов к записи в CSV и БД
+type routedTicket struct {
+	T  TicketInput
+	AI AIResult
+	R  RoutingResult
+}
+
+// routeTicketsOrdered — route для каждого тикета в workers горутинах, emit —
+// строго в порядке tickets и только из вызывающей горутины (CSV пишется
+// последовательно). Готовые раньше очереди результаты ждут в буфере.
+// При отмене ctx новые тикеты не берутся; возвращает, сколько выдано в emit
+// (непрерывный префикс — тикеты после разрыва не пишутся и обработаются
+// при следующем запуске).
+func routeTicketsOrdered(ctx context.Context, tickets []TicketInput, workers int,
+	route func(t TicketInput) routedTicket, emit func(rt routedTicket)) int {
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	done := make(chan int, workers)
+	slots := make([]routedTicket, len(tickets)) // slots[i] пишет воркер до отправки i в done
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				slots[i] = route(tickets[i])
+				done <- i
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range tickets {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ready := make([]bool, len(tickets))
+	next := 0
+	for i := range done {
+		ready[i] = true
+		for next < len(tickets) && ready[next] {
+			emit(slots[next])
+			slots[next] = routedTicket{}
+			next++
+		}
+	}
+	return next
+}
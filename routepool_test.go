@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// routeBenchTickets — тикетов в одном прогоне routeTicketsOrdered
+const routeBenchTickets = 1000
+
+// routeBenchInput — синтетические тикеты и ответы AI по офисам движка:
+// сегменты, типы и языки чередуются, чтобы работали все фильтры каскада
+func routeBenchInput(e *Engine, n int) ([]TicketInput, map[int]AIResult) {
+	segments := []string{"Mass", "VIP", "Priority", "Mass"}
+	types := []string{"Консультация", "Жалоба", "Смена данных", "Претензия", "Неработоспособность приложения"}
+	languages := []string{"RU", "KZ", "ENG"}
+	texts := []string{
+		"Подскажите, как пополнить брокерский счёт с карты другого банка?",
+		"Третий день не могу вывести деньги, поддержка не отвечает.",
+		"Прошу сменить номер телефона в профиле.",
+		"Приложение не открывается после обновления.",
+	}
+	tickets := make([]TicketInput, n)
+	results := make(map[int]AIResult, n)
+	for i := range tickets {
+		office := e.offices[i%len(e.offices)]
+		tickets[i] = TicketInput{
+			Index:   i,
+			GUID:    "bench-" + strconv.Itoa(i),
+			Text:    texts[i%len(texts)],
+			Segment: segments[i%len(segments)],
+			Country: "Казахстан",
+			RawCity: office,
+		}
+		results[i] = AIResult{
+			Type:          types[i%len(types)],
+			Sentiment:     "Нейтральный",
+			Language:      languages[i%len(languages)],
+			Priority:      strconv.Itoa(i%10 + 1),
+			ModelPriority: strconv.Itoa(i%10 + 1),
+			NearestOffice: office,
+			GeoMethod:     "nominatim",
+			Source:        "Gemini",
+			Confidence:    1,
+		}
+	}
+	return tickets, results
+}
+
+// quietStdout — служебный вывод роутинга (📍, 👤 ...) на время замера в /dev/null
+func quietStdout(tb testing.TB) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	tb.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
+
+// benchmarkRoute — фаза роутинга processAllTickets (сверка типа, resolveType,
+// buildRoutingResult) через routeTicketsOrdered с workers воркерами
+func benchmarkRoute(b *testing.B, workers int) {
+	e := benchEngine(len(defaultOfficeCoords), 20)
+	tickets, results := routeBenchInput(e, routeBenchTickets)
+	route := func(t TicketInput) routedTicket {
+		ai := results[t.Index]
+		fbType, disagree := crossCheckAI(t, ai)
+		ai = resolveType(t, ai, fbType, disagree)
+		return routedTicket{T: t, AI: ai, R: e.buildRoutingResult(t, ai)}
+	}
+	written := 0
+	emit := func(routedTicket) { written++ }
+
+	quietStdout(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		written = 0
+		if n := routeTicketsOrdered(context.Background(), tickets, workers, route, emit); n != len(tickets) || written != n {
+			b.Fatalf("выдано %d (emit %d) из %d тикетов", n, written, len(tickets))
+		}
+	}
+}
+
+func BenchmarkRouteSequential(b *testing.B) {
+	benchmarkRoute(b, 1)
+}
+
+func BenchmarkRouteParallel(b *testing.B) {
+	benchmarkRoute(b, runtime.GOMAXPROCS(0))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ЗАПУСК — run_id и итоговая статистика в run_stats
+// ═══════════════════════════════════════════════════════════
+
+// runID — идентификатор запуска процесса (UUID v4), задаётся в main
+var runID string
+
+// newRunID — случайный UUID v4; без источника случайности — метка времени
+func newRunID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000Z")
+	}
+	b[6] = b[6]&0x0f | 0x40 // версия 4
+	b[8] = b[8]&0x3f | 0x80 // вариант RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// saveRunStats — итоги запуска (те же, что печатает printSummary) строкой в
+// run_stats. С -watch каждый цикл — отдельная строка с тем же run_id.
+func saveRunStats(ctx context.Context, conn *sql.DB, st *summaryStats) error {
+	byType, err := json.Marshal(st.Types)
+	if err != nil {
+		return err
+	}
+	bySentiment, err := json.Marshal(st.Sentiments)
+	if err != nil {
+		return err
+	}
+	byOffice, err := json.Marshal(st.Offices)
+	if err != nil {
+		return err
+	}
+	byPriority, err := json.Marshal(priorityCounts(st.Priorities))
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO run_stats (run_id, total, spam, escalated, no_manager,
+		                       by_type, by_sentiment, by_office, by_priority)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)`,
+		runID, st.Total, st.Spam, st.Escalated, st.NoManager,
+		string(byType), string(bySentiment), string(byOffice), string(byPriority))
+	if err == nil {
+		fmt.Printf("🗃  Статистика запуска %s → run_stats\n", runID)
+	}
+	return err
+}
+
+// priorityCounts — гистограмма приоритетов для JSON: "1".."10" → тикетов
+// (все десять ключей, в том числе нулевые)
+func priorityCounts(counts [11]int) map[string]int {
+	m := make(map[string]int, 10)
+	for p := 1; p <= 10; p++ {
+		m[strconv.Itoa(p)] = counts[p]
+	}
+	return m
+}
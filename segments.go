@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  СЕГМЕНТЫ — приоритет и навык менеджера по сегменту клиента
+// ═══════════════════════════════════════════════════════════
+
+// segmentRule — правило сегмента. Priority — принудительный приоритет после
+// AI и матрицы (0 — не меняется); Skill — навык, без которого менеджер не
+// подходит ("" — любой менеджер).
+type segmentRule struct {
+	Priority int
+	Skill    string
+}
+
+// segmentRules — сегмент → правило. Встроенные VIP/Priority → 10 и навык VIP;
+// data/segment_rules.csv дополняет и переопределяет их построчно.
+var segmentRules = map[string]segmentRule{
+	"VIP":      {Priority: 10, Skill: "VIP"},
+	"Priority": {Priority: 10, Skill: "VIP"},
+}
+
+// segmentRuleFor — правило сегмента тикета (без учёта пробелов по краям)
+func segmentRuleFor(segment string) segmentRule {
+	return segmentRules[strings.TrimSpace(segment)]
+}
+
+// requiredSkill — навык, обязательный для менеджера тикета этого сегмента
+func requiredSkill(segment string) string {
+	return segmentRuleFor(segment).Skill
+}
+
+// hasSkill — есть ли у менеджера навык
+func (m *Manager) hasSkill(skill string) bool {
+	for _, s := range m.Skills {
+		if strings.TrimSpace(s) == skill {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSegmentRules — правила из CSV (Сегмент,Приоритет,Навык). Файл
+// необязателен; пустой приоритет или 0 — приоритет не меняется, пустой
+// навык — без требования. Строка с 0 и пустым навыком отключает встроенное
+// правило сегмента.
+func loadSegmentRules(fp string) {
+	file, err := os.Open(fp)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	records, err := readCSV(file)
+	if err != nil {
+		fmt.Printf("⚠️ Ошибка чтения %s: %v\n", fp, err)
+		return
+	}
+	added := 0
+	for i, row := range records {
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+		segment := strings.TrimSpace(row[0])
+		p := 0
+		if v := strings.TrimSpace(row[1]); v != "" {
+			p, err = strconv.Atoi(v)
+		}
+		if segment == "" || err != nil || p < 0 || p > 10 {
+			fmt.Printf("⚠️ %s: пропущена строка %d (%v)\n", fp, i+1, row)
+			continue
+		}
+		rule := segmentRule{Priority: p}
+		if len(row) > 2 {
+			rule.Skill = strings.TrimSpace(row[2])
+		}
+		if rule == (segmentRule{}) {
+			delete(segmentRules, segment)
+		} else {
+			segmentRules[segment] = rule
+		}
+		added++
+	}
+	fmt.Printf("✅ Правил сегментов из %s: %d (всего %d)\n", fp, added, len(segmentRules))
+}
+
+// applySegmentPriority — бизнес-правило: приоритет сегмента (VIP/Priority → 10)
+// заменяет приоритет от AI и матрицы
+func applySegmentPriority(t TicketInput, r AIResult) AIResult {
+	rule := segmentRuleFor(t.Segment)
+	if rule.Priority == 0 {
+		return r
+	}
+	if p := strconv.Itoa(rule.Priority); r.Priority != p {
+		fmt.Printf("   👑 %s | Сегмент %s → приоритет %s (было %s)\n",
+			t.GUID[:min(8, len(t.GUID))], t.Segment, p, r.Priority)
+		r.Priority = p
+	}
+	return r
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  HTTP-СЕРВЕР — режим -serve
+// ═══════════════════════════════════════════════════════════
+
+// resultRow — строка v_full_results в JSON-ответе
+type resultRow struct {
+	GUID           string    `json:"guid"`
+	Segment        string    `json:"segment"`
+	City           string    `json:"city"`
+	Type           string    `json:"type"`
+	Sentiment      string    `json:"sentiment"`
+	Language       string    `json:"language"`
+	Priority       *int      `json:"priority"`
+	Summary        string    `json:"summary"`
+	GeoLat         float64   `json:"geo_lat"`
+	GeoLon         float64   `json:"geo_lon"`
+	GeoMethod      string    `json:"geo_method"`
+	Source         string    `json:"source"`
+	ManagerName    string    `json:"manager_name"`
+	ManagerRole    string    `json:"manager_role"`
+	AssignedOffice string    `json:"assigned_office"`
+	RoutingReason  string    `json:"routing_reason"`
+	IsEscalated    bool      `json:"is_escalated"`
+	RoutedAt       time.Time `json:"routed_at"`
+	DistanceKm     *float64  `json:"distance_km"`
+	AltOffices     string    `json:"alt_offices"`
+	Tenant         string    `json:"tenant_id"`
+	GeoPrecision   string    `json:"geo_precision"`
+
+	PriorityCategory string     `json:"priority_category,omitempty"`
+	SpamDomain       string     `json:"spam_domain,omitempty"`
+	DueAt            *time.Time `json:"due_at,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// handleResults — GET /results?tenant=&office=&type=&geo_precision=&priority_min=&priority_max=&escalated=&run_id=&sort=&limit=
+// geo_precision — через запятую (city,region — точки по центру города/области)
+func handleResults(e *Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "только GET")
+			return
+		}
+		if e.db == nil {
+			writeError(w, http.StatusServiceUnavailable, "БД не подключена")
+			return
+		}
+
+		q := r.URL.Query()
+		var where []string
+		var args []any
+		addArg := func(cond string, v any) {
+			args = append(args, v)
+			where = append(where, fmt.Sprintf(cond, len(args)))
+		}
+
+		if v := q.Get("tenant"); v != "" {
+			addArg("tenant_id = $%d", v)
+		}
+		if v := q.Get("office"); v != "" {
+			addArg("assigned_office = $%d", v)
+		}
+		if v := q.Get("type"); v != "" {
+			addArg("type = $%d", v)
+		}
+		if v := q.Get("run_id"); v != "" {
+			addArg("run_id = $%d", v)
+		}
+		if v := q.Get("geo_precision"); v != "" {
+			addArg("geo_precision = ANY(string_to_array($%d, ','))", v)
+		}
+		for _, pc := range [][2]string{
+			{"priority_min", "priority >= $%d"},
+			{"priority_max", "priority <= $%d"},
+		} {
+			param, cond := pc[0], pc[1]
+			if v := q.Get(param); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, param+" должен быть числом")
+					return
+				}
+				addArg(cond, n)
+			}
+		}
+		if v := q.Get("escalated"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "escalated должен быть true/false")
+				return
+			}
+			addArg("is_escalated = $%d", b)
+		}
+		limit := 1000
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				writeError(w, http.StatusBadRequest, "limit должен быть положительным числом")
+				return
+			}
+			limit = n
+		}
+
+		query := `SELECT guid, COALESCE(segment,''), COALESCE(city,''), COALESCE(type,''),
+		COALESCE(sentiment,''), COALESCE(language,''), priority, COALESCE(summary,''),
+		COALESCE(geo_lat,0), COALESCE(geo_lon,0), COALESCE(geo_method,''), COALESCE(source,''),
+		COALESCE(manager_name,''), COALESCE(manager_role,''), COALESCE(assigned_office,''),
+		COALESCE(routing_reason,''), COALESCE(is_escalated,false), routed_at, distance_km,
+		COALESCE(alt_offices,''), tenant_id, COALESCE(geo_precision,''), COALESCE(priority_category,''), COALESCE(spam_domain,''),
+		due_at
+		FROM v_full_results`
+		if len(where) > 0 {
+			query += " WHERE " + strings.Join(where, " AND ")
+		}
+		order := "priority DESC NULLS LAST, guid"
+		switch q.Get("sort") {
+		case "", "priority":
+		case "due":
+			order = "due_at NULLS LAST, priority DESC NULLS LAST, guid"
+		default:
+			writeError(w, http.StatusBadRequest, "sort: priority или due")
+			return
+		}
+		args = append(args, limit)
+		query += fmt.Sprintf(" ORDER BY %s LIMIT $%d", order, len(args))
+
+		rows, err := e.db.QueryContext(r.Context(), query, args...)
+		if err != nil {
+			log.Printf("⚠️ /results: %v", err)
+			writeError(w, http.StatusInternalServerError, "ошибка запроса к БД")
+			return
+		}
+		defer rows.Close()
+
+		results := []resultRow{}
+		for rows.Next() {
+			var row resultRow
+			if err := rows.Scan(&row.GUID, &row.Segment, &row.City, &row.Type, &row.Sentiment,
+				&row.Language, &row.Priority, &row.Summary, &row.GeoLat, &row.GeoLon,
+				&row.GeoMethod, &row.Source, &row.ManagerName, &row.ManagerRole,
+				&row.AssignedOffice, &row.RoutingReason, &row.IsEscalated, &row.RoutedAt, &row.DistanceKm,
+				&row.AltOffices, &row.Tenant, &row.GeoPrecision, &row.PriorityCategory, &row.SpamDomain,
+				&row.DueAt); err != nil {
+				log.Printf("⚠️ /results scan: %v", err)
+				writeError(w, http.StatusInternalServerError, "ошибка чтения строки")
+				return
+			}
+			results = append(results, row)
+		}
+		writeJSON(w, http.StatusOK, results)
+	}
+}
+
+// routeSingleTicket — полный конвейер для одного тикета:
+// AI (батч из одного) → VIP-правило → геокодирование → роутинг.
+func (e *Engine) routeSingleTicket(ctx context.Context, t TicketInput, keys *apiKeyPool) (AIResult, RoutingResult) {
+	t.Index = 0
+	one := []TicketInput{t}
+	applyRemoteOCR(ctx, one)
+	t = one[0]
+	ai, ok := spamPrefilter(t)
+	if ok {
+		aiUsage.AddPrefiltered(1)
+	} else if results, err := e.analyzeBatchWithRetry(ctx, []TicketInput{t}, keys, 1); err != nil {
+		fmt.Printf("⚠️ AI для %s: %v → Keyword Fallback\n", t.GUID, err)
+	} else {
+		ai, ok = results[t.Index]
+	}
+	if !ok {
+		ai = fallbackAnalyze(t)
+	}
+	ai = applyPriorityRules(t, applyLinkSpam(t, ai))
+
+	office, lat, lon, method, oblast, precision := e.resolveOfficeForTicket(ctx, t, ai.NearestOffice)
+	ai.GeoLat, ai.GeoLon, ai.GeoMethod = lat, lon, method
+	ai.DerivedOblast, ai.GeoPrecision = oblast, precision
+	if office != "" || method == "too_far" {
+		ai.NearestOffice = office
+	}
+
+	fbType, disagree := crossCheckAI(t, ai)
+	ai = resolveType(t, ai, fbType, disagree)
+	result := e.buildRoutingResult(t, ai)
+	result.NeedsReview = disagree
+	result.ReviewReason = reviewReason(result)
+	return ai, result
+}
+
+// handleRoute — POST /route: один TicketInput в JSON → RoutingResult
+// (офисы и менеджеры — тенанта из tenant_id)
+func handleRoute(e *Engine, keys *apiKeyPool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "только POST")
+			return
+		}
+		var t TicketInput
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			writeError(w, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+			return
+		}
+		t.GUID = strings.TrimSpace(t.GUID)
+		if t.GUID == "" {
+			writeError(w, http.StatusBadRequest, "поле guid обязательно")
+			return
+		}
+		if strings.TrimSpace(t.Text) == "" && strings.TrimSpace(t.Attachment) == "" {
+			writeError(w, http.StatusBadRequest, "нет текста и вложения")
+			return
+		}
+		te, ok := e.forTenant(t.Tenant)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "неизвестный tenant_id: "+t.Tenant)
+			return
+		}
+		if err := fillAge(&t); err != nil {
+			log.Printf("⚠️ /route %s: дата рождения: %v — возраст не учитывается", t.GUID, err)
+		}
+		if err := fillCreatedAt(&t); err != nil {
+			log.Printf("⚠️ /route %s: дата обращения: %v — в БД время обработки", t.GUID, err)
+		}
+
+		ai, result := te.routeSingleTicket(r.Context(), t, keys)
+		saveAllAsync(r.Context(), te.db, t, ai, result)
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// runServer — блокирующий запуск HTTP API до отмены ctx
+func runServer(ctx context.Context, e *Engine, addr string, keys *apiKeyPool) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/results", handleResults(e))
+	mux.HandleFunc("/route", handleRoute(e, keys))
+	mux.HandleFunc("/review", handleReview(e))
+	mux.HandleFunc("/reassign", handleReassign(e))
+	mux.Handle("/metrics", promhttp.Handler())
+
+	fmt.Printf("🌐 HTTP API слушает %s (GET /results, POST /route, POST /review, POST /reassign, GET /metrics)\n", addr)
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	dbWg.Wait()
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  SLA — срок ответа (DueAt) по приоритету от даты обращения
+// ═══════════════════════════════════════════════════════════
+
+// slaNone — правило «без срока» (в таблице SLA — none или 0)
+const slaNone time.Duration = -1
+
+// slaByPriority — срок ответа по приоритету (SLA_HOURS). Для приоритета без
+// своей строки берётся ближайший указанный ниже.
+var slaByPriority = map[int]time.Duration{
+	10: 1 * time.Hour,
+	9:  2 * time.Hour,
+	8:  4 * time.Hour,
+	7:  6 * time.Hour,
+	6:  8 * time.Hour,
+	5:  24 * time.Hour,
+	4:  24 * time.Hour,
+	3:  48 * time.Hour,
+	2:  72 * time.Hour,
+	1:  slaNone,
+}
+
+// Отдельные правила: эскалированные в ГО (SLA_ESCALATED; 0 — по таблице
+// приоритетов) и спам (SLA_SPAM; по умолчанию без срока)
+var (
+	slaEscalated time.Duration
+	slaSpam      = slaNone
+)
+
+// parseSLADuration — «8h», «90m», «none»/«0» → slaNone
+func parseSLADuration(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "none" || s == "0" || s == "-" {
+		return slaNone, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("срок %q: ожидается длительность (8h, 90m) или none", s)
+	}
+	return d, nil
+}
+
+// parsePriorityDurations — таблица «приоритет=длительность» через запятую
+// (10=1h,6=8h,1=none) из переменной name
+func parsePriorityDurations(name, v string) (map[int]time.Duration, error) {
+	table := make(map[int]time.Duration)
+	for _, part := range strings.Split(v, ",") {
+		p, dur, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if !ok || err != nil || n < 1 || n > 10 {
+			return nil, fmt.Errorf("%s=%q: ожидается приоритет=срок через запятую (10=1h,6=8h,1=none)", name, v)
+		}
+		d, err := parseSLADuration(dur)
+		if err != nil {
+			return nil, fmt.Errorf("%s, приоритет %d: %v", name, n, err)
+		}
+		table[n] = d
+	}
+	return table, nil
+}
+
+// durationForPriority — значение таблицы для приоритета; без своей строки —
+// ближайший указанный ниже; slaNone — нет ни одного
+func durationForPriority(table map[int]time.Duration, priority string) time.Duration {
+	for p := priorityNum(priority); p >= 1; p-- {
+		if d, ok := table[p]; ok {
+			return d
+		}
+	}
+	return slaNone
+}
+
+// loadSLAConfig — SLA_HOURS="10=1h,6=8h,1=none" заменяет таблицу целиком;
+// SLA_ESCALATED, SLA_SPAM — длительность или none
+func loadSLAConfig() error {
+	if v := getEnv("SLA_HOURS", ""); v != "" {
+		table, err := parsePriorityDurations("SLA_HOURS", v)
+		if err != nil {
+			return err
+		}
+		slaByPriority = table
+	}
+	if v := getEnv("SLA_ESCALATED", ""); v != "" {
+		d, err := parseSLADuration(v)
+		if err != nil {
+			return fmt.Errorf("SLA_ESCALATED: %v", err)
+		}
+		slaEscalated = d
+	}
+	if v := getEnv("SLA_SPAM", ""); v != "" {
+		d, err := parseSLADuration(v)
+		if err != nil {
+			return fmt.Errorf("SLA_SPAM: %v", err)
+		}
+		slaSpam = d
+	}
+	return nil
+}
+
+// slaFor — срок ответа по итогу роутинга; slaNone — срока нет
+func slaFor(r RoutingResult) time.Duration {
+	switch {
+	case r.Type == "Спам":
+		return slaSpam
+	case r.IsEscalated && slaEscalated != 0:
+		return slaEscalated
+	}
+	return durationForPriority(slaByPriority, r.Priority)
+}
+
+// dueAt — крайний срок ответа: дата обращения (без неё — время обработки)
+// плюс срок по SLA; нулевое время — срока нет
+func dueAt(t TicketInput, r RoutingResult) time.Time {
+	d := slaFor(r)
+	if d == slaNone {
+		return time.Time{}
+	}
+	from := t.CreatedTime
+	if from.IsZero() {
+		from = time.Now()
+	}
+	return from.Add(d)
+}
+
+// formatDueAt — Срок_SLA в CSV/JSON (RFC 3339); "" — срока нет
+func formatDueAt(due time.Time) string {
+	if due.IsZero() {
+		return ""
+	}
+	return due.Format(time.RFC3339)
+}
+
+// dueAtToDB — routing_results.due_at: пусто → NULL
+func dueAtToDB(due string) any {
+	if due == "" {
+		return nil
+	}
+	return due
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ССЫЛКИ В ОБРАЩЕНИИ — домены, блок-лист, эвристика спама
+// ═══════════════════════════════════════════════════════════
+
+var urlRe = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"'«»()\[\]]+`)
+
+// spamDomains — домены рассылок; поддомены тоже (mail.enkod.ru).
+// Дополняется переменной SPAM_DOMAINS через запятую.
+var spamDomains = []string{
+	"enkod.ru",
+	"enkod.io",
+}
+
+// marketingTLDs — зоны, которые в обращениях клиентов встречаются почти только в рекламе
+var marketingTLDs = map[string]bool{
+	"click": true, "top": true, "xyz": true, "shop": true, "store": true,
+	"promo": true, "sale": true, "buzz": true, "link": true,
+}
+
+// ownDomains — наши домены: не считаются рекламными ни в ссылках, ни в
+// отправителе. Дополняется переменной SPAM_ALLOW_DOMAINS через запятую.
+var ownDomains = []string{
+	"ffin.kz",
+}
+
+// spamLinkThreshold — столько и больше ссылок в одном обращении — рассылка
+const spamLinkThreshold = 5
+
+// sourcePrefilter — AI_Источник тикета, признанного спамом до AI (spamPrefilter)
+const sourcePrefilter = "Prefilter"
+
+// senderRe — строка отправителя пересланного письма (From:/От:/Отправитель:/Sender:) → домен адреса
+var senderRe = regexp.MustCompile(`(?im)^[\s>]*(?:from|sender|от|отправитель)\s*:[^\n@]*?[\w.+-]+@([\w-]+(?:\.[\w-]+)+)`)
+
+func loadSpamDomains() {
+	for _, d := range strings.Split(os.Getenv("SPAM_DOMAINS"), ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			spamDomains = append(spamDomains, d)
+		}
+	}
+	for _, d := range strings.Split(os.Getenv("SPAM_ALLOW_DOMAINS"), ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			ownDomains = append(ownDomains, d)
+		}
+	}
+}
+
+// domainIn — домен или его поддомен есть в списке
+func domainIn(d string, list []string) bool {
+	for _, b := range list {
+		if d == b || strings.HasSuffix(d, "."+b) {
+			return true
+		}
+	}
+	return false
+}
+
+// spamDomainReason — почему домен рекламный; "" — нет (или это наш домен)
+func spamDomainReason(d string) string {
+	if domainIn(d, ownDomains) {
+		return ""
+	}
+	if domainIn(d, spamDomains) {
+		return "домен из блок-листа: " + d
+	}
+	if tld := d[strings.LastIndex(d, ".")+1:]; marketingTLDs[tld] {
+		return "рекламная зона ." + tld + ": " + d
+	}
+	return ""
+}
+
+// extractLinks — все ссылки из текста (http(s):// и www.)
+func extractLinks(text string) []string {
+	return urlRe.FindAllString(text, -1)
+}
+
+// linkDomain — хост ссылки в нижнем регистре, без www. и порта
+func linkDomain(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(strings.TrimRight(link, ".,;:!?"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// extractDomains — уникальные домены ссылок в порядке появления
+func extractDomains(links []string) []string {
+	seen := make(map[string]bool)
+	var domains []string
+	for _, l := range links {
+		if d := linkDomain(l); d != "" && !seen[d] {
+			seen[d] = true
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// blockedLinkDomain — первый домен ссылок из блок-листа рассылок; "" — нет
+func blockedLinkDomain(domains []string) string {
+	for _, d := range domains {
+		if !domainIn(d, ownDomains) && domainIn(d, spamDomains) {
+			return d
+		}
+	}
+	return ""
+}
+
+// suspiciousLinkReason — эвристика без блок-листа: рекламная зона или много
+// ссылок; domain — домен рекламной зоны ("" — сработало число ссылок)
+func suspiciousLinkReason(links, domains []string) (reason, domain string) {
+	for _, d := range domains {
+		if reason := spamDomainReason(d); reason != "" {
+			return reason, d
+		}
+	}
+	if len(links) >= spamLinkThreshold {
+		return fmt.Sprintf("%d ссылок в обращении", len(links)), ""
+	}
+	return "", ""
+}
+
+// keepsTypeOnSpamLink — тип, который ссылки не понижают до спама: клиент
+// мог прислать фишинговую ссылку как доказательство
+func keepsTypeOnSpamLink(t TicketInput, r AIResult) bool {
+	return r.Type == "Мошеннические действия" || r.Type == "Претензия" ||
+		containsAny(t.Text+" "+t.Attachment+" "+t.OCRText, fraudKeywords...)
+}
+
+// applyLinkSpam — домены ссылок в r.LinkDomains. Домен из блок-листа —
+// принудительно Спам (и для AI, и для Keyword Fallback), кроме мошенничества
+// и претензий (keepsTypeOnSpamLink). Рекламная зона и число ссылок тип не
+// меняют: только Домен_спама и строка в логе для аудита.
+func applyLinkSpam(t TicketInput, r AIResult) AIResult {
+	links := extractLinks(t.Text + " " + t.Attachment)
+	if len(links) == 0 {
+		return r
+	}
+	domains := extractDomains(links)
+	r.LinkDomains = strings.Join(domains, ", ")
+	short := t.GUID[:min(8, len(t.GUID))]
+
+	if d := blockedLinkDomain(domains); d != "" {
+		if r.SpamDomain == "" {
+			r.SpamDomain = d
+		}
+		switch {
+		case r.Type == "Спам":
+		case keepsTypeOnSpamLink(t, r):
+			fmt.Printf("   🔗 %s | домен из блок-листа: %s, но тип '%s' не меняется\n", short, d, r.Type)
+		default:
+			fmt.Printf("   🔗 %s | домен из блок-листа: %s → Спам (было '%s')\n", short, d, r.Type)
+			r.Type = "Спам"
+			r.Priority = "1"
+			r.Sentiment = "Нейтральный"
+		}
+		return r
+	}
+	if reason, d := suspiciousLinkReason(links, domains); reason != "" && r.Type != "Спам" {
+		if r.SpamDomain == "" {
+			r.SpamDomain = d
+		}
+		fmt.Printf("   🔗 %s | похоже на рассылку (%s), тип '%s' не меняется\n", short, reason, r.Type)
+	}
+	return r
+}
+
+// senderDomain — домен отправителя из строки From:/От: в тексте; "" — не указан
+func senderDomain(text string) string {
+	if m := senderRe.FindStringSubmatch(text); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// spamPrefilterMinLinks — меньше рекламных ссылок — решает AI, а не префильтр
+const spamPrefilterMinLinks = 2
+
+// spamPrefilter — спам без запроса к AI (-spam-prefilter): домен отправителя
+// рекламный, или рекламных ссылок не меньше spamPrefilterMinLinks и больше
+// половины (наши домены не считаются). Тикет со словами о мошенничестве
+// (fraudKeywords) — всегда в AI: клиент мог переслать фишинговое письмо.
+// Возвращает готовый результат с совпавшим доменом.
+func spamPrefilter(t TicketInput) (AIResult, bool) {
+	text := t.Text + " " + t.Attachment + " " + t.OCRText
+	if !*prefilterMode || containsAny(text, fraudKeywords...) {
+		return AIResult{}, false
+	}
+	domain, reason := senderDomain(text), ""
+	if domain != "" {
+		if reason = spamDomainReason(domain); reason != "" {
+			reason = "отправитель, " + reason
+		}
+	}
+	if reason == "" {
+		counts := make(map[string]int)
+		total, spam := 0, 0
+		for _, l := range extractLinks(text) {
+			d := linkDomain(l)
+			if d == "" || domainIn(d, ownDomains) {
+				continue
+			}
+			total++
+			if spamDomainReason(d) != "" {
+				spam++
+				if counts[d]++; counts[d] > counts[domain] {
+					domain = d
+				}
+			}
+		}
+		if spam < spamPrefilterMinLinks || spam*2 <= total {
+			return AIResult{}, false
+		}
+		reason = fmt.Sprintf("%s (%d из %d ссылок)", spamDomainReason(domain), spam, total)
+	}
+
+	lang, alt := detectTextLanguage(strings.ToLower(text))
+	fmt.Printf("   🚫 %s | %s → Спам без AI\n", t.GUID[:min(8, len(t.GUID))], reason)
+	return AIResult{
+		Type:        "Спам",
+		Sentiment:   "Нейтральный",
+		Language:    lang,
+		AltLanguage: alt,
+		Priority:    "1",
+		Summary:     "Рассылка с домена " + domain + " — классифицирована без AI. Менеджер не назначается.",
+		Source:      sourcePrefilter,
+		SpamDomain:  domain,
+	}, true
+}
+
+// prefilterSpam — результаты spamPrefilter в results; возвращает тикеты,
+// которые по-прежнему нужно отправить в AI
+func prefilterSpam(tickets []TicketInput, results map[int]AIResult) []TicketInput {
+	rest := tickets[:0:0]
+	for _, t := range tickets {
+		if r, ok := spamPrefilter(t); ok {
+			results[t.Index] = r
+			continue
+		}
+		rest = append(rest, t)
+	}
+	if n := len(tickets) - len(rest); n > 0 {
+		fmt.Printf("🚫 Спам по доменам: %d тикетов без AI, в AI уходит %d из %d\n", n, len(rest), len(tickets))
+		aiUsage.AddPrefiltered(n)
+	}
+	return rest
+}
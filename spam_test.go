@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+// TestApplyLinkSpam — спамом делает только блок-лист, и то не мошенничество
+// и претензии; рекламные зоны и число ссылок лишь отмечают домен для аудита
+func TestApplyLinkSpam(t *testing.T) {
+	quietStdout(t)
+	ai := func(typ string) AIResult {
+		return AIResult{Type: typ, Sentiment: "Негативный", Priority: "8"}
+	}
+	for _, c := range []struct {
+		name, text, aiType string
+		wantType, domain   string
+	}{
+		{"без ссылок", "Не могу войти в приложение", "Неработоспособность приложения", "Неработоспособность приложения", ""},
+		{"блок-лист", "Скидки! https://mail.enkod.ru/promo", "Консультация", "Спам", "mail.enkod.ru"},
+		{"блок-лист у мошенничества", "Пришло письмо https://enkod.ru/x", "Мошеннические действия", "Мошеннические действия", "enkod.ru"},
+		{"блок-лист у претензии", "Подам в суд, вот ваша рассылка https://enkod.ru/x", "Претензия", "Претензия", "enkod.ru"},
+		{"блок-лист со словами о мошенничестве", "Мошенники прислали https://enkod.ru/x", "Жалоба", "Жалоба", "enkod.ru"},
+		{"рекламная зона", "Перешёл по ссылке https://bonus.xyz/login и украли деньги", "Мошеннические действия", "Мошеннические действия", "bonus.xyz"},
+		{"рекламная зона у консультации", "Что это за сайт https://bonus.top?", "Консультация", "Консультация", "bonus.top"},
+		{"много ссылок", "https://a.kz https://b.kz https://c.kz https://d.kz https://e.kz", "Консультация", "Консультация", ""},
+		{"наш домен", "Личный кабинет https://enkod.ru.ffin.kz", "Консультация", "Консультация", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := applyLinkSpam(TicketInput{GUID: "g", Text: c.text}, ai(c.aiType))
+			if r.Type != c.wantType || r.SpamDomain != c.domain {
+				t.Errorf("тип %q, домен %q; ожидалось %q, %q", r.Type, r.SpamDomain, c.wantType, c.domain)
+			}
+			if r.Type != "Спам" && r.Priority != "8" {
+				t.Errorf("приоритет %s изменён без смены типа", r.Priority)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ЯЗЫК SUMMARY — проверка после AI и точечный повторный запрос
+// ═══════════════════════════════════════════════════════════
+
+// kazLetters — буквы казахского алфавита, которых нет в русском
+const kazLetters = "әғқңөұүһі"
+
+// summaryLanguage — язык текста summary: латиница преобладает → ENG,
+// казахские буквы или слова-маркеры → KZ, иначе RU. "" — слишком мало букв,
+// чтобы судить (короткие summary не перепроверяются).
+func summaryLanguage(s string) string {
+	lower := strings.ToLower(s)
+	cyr, lat, kaz := 0, 0, 0
+	for _, r := range lower {
+		switch {
+		case strings.ContainsRune(kazLetters, r):
+			kaz++
+			cyr++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyr++
+		case unicode.Is(unicode.Latin, r):
+			lat++
+		}
+	}
+	if cyr+lat < 10 {
+		return ""
+	}
+	if lat > cyr {
+		return "ENG"
+	}
+	if lang, _ := detectTextLanguage(lower); kaz >= 2 || lang == "KZ" {
+		return "KZ"
+	}
+	return "RU"
+}
+
+// fixSummaryLanguages — summary, написанные не на языке тикета (language),
+// запрашиваются повторно одним запросом только для этих тикетов. Новый
+// summary принимается, если он на нужном языке; иначе остаётся прежний.
+// Ошибка повторного запроса не роняет батч — только логируется.
+func (e *Engine) fixSummaryLanguages(ctx context.Context, tickets []TicketInput, results map[int]AIResult, apiKey string) {
+	var mismatched []TicketInput
+	for _, t := range tickets {
+		r, ok := results[t.Index]
+		if !ok || !knownLanguages[r.Language] {
+			continue
+		}
+		if got := summaryLanguage(r.Summary); got != "" && got != r.Language {
+			mismatched = append(mismatched, t)
+		}
+	}
+	if len(mismatched) == 0 {
+		return
+	}
+	fmt.Printf("   🌐 Язык summary не совпал с language: %d из %d → повторный запрос summary\n",
+		len(mismatched), len(results))
+
+	summaries, err := requestSummaries(ctx, e.analyzer, mismatched, results, apiKey)
+	if err != nil {
+		fmt.Printf("   ⚠️ Повторный запрос summary не удался: %v — остаются прежние\n", err)
+		return
+	}
+	fixed := 0
+	for _, t := range mismatched {
+		r := results[t.Index]
+		s, ok := summaries[t.Index]
+		if !ok || summaryLanguage(s) != r.Language {
+			continue
+		}
+		r.Summary = s
+		results[t.Index] = r
+		fixed++
+	}
+	fmt.Printf("   🌐 Summary на языке тикета: исправлено %d из %d\n", fixed, len(mismatched))
+}
+
+// requestSummaries — только summary на языке language для тикетов tickets
+func requestSummaries(ctx context.Context, a Analyzer, tickets []TicketInput, results map[int]AIResult, apiKey string) (map[int]string, error) {
+	type summaryForPrompt struct {
+		Index    int    `json:"i"`
+		Language string `json:"language"`
+		Text     string `json:"text"`
+		Summary  string `json:"summary"`
+	}
+	var items []summaryForPrompt
+	for _, t := range tickets {
+		text := t.Text
+		if len(text) > 700 {
+			text = text[:700] + "..."
+		}
+		items = append(items, summaryForPrompt{t.Index, results[t.Index].Language, text, results[t.Index].Summary})
+	}
+	ticketsJSON, _ := json.Marshal(items)
+
+	prompt := fmt.Sprintf(`Ты — аналитик клиентских обращений Freedom Broker (Казахстан).
+У этих тикетов summary написан не на том языке. Перепиши summary заново по тексту обращения:
+1–2 предложения — суть обращения + рекомендация менеджеру, СТРОГО на языке из поля language:
+  — "KZ"  → ТОЛЬКО на казахском языке (қазақ тілінде)
+  — "ENG" → ONLY in English
+  — "RU"  → ТОЛЬКО на русском
+Верни ТОЛЬКО JSON-массив без markdown и пояснений:
+[{"i":<число>,"summary":"..."}]
+
+ТИКЕТЫ:
+%s`, string(ticketsJSON))
+
+	fmt.Printf("📤 Повторный запрос summary: %d тикетов → 1 запрос к %s...\n", len(tickets), a.Name())
+	raw, err := a.Generate(ctx, apiKey, prompt)
+	if err != nil {
+		return nil, err
+	}
+	if start, end := strings.Index(raw, "["), strings.LastIndex(raw, "]"); start >= 0 && end > start {
+		raw = raw[start : end+1]
+	}
+	var parsed []struct {
+		Index   int    `json:"i"`
+		Summary string `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, fmt.Errorf("парсинг JSON: %v", err)
+	}
+	summaries := make(map[int]string, len(parsed))
+	for _, it := range parsed {
+		if s := strings.TrimSpace(it.Summary); s != "" {
+			summaries[it.Index] = s
+		}
+	}
+	return summaries, nil
+}
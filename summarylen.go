@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ДЛИНА SUMMARY — обрезка по границе предложения
+// ═══════════════════════════════════════════════════════════
+
+// Пределы summary от AI (SUMMARY_MAX_SENTENCES, SUMMARY_MAX_CHARS; 0 — без предела).
+// Промпт просит 1–2 предложения, модель иногда пишет абзац.
+var (
+	summaryMaxSentences = 2
+	summaryMaxChars     = 300
+)
+
+// loadSummaryLimits — пределы длины summary из окружения
+func loadSummaryLimits() {
+	if n, err := strconv.Atoi(getEnv("SUMMARY_MAX_SENTENCES", "")); err == nil && n >= 0 {
+		summaryMaxSentences = n
+	}
+	if n, err := strconv.Atoi(getEnv("SUMMARY_MAX_CHARS", "")); err == nil && n >= 0 {
+		summaryMaxChars = n
+	}
+}
+
+// splitSentences — предложения с завершающими знаками (. ! ? …) перед пробелом
+// и не строчной буквой; пробелы обрезаны
+func splitSentences(s string) []string {
+	var sentences []string
+	runes := []rune(strings.TrimSpace(s))
+	start := 0
+	for i, r := range runes {
+		if !strings.ContainsRune(".!?…", r) {
+			continue
+		}
+		if i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			continue // «3.5», многоточие из точек — внутри предложения
+		}
+		if i+2 < len(runes) && unicode.IsLower(runes[i+2]) {
+			continue // «т.е. всё», «г. Алматы» — сокращение, предложение продолжается
+		}
+		if sent := strings.TrimSpace(string(runes[start : i+1])); sent != "" {
+			sentences = append(sentences, sent)
+		}
+		start = i + 1
+	}
+	if tail := strings.TrimSpace(string(runes[start:])); tail != "" {
+		sentences = append(sentences, tail)
+	}
+	return sentences
+}
+
+// truncateSummary — не больше maxSentences предложений и maxChars символов
+// (0 — без предела). Обрезается по границе предложения с «…» в конце; если
+// не помещается даже первое предложение — по границе слова. ok=false — не обрезано.
+func truncateSummary(s string, maxSentences, maxChars int) (string, bool) {
+	sentences := splitSentences(s)
+	fitsChars := func(n int) bool { return maxChars <= 0 || n <= maxChars }
+	if (maxSentences <= 0 || len(sentences) <= maxSentences) && fitsChars(len([]rune(strings.TrimSpace(s)))) {
+		return s, false
+	}
+
+	var kept string
+	for i, sent := range sentences {
+		if maxSentences > 0 && i >= maxSentences {
+			break
+		}
+		next := sent
+		if kept != "" {
+			next = kept + " " + sent
+		}
+		if !fitsChars(len([]rune(next)) + 1) { // +1 — «…»
+			break
+		}
+		kept = next
+	}
+	if kept == "" { // первое предложение длиннее maxChars — режем по слову
+		runes := []rune(sentences[0])
+		cut := string(runes[:maxChars-1])
+		if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > 0 {
+			cut = cut[:i]
+		}
+		kept = cut
+	}
+	return strings.TrimRight(kept, " .,;:") + "…", true
+}
+
+// limitSummaries — обрезает summary батча по пределам и логирует, сколько обрезано
+func limitSummaries(results map[int]AIResult) {
+	if summaryMaxSentences <= 0 && summaryMaxChars <= 0 {
+		return
+	}
+	truncated := 0
+	for idx, r := range results {
+		if s, ok := truncateSummary(r.Summary, summaryMaxSentences, summaryMaxChars); ok {
+			r.Summary = s
+			results[idx] = r
+			truncated++
+		}
+	}
+	if truncated > 0 {
+		fmt.Printf("   ✂️ Summary обрезано: %d из %d (предел: %d предл., %d симв.)\n",
+			truncated, len(results), summaryMaxSentences, summaryMaxChars)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ТЕНАНТЫ — свои офисы, менеджеры и ГО на каждого клиента движка
+// ═══════════════════════════════════════════════════════════
+
+// defaultTenant — тенант тикетов без tenant_id: офисы и менеджеры из
+// основных business_units.csv / managers.csv
+const defaultTenant = "default"
+
+// tenantID — пустой tenant_id → defaultTenant
+func tenantID(s string) string {
+	if s = strings.TrimSpace(s); s != "" {
+		return s
+	}
+	return defaultTenant
+}
+
+// tenantKey — ключ тикета «тенант|GUID»: один GUID может прийти от разных тенантов
+func tenantKey(tenant, guid string) string {
+	return tenantID(tenant) + "|" + guid
+}
+
+// LoadTenants — data/tenants.csv (Тенант,Офисы,Менеджеры,ГО): на каждую строку
+// отдельный движок со своими офисами, менеджерами, Round Robin и нагрузкой.
+// ГО — через запятую или «;» (пусто — встроенные HQ_CITIES). Алиасы городов
+// берутся из того же aliasesPath и сверяются с офисами тенанта. Файла нет —
+// работает только тенант default (e).
+func (e *Engine) LoadTenants(path, aliasesPath string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	records, err := readCSV(file)
+	if err != nil {
+		return fmt.Errorf("чтение %s: %v", path, err)
+	}
+	for i, row := range records {
+		if i == 0 || len(row) < 3 {
+			continue
+		}
+		id := strings.TrimSpace(row[0])
+		if id == "" {
+			continue
+		}
+		if id == e.tenant || e.tenants[id] != nil {
+			return fmt.Errorf("%s: тенант '%s' указан дважды", path, id)
+		}
+
+		fmt.Printf("\n🏢 Тенант '%s'\n", id)
+		te := NewEngine(e.geocoder, e.analyzer, e.db)
+		te.tenant = id
+		if err := te.LoadOffices(strings.TrimSpace(row[1])); err != nil {
+			return fmt.Errorf("тенант %s: офисы: %v", id, err)
+		}
+		if err := te.LoadManagers(strings.TrimSpace(row[2])); err != nil {
+			return fmt.Errorf("тенант %s: менеджеры: %v", id, err)
+		}
+		if len(row) > 3 {
+			var hq []string
+			for _, city := range strings.FieldsFunc(row[3], func(r rune) bool { return r == ',' || r == ';' }) {
+				office := te.normalizeOfficeName(city)
+				if office == "" {
+					return fmt.Errorf("тенант %s: ГО '%s' нет среди его офисов", id, strings.TrimSpace(city))
+				}
+				hq = append(hq, office)
+			}
+			if len(hq) > 0 {
+				te.hqCities = hq
+			}
+		}
+		te.LoadCityAliases(aliasesPath)
+		e.tenants[id] = te
+	}
+	if len(e.tenants) > 0 {
+		fmt.Printf("✅ Тенантов: %d + %s (из %s)\n", len(e.tenants), e.tenant, path)
+	}
+	return nil
+}
+
+// tenantOffice — офис с тенантом для сообщений («acme/Астана»); у default — без префикса
+func tenantOffice(tenant, office string) string {
+	if tenant == defaultTenant {
+		return office
+	}
+	return tenant + "/" + office
+}
+
+// forTenant — движок тенанта; ok=false — tenant_id не настроен
+func (e *Engine) forTenant(id string) (*Engine, bool) {
+	id = tenantID(id)
+	if id == e.tenant {
+		return e, true
+	}
+	te, ok := e.tenants[id]
+	return te, ok
+}
+
+// engineFor — движок тенанта тикета (неизвестный тенант отсеивается раньше;
+// здесь он попадает в default)
+func (e *Engine) engineFor(t TicketInput) *Engine {
+	if te, ok := e.forTenant(t.Tenant); ok {
+		return te
+	}
+	return e
+}
+
+// allTenants — e и движки тенантов по имени (для сводок и сброса состояния)
+func (e *Engine) allTenants() []*Engine {
+	ids := make([]string, 0, len(e.tenants))
+	for id := range e.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	engines := []*Engine{e}
+	for _, id := range ids {
+		engines = append(engines, e.tenants[id])
+	}
+	return engines
+}
+
+// SetDB — подключение к БД для e и всех тенантов (после initDB)
+func (e *Engine) SetDB(conn *sql.DB) {
+	for _, te := range e.allTenants() {
+		te.db = conn
+	}
+}
+
+// groupByTenant — тикеты по движкам тенантов, в порядке первого появления
+func (e *Engine) groupByTenant(tickets []TicketInput) ([]*Engine, [][]TicketInput) {
+	var engines []*Engine
+	var groups [][]TicketInput
+	pos := make(map[*Engine]int)
+	for _, t := range tickets {
+		te := e.engineFor(t)
+		i, ok := pos[te]
+		if !ok {
+			i = len(engines)
+			pos[te] = i
+			engines = append(engines, te)
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], t)
+	}
+	return engines, groups
+}
+
+// analyzeByTenant — analyzeAllInChunks отдельно по тенантам: в промпт
+// попадают офисы тенанта тикета. Спам по доменам (spamPrefilter) в AI не
+// уходит. Индексы результатов — TicketInput.Index.
+func (e *Engine) analyzeByTenant(ctx context.Context, tickets []TicketInput, keys *apiKeyPool, chunkSize, pauseSec int) (map[int]AIResult, error) {
+	results := make(map[int]AIResult, len(tickets))
+	tickets = prefilterSpam(tickets, results)
+	engines, groups := e.groupByTenant(tickets)
+	for i, te := range engines {
+		res, err := te.analyzeAllInChunks(ctx, groups[i], keys, chunkSize, pauseSec)
+		maps.Copy(results, res)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// geocodeByTenant — geocodeAllParallel отдельно по тенантам (свои офисы и алиасы)
+func (e *Engine) geocodeByTenant(ctx context.Context, tickets []TicketInput, aiResults map[int]AIResult) {
+	engines, groups := e.groupByTenant(tickets)
+	for i, te := range engines {
+		if ctx.Err() != nil {
+			return
+		}
+		te.geocodeAllParallel(ctx, groups[i], aiResults)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ХРОНОМЕТРАЖ — куда уходит время запуска
+// ═══════════════════════════════════════════════════════════
+
+// ticketTiming — время одного тикета по фазам
+type ticketTiming struct {
+	GUID    string
+	Geocode time.Duration // включая ожидание слота Nominatim; 0 — кэш
+	Routing time.Duration
+}
+
+// runTimings — разбивка времени запуска. AI и фазы геокодирования и роутинга —
+// по стене, Geocode/Routing/DB — сумма по тикетам (геокодирование, БД и роутинг
+// с -route-workers идут параллельно, поэтому сумма может быть больше стены).
+type runTimings struct {
+	mu          sync.Mutex
+	AI          time.Duration
+	GeocodeWall time.Duration
+	Geocode     time.Duration
+	RoutingWall time.Duration
+	Routing     time.Duration
+	DB          time.Duration
+	tickets     map[string]*ticketTiming
+	order       []string
+
+	// CityMemoHits — тикетов, геокодированных из памяти город → офис (-city-memo)
+	CityMemoHits int
+}
+
+// timings — хронометраж текущего пакетного запуска
+var timings = newRunTimings()
+
+func newRunTimings() *runTimings {
+	return &runTimings{tickets: make(map[string]*ticketTiming)}
+}
+
+func (rt *runTimings) ticket(guid string) *ticketTiming {
+	tt, ok := rt.tickets[guid]
+	if !ok {
+		tt = &ticketTiming{GUID: guid}
+		rt.tickets[guid] = tt
+		rt.order = append(rt.order, guid)
+	}
+	return tt
+}
+
+func (rt *runTimings) AddAI(d time.Duration) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.AI += d
+}
+
+func (rt *runTimings) AddGeocodeWall(d time.Duration) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.GeocodeWall += d
+}
+
+func (rt *runTimings) AddGeocode(guid string, d time.Duration) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.Geocode += d
+	rt.ticket(guid).Geocode += d
+}
+
+func (rt *runTimings) AddRoutingWall(d time.Duration) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.RoutingWall += d
+}
+
+func (rt *runTimings) AddRouting(guid string, d time.Duration) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.Routing += d
+	rt.ticket(guid).Routing += d
+}
+
+// AddCityMemoHit — тикет получил офис из памяти города, без геокодирования
+func (rt *runTimings) AddCityMemoHit() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.CityMemoHits++
+}
+
+// AddDB — время фонового сохранения (тикета или пачки)
+func (rt *runTimings) AddDB(d time.Duration) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.DB += d
+}
+
+// Print — итоговая разбивка; вызывать после dbWg.Wait()
+func (rt *runTimings) Print(withDB bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	fmt.Println("\n  ⏱  Время по фазам:")
+	fmt.Printf("    %-28s %v\n", "AI-анализ", rt.AI.Round(time.Millisecond))
+	fmt.Printf("    %-28s %v (сумма по тикетам %v)\n", "Геокодирование",
+		rt.GeocodeWall.Round(time.Millisecond), rt.Geocode.Round(time.Millisecond))
+	if *cityMemoMode {
+		fmt.Printf("    %-28s %d тикетов без геокодирования\n", "Из памяти города", rt.CityMemoHits)
+	}
+	fmt.Printf("    %-28s %v (сумма по тикетам %v)\n", "Роутинг",
+		rt.RoutingWall.Round(time.Millisecond), rt.Routing.Round(time.Millisecond))
+	if withDB {
+		fmt.Printf("    %-28s %v (сумма фоновых сохранений)\n", "БД", rt.DB.Round(time.Millisecond))
+	}
+	if n := len(rt.order); n > 0 {
+		fmt.Printf("    %-28s геокод %v, роутинг %v\n", "В среднем на тикет",
+			(rt.Geocode / time.Duration(n)).Round(time.Millisecond),
+			(rt.Routing / time.Duration(n)).Round(time.Microsecond))
+	}
+}
+
+// Write — по строке на тикет: GUID, геокодирование и роутинг в миллисекундах
+func (rt *runTimings) Write(path string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if dir := filepath.Dir(path); dir != "." {
+		os.MkdirAll(dir, 0755)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ms := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64)
+	}
+	w := newCSVWriter(f)
+	w.Write([]string{"GUID", "Геокодирование_мс", "Роутинг_мс"})
+	for _, guid := range rt.order {
+		tt := rt.tickets[guid]
+		w.Write([]string{tt.GUID, ms(tt.Geocode), ms(tt.Routing)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	fmt.Printf("⏱  Хронометраж %d тикетов → %s\n", len(rt.order), path)
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ТОКЕНЫ И СТОИМОСТЬ — usageMetadata ответов Gemini
+// ═══════════════════════════════════════════════════════════
+
+// tokenUsage — usageMetadata одного ответа. Thoughts — токены «размышлений»
+// моделей 2.5: входят в Total и оплачиваются как выход.
+type tokenUsage struct {
+	Prompt     int `json:"promptTokenCount"`
+	Candidates int `json:"candidatesTokenCount"`
+	Thoughts   int `json:"thoughtsTokenCount"`
+	Total      int `json:"totalTokenCount"`
+}
+
+// Output — оплачиваемые токены ответа
+func (u tokenUsage) Output() int { return u.Candidates + u.Thoughts }
+
+// aiUsageTotals — сумма по всем запросам запуска, включая повторы и
+// повторные запросы summary (каждый оплачивается)
+type aiUsageTotals struct {
+	mu       sync.Mutex
+	Requests int
+	Usage    tokenUsage
+	// Deduplicated — тикетов, не отправленных в AI благодаря -dedup-content
+	Deduplicated int
+	// Prefiltered — тикетов, признанных спамом по доменам до AI (spamPrefilter)
+	Prefiltered int
+}
+
+// aiUsage — токены текущего запуска (сбрасывается вместе с timings)
+var aiUsage = &aiUsageTotals{}
+
+// Тарифы за 1000 токенов (AI_COST_PER_1K_PROMPT, AI_COST_PER_1K_OUTPUT; 0 — оценки нет)
+var (
+	aiCostPer1KPrompt float64
+	aiCostPer1KOutput float64
+)
+
+var metricGeminiTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "fire_gemini_tokens_total",
+	Help: "Токенов Gemini по виду (prompt | candidates | thoughts).",
+}, []string{"kind"})
+
+// loadAICost — тарифы из окружения
+func loadAICost() {
+	if v, err := strconv.ParseFloat(getEnv("AI_COST_PER_1K_PROMPT", ""), 64); err == nil && v >= 0 {
+		aiCostPer1KPrompt = v
+	}
+	if v, err := strconv.ParseFloat(getEnv("AI_COST_PER_1K_OUTPUT", ""), 64); err == nil && v >= 0 {
+		aiCostPer1KOutput = v
+	}
+}
+
+// aiCost — оценка стоимости по тарифам
+func aiCost(u tokenUsage) float64 {
+	return float64(u.Prompt)/1000*aiCostPer1KPrompt + float64(u.Output())/1000*aiCostPer1KOutput
+}
+
+// Add — учесть один ответ и напечатать его токены
+func (a *aiUsageTotals) Add(u tokenUsage) {
+	a.mu.Lock()
+	a.Requests++
+	a.Usage.Prompt += u.Prompt
+	a.Usage.Candidates += u.Candidates
+	a.Usage.Thoughts += u.Thoughts
+	a.Usage.Total += u.Total
+	a.mu.Unlock()
+
+	metricGeminiTokens.WithLabelValues("prompt").Add(float64(u.Prompt))
+	metricGeminiTokens.WithLabelValues("candidates").Add(float64(u.Candidates))
+	metricGeminiTokens.WithLabelValues("thoughts").Add(float64(u.Thoughts))
+	fmt.Printf("   🔢 Токены: промпт %d, ответ %d (размышления %d), всего %d\n",
+		u.Prompt, u.Candidates, u.Thoughts, u.Total)
+}
+
+// AddDeduplicated — n тикетов получили анализ копией, без запроса к AI
+func (a *aiUsageTotals) AddDeduplicated(n int) {
+	a.mu.Lock()
+	a.Deduplicated += n
+	a.mu.Unlock()
+}
+
+// AddPrefiltered — n тикетов признаны спамом по доменам, без запроса к AI
+func (a *aiUsageTotals) AddPrefiltered(n int) {
+	a.mu.Lock()
+	a.Prefiltered += n
+	a.mu.Unlock()
+}
+
+// Reset — начать учёт нового запуска (-watch)
+func (a *aiUsageTotals) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Requests = 0
+	a.Usage = tokenUsage{}
+	a.Deduplicated = 0
+	a.Prefiltered = 0
+}
+
+// Print — итог по токенам и оценка стоимости; без запросов к AI — ничего
+func (a *aiUsageTotals) Print() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Deduplicated > 0 {
+		// Чанки по 10 тикетов: столько запросов не понадобилось (оценка)
+		fmt.Printf("\n  ♊ Дубликаты по содержимому: %d тикетов без AI (≈%d запросов сэкономлено)\n",
+			a.Deduplicated, (a.Deduplicated+9)/10)
+	}
+	if a.Prefiltered > 0 {
+		fmt.Printf("\n  🚫 Спам по доменам: %d тикетов без AI (≈%d запросов сэкономлено)\n",
+			a.Prefiltered, (a.Prefiltered+9)/10)
+	}
+	if a.Requests == 0 {
+		return
+	}
+	u := a.Usage
+	fmt.Println("\n  💰 Токены AI:")
+	fmt.Printf("    %-22s %d\n", "Запросов", a.Requests)
+	fmt.Printf("    %-22s %d\n", "Промпт", u.Prompt)
+	fmt.Printf("    %-22s %d\n", "Ответ", u.Candidates)
+	fmt.Printf("    %-22s %d\n", "Размышления", u.Thoughts)
+	fmt.Printf("    %-22s %d\n", "Всего", u.Total)
+	if aiCostPer1KPrompt == 0 && aiCostPer1KOutput == 0 {
+		fmt.Println("    Стоимость: тарифы не заданы (AI_COST_PER_1K_PROMPT / AI_COST_PER_1K_OUTPUT)")
+		return
+	}
+	fmt.Printf("    %-22s %.4f (промпт %.4f/1K, выход %.4f/1K)\n", "Оценка стоимости",
+		aiCost(u), aiCostPer1KPrompt, aiCostPer1KOutput)
+}
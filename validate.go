@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// ═══════════════════════════════════════════════════════════
+//  ПРОВЕРКА КОНФИГУРАЦИИ — единый отчёт до начала работы (-check)
+// ═══════════════════════════════════════════════════════════
+
+// configCheck — один пункт отчёта. Required=false — проблема не мешает
+// запуску (необязательный файл, недоступная БД → работа только с CSV).
+type configCheck struct {
+	Name     string
+	OK       bool
+	Required bool
+	Detail   string
+}
+
+// appPaths — входные файлы запуска (после findFile)
+type appPaths struct {
+	Tickets, Offices, Managers  string
+	CityAliases, PriorityMatrix string
+	TicketColumns, Tenants      string
+	Profanity, SegmentRules     string
+	Overrides, RoleRules        string
+}
+
+// validateConfig — ключи AI (или доступность Ollama), входные файлы и БД (если настроена). Печатает
+// отчёт целиком и возвращает false, если не выполнено обязательное условие.
+// needTickets=false в режиме -serve: тикеты приходят через POST /route.
+func validateConfig(ctx context.Context, keys *apiKeyPool, paths appPaths, needTickets bool) bool {
+	var checks []configCheck
+
+	if o, ok := analyzer.(ollamaAnalyzer); ok {
+		if err := pingOllama(ctx, o); err != nil {
+			checks = append(checks, configCheck{"Ollama", false, false,
+				fmt.Sprintf("%s: %v → Keyword Fallback", o.baseURL, err)})
+		} else {
+			checks = append(checks, configCheck{"Ollama", true, false, o.baseURL + ", модель " + o.model})
+		}
+	} else {
+		keysDetail := fmt.Sprintf("%d ключ(ей) Gemini", keys.Len())
+		if keys.Len() == 0 {
+			keysDetail = "не задан GEMINI_API_KEY / GEMINI_API_KEYS (.env или окружение)"
+		}
+		// -regeo не обращается к AI: без ключей только предупреждение
+		checks = append(checks, configCheck{"AI-ключи", keys.Len() > 0, !*regeoMode, keysDetail})
+	}
+
+	file := func(name, path string, required bool) {
+		if path == stdinPath {
+			checks = append(checks, configCheck{name, true, required, "стандартный ввод (-stdin)"})
+			return
+		}
+		info, err := os.Stat(path)
+		switch {
+		case err != nil && required:
+			checks = append(checks, configCheck{name, false, true, path + ": файл не найден"})
+		case err != nil:
+			checks = append(checks, configCheck{name, true, false, path + ": нет (необязательный)"})
+		case info.Size() == 0:
+			checks = append(checks, configCheck{name, false, required, path + ": пустой файл"})
+		default:
+			checks = append(checks, configCheck{name, true, required, path})
+		}
+	}
+	file("Тикеты", paths.Tickets, needTickets)
+	file("Офисы", paths.Offices, true)
+	file("Менеджеры", paths.Managers, true)
+	file("Алиасы городов", paths.CityAliases, false)
+	file("Матрица приоритетов", paths.PriorityMatrix, false)
+	file("Правила сегментов", paths.SegmentRules, false)
+	file("Требования к должности", paths.RoleRules, false)
+	file("Колонки тикетов", paths.TicketColumns, false)
+	file("Тенанты", paths.Tenants, false)
+	file("Ручные назначения", paths.Overrides, false)
+	if *maskProfanity {
+		file("Ненормативная лексика", paths.Profanity, false)
+	}
+
+	if *mailWorklists {
+		if c := loadSMTPConfig(); c.Host == "" || c.From == "" {
+			checks = append(checks, configCheck{"SMTP", false, true, "-mail-worklists: нужны SMTP_HOST и SMTP_FROM (или SMTP_USER)"})
+		} else {
+			checks = append(checks, configCheck{"SMTP", true, false, net.JoinHostPort(c.Host, c.Port) + ", от " + c.From})
+		}
+	}
+
+	if dbConfigured() {
+		if err := pingDB(ctx); err != nil {
+			checks = append(checks, configCheck{"PostgreSQL", false, false,
+				fmt.Sprintf("%s: %v → работа только с CSV", getEnv("DB_NAME", "fire_db"), err)})
+		} else {
+			checks = append(checks, configCheck{"PostgreSQL", true, false, getEnv("DB_NAME", "fire_db")})
+		}
+	} else {
+		checks = append(checks, configCheck{"PostgreSQL", true, false, "не настроена (DB_HOST/DB_NAME) — только CSV"})
+	}
+
+	ok := true
+	fmt.Println("--- Проверка конфигурации ---")
+	for _, c := range checks {
+		mark := "✅"
+		if !c.OK {
+			mark = "⚠️ "
+			if c.Required {
+				mark = "❌"
+				ok = false
+			}
+		}
+		fmt.Printf("  %s %-22s %s\n", mark, c.Name, c.Detail)
+	}
+	fmt.Println(strings.Repeat("─", 30))
+	return ok
+}